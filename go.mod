@@ -3,15 +3,13 @@ module github.com/aqua777/ai-flow
 go 1.24
 
 require (
+	github.com/google/uuid v1.3.0
 	github.com/philippgille/chromem-go v0.7.0
+	github.com/pkoukk/tiktoken-go v0.1.8
 	github.com/stretchr/testify v1.11.1
 )
 
-require (
-	github.com/dlclark/regexp2 v1.10.0 // indirect
-	github.com/google/uuid v1.3.0 // indirect
-	github.com/pkoukk/tiktoken-go v0.1.8 // indirect
-)
+require github.com/dlclark/regexp2 v1.10.0 // indirect
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
@@ -1,14 +1,16 @@
 package http
 
 import (
+	"bufio"
 	"bytes"
 	"context"
+	"errors"
 	"io"
+	"log/slog"
 	"net/http"
 	"strings"
 	"sync"
 	"time"
-	"log/slog"
 )
 
 const (
@@ -19,10 +21,12 @@ const (
 )
 
 type Client struct {
-	baseUrl    string
-	timeout    time.Duration
-	clientOnce sync.Once
-	client     *http.Client
+	baseUrl          string
+	timeout          time.Duration
+	clientOnce       sync.Once
+	client           *http.Client
+	streamClientOnce sync.Once
+	streamClient     *http.Client
 }
 
 func (c *Client) WithTimeout(timeout time.Duration) *Client {
@@ -42,15 +46,41 @@ func (c *Client) getClient() *http.Client {
 	return c.client
 }
 
+// getStreamClient returns a client without an overall timeout; streamed
+// responses can legitimately outlive it and are bounded by the context instead.
+func (c *Client) getStreamClient() *http.Client {
+	c.streamClientOnce.Do(func() {
+		c.streamClient = &http.Client{}
+	})
+	return c.streamClient
+}
+
 func (c *Client) getFullUrl(path string) string {
 	return c.baseUrl + strings.ReplaceAll(path, "//", "/")
 }
 
+// Response is a fully read HTTP response.
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
 func (c *Client) Do(ctx context.Context, method, path string, headers map[string]string, dataBytes []byte) (data []byte, status int, err error) {
+	resp, err := c.DoResponse(ctx, method, path, headers, dataBytes)
+	if err != nil {
+		return nil, 0, err
+	}
+	return resp.Body, resp.StatusCode, nil
+}
+
+// DoResponse is like Do but also returns the response headers, which callers
+// need to honour Retry-After and similar.
+func (c *Client) DoResponse(ctx context.Context, method, path string, headers map[string]string, dataBytes []byte) (*Response, error) {
 	slog.Debug("HttpClient.Do()", "method", method, "path", path, "headers", headers, "dataBytes", string(dataBytes))
 	req, err := http.NewRequestWithContext(ctx, method, c.getFullUrl(path), bytes.NewReader(dataBytes))
 	if err != nil {
-		return nil, 0, err
+		return nil, err
 	}
 
 	for key, value := range headers {
@@ -59,17 +89,70 @@ func (c *Client) Do(ctx context.Context, method, path string, headers map[string
 
 	resp, err := c.getClient().Do(req)
 	if err != nil {
-		return nil, 0, err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, 0, err
+		return nil, err
 	}
 	slog.Debug("HttpClient.Do()", "respBody", string(respBody), "statusCode", resp.StatusCode)
 
-	return respBody, resp.StatusCode, nil
+	return &Response{StatusCode: resp.StatusCode, Header: resp.Header, Body: respBody}, nil
+}
+
+// DoStream sends the request and passes each non-empty line of a successful
+// response body to onLine as it arrives. For non-200 responses the body is
+// read whole and returned in the response so callers can build an error; for
+// 200 responses the returned Body is empty.
+func (c *Client) DoStream(ctx context.Context, method, path string, headers map[string]string, dataBytes []byte, onLine func(line []byte) error) (*Response, error) {
+	slog.Debug("HttpClient.DoStream()", "method", method, "path", path, "headers", headers)
+	req, err := http.NewRequestWithContext(ctx, method, c.getFullUrl(path), bytes.NewReader(dataBytes))
+	if err != nil {
+		return nil, err
+	}
+
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := c.getStreamClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	result := &Response{StatusCode: resp.StatusCode, Header: resp.Header}
+	if resp.StatusCode != http.StatusOK {
+		result.Body, err = io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+
+	reader := bufio.NewReader(resp.Body)
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			if err := onLine(line); err != nil {
+				return result, err
+			}
+		}
+		if readErr != nil {
+			if errors.Is(readErr, io.EOF) {
+				return result, nil
+			}
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return result, ctxErr
+			}
+			return result, readErr
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return result, ctxErr
+		}
+	}
 }
 
 func NewClient(optionalBaseUrl ...string) (*Client, error) {
@@ -0,0 +1,71 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const RetryAfterHeader = "Retry-After"
+
+// StatusError is returned by JsonClient for non-200 responses.
+type StatusError struct {
+	StatusCode int
+	// Message is the "error" field of a JSON error body, if there was one.
+	Message string
+	Body    []byte
+	// RetryAfter is the delay requested by the server's Retry-After header,
+	// or zero when it sent none.
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("status code: %d, error: %s", e.StatusCode, e.Message)
+	}
+	if len(e.Body) > 0 {
+		return fmt.Sprintf("status code: %d, body: %s", e.StatusCode, string(e.Body))
+	}
+	return fmt.Sprintf("status code: %d", e.StatusCode)
+}
+
+func newStatusError(resp *Response) *StatusError {
+	err := &StatusError{
+		StatusCode: resp.StatusCode,
+		Body:       resp.Body,
+		RetryAfter: ParseRetryAfter(resp.Header.Get(RetryAfterHeader), time.Now()),
+	}
+	// Check if response body contains error message
+	var errResp map[string]interface{}
+	if jsonErr := json.Unmarshal(resp.Body, &errResp); jsonErr == nil {
+		if errMsg, ok := errResp["error"].(string); ok {
+			err.Message = errMsg
+		}
+	}
+	return err
+}
+
+// ParseRetryAfter parses a Retry-After header value, given either as a number
+// of seconds or as an HTTP date, into a delay relative to now. It returns zero
+// for empty, invalid or past values.
+func ParseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		if seconds <= 0 {
+			return 0
+		}
+		return time.Duration(seconds * float64(time.Second))
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if delay := at.Sub(now); delay > 0 {
+			return delay
+		}
+	}
+	return 0
+}
@@ -0,0 +1,44 @@
+package http
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type errorsTestSuite struct {
+	suite.Suite
+}
+
+func (suite *errorsTestSuite) TestParseRetryAfter() {
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name     string
+		value    string
+		expected time.Duration
+	}{
+		{name: "empty", value: "", expected: 0},
+		{name: "seconds", value: "120", expected: 2 * time.Minute},
+		{name: "fractional seconds", value: "0.5", expected: 500 * time.Millisecond},
+		{name: "negative", value: "-1", expected: 0},
+		{name: "http date", value: "Thu, 02 Jan 2025 03:04:35 GMT", expected: 30 * time.Second},
+		{name: "past date", value: "Thu, 02 Jan 2025 03:00:00 GMT", expected: 0},
+		{name: "garbage", value: "soon", expected: 0},
+	}
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			suite.Equal(tt.expected, ParseRetryAfter(tt.value, now))
+		})
+	}
+}
+
+func (suite *errorsTestSuite) TestStatusError() {
+	suite.EqualError(&StatusError{StatusCode: 500}, "status code: 500")
+	suite.EqualError(&StatusError{StatusCode: 404, Message: "model not found"}, "status code: 404, error: model not found")
+	suite.EqualError(&StatusError{StatusCode: 502, Body: []byte("bad gateway")}, "status code: 502, body: bad gateway")
+}
+
+func TestErrorsSuite(t *testing.T) {
+	suite.Run(t, new(errorsTestSuite))
+}
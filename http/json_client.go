@@ -3,11 +3,21 @@ package http
 import (
 	"context"
 	"encoding/json"
-	"fmt"
 )
 
 type JsonClient struct {
 	Client *Client
+	// ErrorMapper, if set, converts non-200 responses into the caller's own
+	// error type; by default they are returned as *StatusError.
+	ErrorMapper func(err *StatusError) error
+}
+
+func (c *JsonClient) statusError(resp *Response) error {
+	err := newStatusError(resp)
+	if c.ErrorMapper != nil {
+		return c.ErrorMapper(err)
+	}
+	return err
 }
 
 func (c *JsonClient) Do(ctx context.Context, method, path string, reqObj, respObj any, headers map[string]string) (err error) {
@@ -19,25 +29,14 @@ func (c *JsonClient) Do(ctx context.Context, method, path string, reqObj, respOb
 	if err != nil {
 		return err
 	}
-	respBytes, status, err := c.Client.Do(ctx, method, path, headers, reqData)
+	resp, err := c.Client.DoResponse(ctx, method, path, headers, reqData)
 	if err != nil {
 		return err
-	} else if status != StatusOK {
-		// Check if response body contains error message
-		if len(respBytes) > 0 {
-			var errResp map[string]interface{}
-			if jsonErr := json.Unmarshal(respBytes, &errResp); jsonErr == nil {
-				if errMsg, ok := errResp["error"].(string); ok {
-					return fmt.Errorf("status code: %d, error: %s", status, errMsg)
-				}
-			}
-			// If not JSON error or couldn't parse, return raw body as string
-			return fmt.Errorf("status code: %d, body: %s", status, string(respBytes))
-		}
-		return fmt.Errorf("status code: %d", status)
+	} else if resp.StatusCode != StatusOK {
+		return c.statusError(resp)
 	}
 	if respObj != nil {
-		err = json.Unmarshal(respBytes, respObj)
+		err = json.Unmarshal(resp.Body, respObj)
 		if err != nil {
 			return err
 		}
@@ -45,6 +44,26 @@ func (c *JsonClient) Do(ctx context.Context, method, path string, reqObj, respOb
 	return nil
 }
 
+// Stream sends reqObj as JSON and passes each line of a newline-delimited
+// JSON response to onLine.
+func (c *JsonClient) Stream(ctx context.Context, method, path string, reqObj any, headers map[string]string, onLine func(line []byte) error) (err error) {
+	if headers == nil {
+		headers = make(map[string]string)
+	}
+	headers[ContentTypeHeader] = ContentTypeJson
+	reqData, err := json.Marshal(reqObj)
+	if err != nil {
+		return err
+	}
+	resp, err := c.Client.DoStream(ctx, method, path, headers, reqData, onLine)
+	if err != nil {
+		return err
+	} else if resp.StatusCode != StatusOK {
+		return c.statusError(resp)
+	}
+	return nil
+}
+
 func (c *JsonClient) Get(ctx context.Context, path string, respObj any, headers map[string]string) (err error) {
 	return c.Do(ctx, MethodGet, path, nil, respObj, headers)
 }
@@ -53,6 +72,10 @@ func (c *JsonClient) Post(ctx context.Context, path string, reqObj, respObj any,
 	return c.Do(ctx, MethodPost, path, reqObj, respObj, headers)
 }
 
+func (c *JsonClient) PostStream(ctx context.Context, path string, reqObj any, headers map[string]string, onLine func(line []byte) error) (err error) {
+	return c.Stream(ctx, MethodPost, path, reqObj, headers, onLine)
+}
+
 func (c *JsonClient) Put(ctx context.Context, path string, reqObj, respObj any, headers map[string]string) (err error) {
 	return c.Do(ctx, MethodPut, path, reqObj, respObj, headers)
 }
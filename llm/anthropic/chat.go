@@ -0,0 +1,170 @@
+package anthropic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/aqua777/ai-flow/llm/models"
+)
+
+func (c *Client) Chat(ctx context.Context, r *models.ChatRequest, stream ...func(chunk []byte) error) (*models.ChatResponse, error) {
+	req, err := toMessagesRequest(r)
+	if err != nil {
+		return nil, err
+	}
+	req.Stream = len(stream) > 0 && stream[0] != nil
+	body, err := models.MergeExtra(req, r.Options.ExtraFor(models.ANTHROPIC))
+	if err != nil {
+		return nil, err
+	}
+	if req.Stream {
+		return c.streamChat(ctx, body, stream[0])
+	}
+
+	var resp MessagesResponse
+	if err := c.client.Post(ctx, "/messages", body, &resp, c.headers()); err != nil {
+		return nil, err
+	}
+	result := fromContentBlocks(resp.Content)
+	result.Metadata = toMetadata(resp.Usage)
+	result.FinishReason = toFinishReason(resp.StopReason)
+	return result, nil
+}
+
+func (c *Client) Generate(ctx context.Context, r *models.GenerateRequest, stream ...func(chunk []byte) error) (*models.GenerateResponse, error) {
+	resp, err := c.Chat(ctx, &models.ChatRequest{
+		Model:          r.Model,
+		Messages:       []*models.Message{{Role: models.UserRole, Content: r.Prompt}},
+		ResponseFormat: r.ResponseFormat,
+		Options:        r.Options,
+	}, stream...)
+	if err != nil {
+		return nil, err
+	}
+	return &models.GenerateResponse{
+		Text:             resp.Content,
+		Model:            r.Model,
+		PromptTokens:     resp.Metadata.PromptTokens,
+		CompletionTokens: resp.Metadata.CompletionTokens,
+		TotalTokens:      resp.Metadata.TotalTokens,
+		FinishReason:     resp.FinishReason,
+	}, nil
+}
+
+type streamDelta struct {
+	Type        string `json:"type"`
+	Text        string `json:"text,omitempty"`
+	PartialJSON string `json:"partial_json,omitempty"`
+	Thinking    string `json:"thinking,omitempty"`
+	StopReason  string `json:"stop_reason,omitempty"`
+}
+
+type streamError struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+type streamEvent struct {
+	Type         string            `json:"type"`
+	Index        int               `json:"index"`
+	Message      *MessagesResponse `json:"message,omitempty"`
+	ContentBlock *ContentBlock     `json:"content_block,omitempty"`
+	Delta        *streamDelta      `json:"delta,omitempty"`
+	Usage        *Usage            `json:"usage,omitempty"`
+	Error        *streamError      `json:"error,omitempty"`
+}
+
+var dataPrefix = []byte("data:")
+
+// streamChat consumes the server-sent events of a streamed message. Text
+// deltas are forwarded to callback; thinking and tool input deltas are
+// accumulated per content block and folded into the final response.
+func (c *Client) streamChat(ctx context.Context, body any, callback func(chunk []byte) error) (*models.ChatResponse, error) {
+	var (
+		blocks     []*ContentBlock
+		toolInput  = make(map[int]*strings.Builder)
+		usage      Usage
+		stopReason string
+		done       bool
+	)
+	err := c.client.PostStream(ctx, "/messages", body, c.headers(), func(line []byte) error {
+		// Only data lines carry payloads; each payload repeats its event type.
+		if !bytes.HasPrefix(line, dataPrefix) {
+			return nil
+		}
+		var event streamEvent
+		if err := json.Unmarshal(bytes.TrimSpace(line[len(dataPrefix):]), &event); err != nil {
+			return fmt.Errorf("failed to decode stream event: %w", err)
+		}
+		switch event.Type {
+		case "message_start":
+			if event.Message != nil {
+				usage.InputTokens = event.Message.Usage.InputTokens
+				usage.OutputTokens = event.Message.Usage.OutputTokens
+			}
+		case "content_block_start":
+			for len(blocks) <= event.Index {
+				blocks = append(blocks, &ContentBlock{})
+			}
+			if event.ContentBlock != nil {
+				blocks[event.Index] = event.ContentBlock
+			}
+			delete(toolInput, event.Index)
+		case "content_block_delta":
+			if event.Delta == nil || event.Index >= len(blocks) {
+				return nil
+			}
+			block := blocks[event.Index]
+			switch event.Delta.Type {
+			case "text_delta":
+				block.Text += event.Delta.Text
+				if event.Delta.Text != "" {
+					return callback([]byte(event.Delta.Text))
+				}
+			case "thinking_delta":
+				block.Thinking += event.Delta.Thinking
+			case "input_json_delta":
+				input, ok := toolInput[event.Index]
+				if !ok {
+					input = &strings.Builder{}
+					toolInput[event.Index] = input
+				}
+				input.WriteString(event.Delta.PartialJSON)
+			}
+		case "content_block_stop":
+			if event.Index < len(blocks) && blocks[event.Index].Type == blockToolUse {
+				if input, ok := toolInput[event.Index]; ok && input.Len() > 0 {
+					blocks[event.Index].Input = json.RawMessage(input.String())
+				}
+			}
+		case "message_delta":
+			if event.Delta != nil && event.Delta.StopReason != "" {
+				stopReason = event.Delta.StopReason
+			}
+			if event.Usage != nil {
+				usage.OutputTokens = event.Usage.OutputTokens
+			}
+		case "message_stop":
+			done = true
+		case "error":
+			if event.Error != nil {
+				return models.NewProviderError(models.ANTHROPIC, 0, event.Error.Type, event.Error.Message, nil)
+			}
+			return fmt.Errorf("stream error")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !done {
+		return nil, fmt.Errorf("stream ended before completion")
+	}
+	result := fromContentBlocks(blocks)
+	result.Metadata = toMetadata(usage)
+	result.FinishReason = toFinishReason(stopReason)
+	return result, nil
+}
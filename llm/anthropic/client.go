@@ -0,0 +1,110 @@
+package anthropic
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/aqua777/ai-flow/http"
+	"github.com/aqua777/ai-flow/llm/iface"
+	"github.com/aqua777/ai-flow/llm/models"
+)
+
+const (
+	APIVersion = "2023-06-01"
+
+	// DefaultMaxTokens is sent when the request does not set MaxTokens,
+	// since the Messages API requires it.
+	DefaultMaxTokens = 4096
+
+	apiKeyHeader  = "x-api-key"
+	versionHeader = "anthropic-version"
+)
+
+type Client struct {
+	config *models.LLMConfig
+	client *http.JsonClient
+}
+
+// Ensure Client implements iface.LLM
+var (
+	_ iface.LLM         = (*Client)(nil)
+	_ iface.ModelGetter = (*Client)(nil)
+)
+
+func NewClient(optionalConfig ...*models.LLMConfig) (*Client, error) {
+	config := models.OptionalConfig(optionalConfig).GetConfig(models.ANTHROPIC)
+	client, err := http.NewJsonClient(config.Url)
+	if err != nil {
+		return nil, err
+	}
+	client.ErrorMapper = toProviderError
+	return &Client{
+		config: config,
+		client: client,
+	}, nil
+}
+
+// headers returns a fresh header map per call; JsonClient writes into it.
+func (c *Client) headers() map[string]string {
+	return map[string]string{
+		apiKeyHeader:  c.config.ApiKey,
+		versionHeader: APIVersion,
+	}
+}
+
+type AnthropicModel struct {
+	ID          string `json:"id"`
+	DisplayName string `json:"display_name"`
+	Type        string `json:"type"`
+	CreatedAt   string `json:"created_at"`
+}
+
+func (m *AnthropicModel) toModel() *models.Model {
+	return &models.Model{
+		ID:          m.ID,
+		Name:        m.DisplayName,
+		Model:       m.ID,
+		Description: m.DisplayName,
+	}
+}
+
+type ListModelsResponse struct {
+	Data    []*AnthropicModel `json:"data"`
+	HasMore bool              `json:"has_more"`
+	LastID  string            `json:"last_id"`
+}
+
+func (c *Client) ListModels(ctx context.Context) ([]*models.Model, error) {
+	var results []*models.Model
+	path := "/models?limit=1000"
+	for {
+		var response ListModelsResponse
+		if err := c.client.Get(ctx, path, &response, c.headers()); err != nil {
+			return nil, err
+		}
+		for _, m := range response.Data {
+			results = append(results, m.toModel())
+		}
+		if !response.HasMore || response.LastID == "" {
+			return results, nil
+		}
+		path = fmt.Sprintf("/models?limit=1000&after_id=%s", response.LastID)
+	}
+}
+
+func (c *Client) GetModel(ctx context.Context, name string) (*models.Model, error) {
+	var response AnthropicModel
+	if err := c.client.Get(ctx, "/models/"+url.PathEscape(name), &response, c.headers()); err != nil {
+		return nil, err
+	}
+	return response.toModel(), nil
+}
+
+func (c *Client) Embeddings(ctx context.Context, cr *models.EmbeddingsRequest) (*models.EmbeddingsResponse, error) {
+	return nil, fmt.Errorf("anthropic embeddings: %w", models.ErrNotSupported)
+}
+
+func (c *Client) BatchEmbeddings(ctx context.Context, r *models.BatchEmbeddingsRequest) (*models.BatchEmbeddingsResponse, error) {
+	return nil, fmt.Errorf("anthropic embeddings: %w", models.ErrNotSupported)
+}
@@ -0,0 +1,250 @@
+package anthropic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/aqua777/ai-flow/llm/models"
+)
+
+type ClientTestSuite struct {
+	suite.Suite
+	server   *httptest.Server
+	handler  http.HandlerFunc
+	lastReq  *MessagesRequest
+	lastBody map[string]any
+	headers  http.Header
+	client   *Client
+}
+
+func TestClientTestSuite(t *testing.T) {
+	suite.Run(t, new(ClientTestSuite))
+}
+
+func (s *ClientTestSuite) SetupTest() {
+	s.lastReq = nil
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.headers = r.Header.Clone()
+		if r.URL.Path == "/v1/messages" {
+			body, err := io.ReadAll(r.Body)
+			s.Require().NoError(err)
+			s.lastReq = new(MessagesRequest)
+			s.Require().NoError(json.Unmarshal(body, s.lastReq))
+			s.Require().NoError(json.Unmarshal(body, &s.lastBody))
+		}
+		s.handler(w, r)
+	}))
+	client, err := NewClient(&models.LLMConfig{Url: s.server.URL + "/v1", ApiKey: "secret"})
+	s.Require().NoError(err)
+	s.client = client
+}
+
+func (s *ClientTestSuite) TearDownTest() {
+	s.server.Close()
+}
+
+func (s *ClientTestSuite) TestChat() {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id":"msg_1","type":"message","role":"assistant","model":"claude","stop_reason":"tool_use",
+			"content":[{"type":"thinking","thinking":"User wants weather."},{"type":"text","text":"Let me check."},{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{"city":"Paris"}}],
+			"usage":{"input_tokens":20,"output_tokens":8}}`)
+	}
+	call := &models.ToolCall{ID: "toolu_0", Name: "get_time", Arguments: `{}`}
+	resp, err := s.client.Chat(context.Background(), &models.ChatRequest{
+		Model: "claude",
+		Messages: []*models.Message{
+			{Role: models.SystemRole, Content: "Be brief."},
+			{Role: models.UserRole, Content: "Weather in Paris?"},
+			{Role: models.AssistantRole, ToolCalls: []*models.ToolCall{call}},
+			models.NewToolResultMessage(call, "12:00"),
+			{Role: models.UserRole, Content: "And the weather?"},
+		},
+		Tools:   []*models.Tool{{Name: "get_weather", Parameters: map[string]any{"type": "object"}}},
+		Options: models.RequestOptions{Temperature: 0.2},
+	})
+	s.Require().NoError(err)
+	s.Equal("Let me check.", resp.Content)
+	s.Equal("User wants weather.", resp.Reasoning)
+	s.Require().Len(resp.ToolCalls, 1)
+	s.Equal("toolu_1", resp.ToolCalls[0].ID)
+	s.JSONEq(`{"city":"Paris"}`, resp.ToolCalls[0].Arguments)
+	s.Equal(&models.ChatResponseMetadata{PromptTokens: 20, CompletionTokens: 8, TotalTokens: 28}, resp.Metadata)
+	s.Equal(models.FinishReasonToolCalls, resp.FinishReason)
+
+	s.Equal("secret", s.headers.Get("x-api-key"))
+	s.Equal(APIVersion, s.headers.Get("anthropic-version"))
+	s.Equal("Be brief.", s.lastReq.System)
+	s.Equal(DefaultMaxTokens, s.lastReq.MaxTokens)
+	s.Equal(0.2, *s.lastReq.Temperature)
+	s.Equal("get_weather", s.lastReq.Tools[0].Name)
+	s.Require().Len(s.lastReq.Messages, 3, "tool result and follow-up user text merge into one user turn")
+	s.Equal(blockToolUse, s.lastReq.Messages[1].Content[0].Type)
+	userTurn := s.lastReq.Messages[2]
+	s.Equal("user", userTurn.Role)
+	s.Equal(blockToolResult, userTurn.Content[0].Type)
+	s.Equal("toolu_0", userTurn.Content[0].ToolUseID)
+	s.Equal("And the weather?", userTurn.Content[1].Text)
+}
+
+func (s *ClientTestSuite) TestChat_Stream() {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		events := []string{
+			`{"type":"message_start","message":{"id":"msg_1","role":"assistant","content":[],"usage":{"input_tokens":12,"output_tokens":1}}}`,
+			`{"type":"content_block_start","index":0,"content_block":{"type":"thinking","thinking":""}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"Hmm."}}`,
+			`{"type":"content_block_stop","index":0}`,
+			`{"type":"content_block_start","index":1,"content_block":{"type":"text","text":""}}`,
+			`{"type":"ping"}`,
+			`{"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":"Hello"}}`,
+			`{"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":" there"}}`,
+			`{"type":"content_block_stop","index":1}`,
+			`{"type":"content_block_start","index":2,"content_block":{"type":"tool_use","id":"toolu_1","name":"lookup","input":{}}}`,
+			`{"type":"content_block_delta","index":2,"delta":{"type":"input_json_delta","partial_json":"{\"q\":"}}`,
+			`{"type":"content_block_delta","index":2,"delta":{"type":"input_json_delta","partial_json":"\"go\"}"}}`,
+			`{"type":"content_block_stop","index":2}`,
+			`{"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":15}}`,
+			`{"type":"message_stop"}`,
+		}
+		for _, event := range events {
+			var typed struct{ Type string }
+			json.Unmarshal([]byte(event), &typed)
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", typed.Type, event)
+		}
+	}
+	var chunks []string
+	resp, err := s.client.Chat(context.Background(), &models.ChatRequest{
+		Model:    "claude",
+		Messages: []*models.Message{{Role: models.UserRole, Content: "hi"}},
+	}, func(chunk []byte) error {
+		chunks = append(chunks, string(chunk))
+		return nil
+	})
+	s.Require().NoError(err)
+	s.True(s.lastReq.Stream)
+	s.Equal([]string{"Hello", " there"}, chunks)
+	s.Equal("Hello there", resp.Content)
+	s.Equal("Hmm.", resp.Reasoning)
+	s.Require().Len(resp.ToolCalls, 1)
+	s.JSONEq(`{"q":"go"}`, resp.ToolCalls[0].Arguments)
+	s.Equal(&models.ChatResponseMetadata{PromptTokens: 12, CompletionTokens: 15, TotalTokens: 27}, resp.Metadata)
+	s.Equal(models.FinishReasonToolCalls, resp.FinishReason)
+}
+
+func (s *ClientTestSuite) TestChat_StreamOutOfOrderBlocks() {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		events := []string{
+			`{"type":"message_start","message":{"id":"msg_1","role":"assistant","content":[],"usage":{"input_tokens":1}}}`,
+			`{"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_1","name":"lookup","input":{}}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"{}"}}`,
+			`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"q\":\"go\"}"}}`,
+			`{"type":"content_block_stop","index":0}`,
+			`{"type":"content_block_stop","index":1}`,
+			`{"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":5}}`,
+			`{"type":"message_stop"}`,
+		}
+		for _, event := range events {
+			fmt.Fprintf(w, "data: %s\n\n", event)
+		}
+	}
+	resp, err := s.client.Chat(context.Background(), &models.ChatRequest{Model: "claude"}, func(chunk []byte) error { return nil })
+	s.Require().NoError(err)
+	s.Require().Len(resp.ToolCalls, 1)
+	s.JSONEq(`{"q":"go"}`, resp.ToolCalls[0].Arguments)
+}
+
+func (s *ClientTestSuite) TestChat_StreamError() {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "event: error\ndata: {\"type\":\"error\",\"error\":{\"type\":\"overloaded_error\",\"message\":\"Overloaded\"}}\n\n")
+	}
+	_, err := s.client.Chat(context.Background(), &models.ChatRequest{Model: "claude"}, func(chunk []byte) error { return nil })
+	s.ErrorContains(err, "overloaded_error")
+	s.ErrorIs(err, models.ErrUnavailable)
+}
+
+func (s *ClientTestSuite) TestChat_Errors() {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"type":"error","error":{"type":"invalid_request_error","message":"prompt is too long: 210000 tokens > 200000 maximum"}}`)
+	}
+	_, err := s.client.Chat(context.Background(), &models.ChatRequest{Model: "claude"})
+	s.ErrorIs(err, models.ErrContextLengthExceeded)
+	var providerErr *models.ProviderError
+	s.Require().ErrorAs(err, &providerErr)
+	s.Equal(models.ANTHROPIC, providerErr.Provider)
+	s.Equal("invalid_request_error", providerErr.Code)
+	s.Equal("prompt is too long: 210000 tokens > 200000 maximum", providerErr.Message)
+}
+
+func (s *ClientTestSuite) TestListModels() {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		s.Equal("/v1/models", r.URL.Path)
+		if r.URL.Query().Get("after_id") == "" {
+			fmt.Fprint(w, `{"data":[{"id":"claude-a","display_name":"Claude A"}],"has_more":true,"last_id":"claude-a"}`)
+			return
+		}
+		fmt.Fprint(w, `{"data":[{"id":"claude-b","display_name":"Claude B"}],"has_more":false}`)
+	}
+	result, err := s.client.ListModels(context.Background())
+	s.Require().NoError(err)
+	s.Require().Len(result, 2)
+	s.Equal("claude-a", result[0].ID)
+	s.Equal("Claude B", result[1].Name)
+}
+
+func (s *ClientTestSuite) TestChat_Images() {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id":"msg_1","type":"message","role":"assistant","content":[{"type":"text","text":"A cat."}],"usage":{}}`)
+	}
+	image := &models.Image{Data: []byte("\x89PNG\r\n\x1a\n")}
+	_, err := s.client.Chat(context.Background(), &models.ChatRequest{
+		Model:    "claude",
+		Messages: []*models.Message{{Role: models.UserRole, Content: "What is this?", Images: []*models.Image{image}}},
+	})
+	s.Require().NoError(err)
+	s.Equal("image/png", s.lastReq.Messages[0].Content[0].Source.MediaType, "MIME type is sniffed when empty")
+
+	_, err = s.client.Chat(context.Background(), &models.ChatRequest{
+		Model:    "claude",
+		Messages: []*models.Message{{Role: models.UserRole, Images: []*models.Image{models.NewImageURL("data:image/jpeg;base64,/9j/4AA=")}}},
+	})
+	s.Require().NoError(err)
+	s.Equal(&ImageSource{Type: "base64", MediaType: "image/jpeg", Data: "/9j/4AA="}, s.lastReq.Messages[0].Content[0].Source, "data URLs are sent inline")
+
+	s.lastReq = nil
+	_, err = s.client.Chat(context.Background(), &models.ChatRequest{
+		Model:    "claude",
+		Messages: []*models.Message{{Role: models.UserRole, Content: "What is this?", Images: []*models.Image{{}}}},
+	})
+	s.ErrorIs(err, models.ErrInvalidImage)
+	s.Nil(s.lastReq, "invalid image must not be sent")
+}
+
+func (s *ClientTestSuite) TestEmbeddingsNotSupported() {
+	_, err := s.client.Embeddings(context.Background(), &models.EmbeddingsRequest{Content: "x"})
+	s.ErrorIs(err, models.ErrNotSupported)
+}
+
+func (s *ClientTestSuite) TestChat_Options() {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id":"msg_1","type":"message","role":"assistant","content":[{"type":"text","text":"ok"}],"usage":{}}`)
+	}
+	options := models.RequestOptions{Stop: []string{"END"}}
+	options.WithExtra(models.ANTHROPIC, "metadata", map[string]any{"user_id": "u1"})
+	_, err := s.client.Chat(context.Background(), &models.ChatRequest{
+		Model:    "claude",
+		Messages: []*models.Message{{Role: models.UserRole, Content: "hi"}},
+		Options:  options,
+	})
+	s.Require().NoError(err)
+	s.Equal([]string{"END"}, s.lastReq.StopSequences)
+	s.Equal(map[string]any{"user_id": "u1"}, s.lastBody["metadata"])
+}
@@ -0,0 +1,28 @@
+package anthropic
+
+import (
+	"encoding/json"
+
+	"github.com/aqua777/ai-flow/http"
+	"github.com/aqua777/ai-flow/llm/models"
+)
+
+type errorResponse struct {
+	Type  string       `json:"type"`
+	Error *streamError `json:"error"`
+}
+
+// toProviderError maps an Anthropic error response, whose body is
+// {"type":"error","error":{"type":"rate_limit_error","message":"..."}}, onto
+// models.ProviderError.
+func toProviderError(err *http.StatusError) error {
+	code, message := "", string(err.Body)
+	var body errorResponse
+	if json.Unmarshal(err.Body, &body) == nil && body.Error != nil {
+		code, message = body.Error.Type, body.Error.Message
+	}
+	providerErr := models.NewProviderError(models.ANTHROPIC, err.StatusCode, code, message, err)
+	providerErr.Body = err.Body
+	providerErr.RetryAfter = err.RetryAfter
+	return providerErr
+}
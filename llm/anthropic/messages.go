@@ -0,0 +1,256 @@
+package anthropic
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/aqua777/ai-flow/llm/models"
+)
+
+const (
+	blockText       = "text"
+	blockImage      = "image"
+	blockToolUse    = "tool_use"
+	blockToolResult = "tool_result"
+	blockThinking   = "thinking"
+)
+
+type ImageSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type,omitempty"`
+	Data      string `json:"data,omitempty"`
+	URL       string `json:"url,omitempty"`
+}
+
+// ContentBlock is the union of the block types the Messages API exchanges.
+type ContentBlock struct {
+	Type      string          `json:"type"`
+	Text      string          `json:"text,omitempty"`
+	Source    *ImageSource    `json:"source,omitempty"`
+	ID        string          `json:"id,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Input     json.RawMessage `json:"input,omitempty"`
+	ToolUseID string          `json:"tool_use_id,omitempty"`
+	Content   string          `json:"content,omitempty"`
+	Thinking  string          `json:"thinking,omitempty"`
+	Signature string          `json:"signature,omitempty"`
+}
+
+type Message struct {
+	Role    string          `json:"role"`
+	Content []*ContentBlock `json:"content"`
+}
+
+type Tool struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	InputSchema any    `json:"input_schema"`
+}
+
+type MessagesRequest struct {
+	Model         string     `json:"model"`
+	System        string     `json:"system,omitempty"`
+	Messages      []*Message `json:"messages"`
+	MaxTokens     int        `json:"max_tokens"`
+	Tools         []*Tool    `json:"tools,omitempty"`
+	Stream        bool       `json:"stream,omitempty"`
+	Temperature   *float64   `json:"temperature,omitempty"`
+	TopP          *float64   `json:"top_p,omitempty"`
+	TopK          int        `json:"top_k,omitempty"`
+	StopSequences []string   `json:"stop_sequences,omitempty"`
+}
+
+type Usage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+type MessagesResponse struct {
+	ID         string          `json:"id"`
+	Type       string          `json:"type"`
+	Role       string          `json:"role"`
+	Model      string          `json:"model"`
+	Content    []*ContentBlock `json:"content"`
+	StopReason string          `json:"stop_reason"`
+	Usage      Usage           `json:"usage"`
+}
+
+// toMessagesRequest maps a chat request onto the Messages API. System
+// messages are lifted into the top-level system prompt, tool results become
+// user tool_result blocks and consecutive messages of the same role are
+// merged, as the API requires alternating turns.
+func toMessagesRequest(r *models.ChatRequest) (*MessagesRequest, error) {
+	req := &MessagesRequest{
+		Model:         r.Model,
+		MaxTokens:     r.Options.MaxTokens,
+		TopK:          r.Options.TopK,
+		StopSequences: r.Options.Stop,
+	}
+	if req.MaxTokens <= 0 {
+		req.MaxTokens = DefaultMaxTokens
+	}
+	if r.Options.Temperature != 0 {
+		req.Temperature = &r.Options.Temperature
+	}
+	if r.Options.TopP != 0 {
+		req.TopP = &r.Options.TopP
+	}
+
+	var system []string
+	for _, msg := range r.Messages {
+		if msg.Role == models.SystemRole {
+			system = append(system, msg.Content)
+			continue
+		}
+		role, blocks, err := toContentBlocks(msg)
+		if err != nil {
+			return nil, err
+		}
+		if n := len(req.Messages); n > 0 && req.Messages[n-1].Role == role {
+			req.Messages[n-1].Content = append(req.Messages[n-1].Content, blocks...)
+			continue
+		}
+		req.Messages = append(req.Messages, &Message{Role: role, Content: blocks})
+	}
+
+	if instruction, err := responseFormatInstruction(r.ResponseFormat); err != nil {
+		return nil, err
+	} else if instruction != "" {
+		system = append(system, instruction)
+	}
+	req.System = strings.Join(system, "\n\n")
+
+	for _, tool := range r.Tools {
+		schema := tool.Parameters
+		if schema == nil {
+			schema = map[string]any{"type": "object", "properties": map[string]any{}}
+		}
+		req.Tools = append(req.Tools, &Tool{
+			Name:        tool.Name,
+			Description: tool.Description,
+			InputSchema: schema,
+		})
+	}
+	return req, nil
+}
+
+func toContentBlocks(msg *models.Message) (string, []*ContentBlock, error) {
+	if msg.Role == models.ToolRole {
+		return string(models.UserRole), []*ContentBlock{{
+			Type:      blockToolResult,
+			ToolUseID: msg.ToolCallID,
+			Content:   msg.Content,
+		}}, nil
+	}
+
+	var blocks []*ContentBlock
+	for _, image := range msg.Images {
+		if err := image.Validate(); err != nil {
+			return "", nil, err
+		}
+		source := &ImageSource{Type: "url", URL: image.URL}
+		if image.Inline() {
+			data, err := image.Base64()
+			if err != nil {
+				return "", nil, err
+			}
+			source = &ImageSource{Type: "base64", MediaType: image.ContentType(), Data: data}
+		}
+		blocks = append(blocks, &ContentBlock{Type: blockImage, Source: source})
+	}
+	if msg.Content != "" {
+		blocks = append(blocks, &ContentBlock{Type: blockText, Text: msg.Content})
+	}
+	for _, call := range msg.ToolCalls {
+		input := json.RawMessage(call.Arguments)
+		if len(input) == 0 {
+			input = json.RawMessage("{}")
+		} else if !json.Valid(input) {
+			return "", nil, fmt.Errorf("tool call %s has invalid JSON arguments", call.Name)
+		}
+		blocks = append(blocks, &ContentBlock{
+			Type:  blockToolUse,
+			ID:    call.ID,
+			Name:  call.Name,
+			Input: input,
+		})
+	}
+	if len(blocks) == 0 {
+		blocks = append(blocks, &ContentBlock{Type: blockText, Text: msg.Content})
+	}
+	return string(msg.Role), blocks, nil
+}
+
+// responseFormatInstruction emulates JSON response formats, which the
+// Messages API has no field for, with a system prompt instruction.
+func responseFormatInstruction(rf *models.ResponseFormat) (string, error) {
+	if rf == nil {
+		return "", nil
+	}
+	switch rf.Type {
+	case "", models.ResponseFormatText:
+		return "", nil
+	case models.ResponseFormatJSON:
+		return "Respond only with a valid JSON object and no other text.", nil
+	case models.ResponseFormatJSONSchema:
+		schema, err := json.Marshal(rf.Schema)
+		if err != nil {
+			return "", fmt.Errorf("failed to marshal response schema: %w", err)
+		}
+		return fmt.Sprintf("Respond only with a JSON value that matches this JSON schema and no other text:\n%s", schema), nil
+	default:
+		return "", fmt.Errorf("unsupported response format type: %s", rf.Type)
+	}
+}
+
+// fromContentBlocks folds response blocks into a chat response.
+func fromContentBlocks(blocks []*ContentBlock) *models.ChatResponse {
+	var content, reasoning strings.Builder
+	resp := &models.ChatResponse{}
+	for _, block := range blocks {
+		switch block.Type {
+		case blockText:
+			content.WriteString(block.Text)
+		case blockThinking:
+			reasoning.WriteString(block.Thinking)
+		case blockToolUse:
+			args := string(block.Input)
+			if args == "" || args == "null" {
+				args = "{}"
+			}
+			resp.ToolCalls = append(resp.ToolCalls, &models.ToolCall{
+				ID:        block.ID,
+				Name:      block.Name,
+				Arguments: args,
+			})
+		}
+	}
+	resp.Content = content.String()
+	resp.Reasoning = reasoning.String()
+	return resp
+}
+
+// toFinishReason maps an Anthropic stop_reason onto models.FinishReason.
+func toFinishReason(stopReason string) models.FinishReason {
+	switch stopReason {
+	case "end_turn", "stop_sequence":
+		return models.FinishReasonStop
+	case "max_tokens":
+		return models.FinishReasonLength
+	case "tool_use":
+		return models.FinishReasonToolCalls
+	case "refusal":
+		return models.FinishReasonContentFilter
+	default:
+		return models.FinishReason(stopReason)
+	}
+}
+
+func toMetadata(usage Usage) *models.ChatResponseMetadata {
+	return &models.ChatResponseMetadata{
+		PromptTokens:     usage.InputTokens,
+		CompletionTokens: usage.OutputTokens,
+		TotalTokens:      usage.InputTokens + usage.OutputTokens,
+	}
+}
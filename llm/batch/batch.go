@@ -0,0 +1,85 @@
+// Package batch splits batch embedding requests at provider limits and runs
+// the parts with bounded concurrency.
+package batch
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/aqua777/ai-flow/llm/models"
+)
+
+// EmbedFunc embeds one provider-sized batch, returning a vector per input.
+type EmbedFunc func(ctx context.Context, inputs []string) ([][]float32, error)
+
+// Embed splits r.Inputs into batches of at most min(r.BatchSize, providerLimit)
+// inputs, embeds them with up to r.Concurrency calls in flight and returns the
+// vectors in input order. The first failure cancels the remaining batches.
+func Embed(ctx context.Context, r *models.BatchEmbeddingsRequest, providerLimit int, embed EmbedFunc) (*models.BatchEmbeddingsResponse, error) {
+	size := providerLimit
+	if r.BatchSize > 0 && (size <= 0 || r.BatchSize < size) {
+		size = r.BatchSize
+	}
+	if size <= 0 {
+		size = len(r.Inputs)
+	}
+	concurrency := r.Concurrency
+	if concurrency <= 0 {
+		concurrency = models.DefaultEmbeddingsConcurrency
+	}
+
+	result := make([][]float32, len(r.Inputs))
+	if len(r.Inputs) == 0 {
+		return &models.BatchEmbeddingsResponse{Embeddings: result}, nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+		sem      = make(chan struct{}, concurrency)
+	)
+	fail := func(err error) {
+		errOnce.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	for start := 0; start < len(r.Inputs); start += size {
+		end := min(start+size, len(r.Inputs))
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			fail(ctx.Err())
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func(start, end int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			vectors, err := embed(ctx, r.Inputs[start:end])
+			if err != nil {
+				fail(fmt.Errorf("batch %d-%d: %w", start, end, err))
+				return
+			}
+			if len(vectors) != end-start {
+				fail(fmt.Errorf("batch %d-%d: expected %d embeddings, got %d", start, end, end-start, len(vectors)))
+				return
+			}
+			copy(result[start:end], vectors)
+		}(start, end)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return &models.BatchEmbeddingsResponse{Embeddings: result}, nil
+}
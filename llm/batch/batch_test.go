@@ -0,0 +1,105 @@
+package batch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/aqua777/ai-flow/llm/models"
+)
+
+type BatchTestSuite struct {
+	suite.Suite
+}
+
+func TestBatchTestSuite(t *testing.T) {
+	suite.Run(t, new(BatchTestSuite))
+}
+
+func inputs(n int) []string {
+	result := make([]string, n)
+	for i := range result {
+		result[i] = fmt.Sprintf("%d", i)
+	}
+	return result
+}
+
+func (s *BatchTestSuite) TestEmbed_SplitsAndPreservesOrder() {
+	var mu sync.Mutex
+	var sizes []int
+	var inFlight, maxInFlight int32
+	resp, err := Embed(context.Background(), &models.BatchEmbeddingsRequest{Inputs: inputs(10), BatchSize: 100, Concurrency: 2}, 3,
+		func(ctx context.Context, batch []string) ([][]float32, error) {
+			n := atomic.AddInt32(&inFlight, 1)
+			defer atomic.AddInt32(&inFlight, -1)
+			for {
+				m := atomic.LoadInt32(&maxInFlight)
+				if n <= m || atomic.CompareAndSwapInt32(&maxInFlight, m, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			mu.Lock()
+			sizes = append(sizes, len(batch))
+			mu.Unlock()
+			vectors := make([][]float32, len(batch))
+			for i, in := range batch {
+				var v float32
+				fmt.Sscanf(in, "%f", &v)
+				vectors[i] = []float32{v}
+			}
+			return vectors, nil
+		})
+	s.Require().NoError(err)
+	s.ElementsMatch([]int{3, 3, 3, 1}, sizes)
+	s.LessOrEqual(maxInFlight, int32(2))
+	s.Require().Len(resp.Embeddings, 10)
+	for i, v := range resp.Embeddings {
+		s.Equal([]float32{float32(i)}, v)
+	}
+}
+
+func (s *BatchTestSuite) TestEmbed_RequestBatchSizeBelowLimit() {
+	calls := 0
+	_, err := Embed(context.Background(), &models.BatchEmbeddingsRequest{Inputs: inputs(4), BatchSize: 2, Concurrency: 1}, 100,
+		func(ctx context.Context, batch []string) ([][]float32, error) {
+			calls++
+			return make([][]float32, len(batch)), nil
+		})
+	s.NoError(err)
+	s.Equal(2, calls)
+}
+
+func (s *BatchTestSuite) TestEmbed_Errors() {
+	boom := errors.New("boom")
+	_, err := Embed(context.Background(), &models.BatchEmbeddingsRequest{Inputs: inputs(6)}, 2,
+		func(ctx context.Context, batch []string) ([][]float32, error) {
+			if batch[0] == "2" {
+				return nil, boom
+			}
+			return make([][]float32, len(batch)), nil
+		})
+	s.ErrorIs(err, boom)
+
+	_, err = Embed(context.Background(), &models.BatchEmbeddingsRequest{Inputs: inputs(2)}, 0,
+		func(ctx context.Context, batch []string) ([][]float32, error) {
+			return make([][]float32, 1), nil
+		})
+	s.ErrorContains(err, "expected 2 embeddings")
+}
+
+func (s *BatchTestSuite) TestEmbed_Empty() {
+	resp, err := Embed(context.Background(), &models.BatchEmbeddingsRequest{}, 10,
+		func(ctx context.Context, batch []string) ([][]float32, error) {
+			s.Fail("embed should not be called")
+			return nil, nil
+		})
+	s.NoError(err)
+	s.Empty(resp.Embeddings)
+}
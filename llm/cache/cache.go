@@ -0,0 +1,311 @@
+// Package cache provides an iface.LLM decorator that stores responses keyed
+// on a canonical hash of the request, so repeated completions and embeddings
+// are served without calling the provider.
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/aqua777/ai-flow/llm/iface"
+	"github.com/aqua777/ai-flow/llm/models"
+)
+
+// keyVersion changes whenever the key derivation or entry format changes, so
+// old entries are ignored rather than misread.
+const keyVersion = "v1"
+
+type contextKey int
+
+const (
+	bypassKey contextKey = iota
+	refreshKey
+)
+
+// WithBypass returns a context under which the cache is neither read nor
+// written.
+func WithBypass(ctx context.Context) context.Context {
+	return context.WithValue(ctx, bypassKey, true)
+}
+
+// WithRefresh returns a context under which cached entries are ignored but
+// fresh responses are still stored, replacing them.
+func WithRefresh(ctx context.Context) context.Context {
+	return context.WithValue(ctx, refreshKey, true)
+}
+
+func flag(ctx context.Context, key contextKey) bool {
+	v, _ := ctx.Value(key).(bool)
+	return v
+}
+
+// Config configures the cache decorator.
+type Config struct {
+	// Store holds the entries. Defaults to a NewMemoryStore(DefaultCapacity).
+	Store Store
+	// TTL is how long entries live; zero keeps them until evicted.
+	TTL time.Duration
+	// Namespace is mixed into every key, to keep entries from different
+	// providers or deployments apart in a shared store.
+	Namespace string
+}
+
+// Stats counts cache lookups.
+type Stats struct {
+	Hits   int64
+	Misses int64
+}
+
+// LLM caches Generate, Chat, Embeddings and BatchEmbeddings responses of the
+// wrapped LLM. Errors are never cached and ListModels is passed through.
+// Streamed calls are cached too: a hit replays the recorded chunks to the
+// callback, and a cached non-streamed answer is replayed as a single chunk.
+type LLM struct {
+	llm    iface.LLM
+	config Config
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+// Ensure LLM implements iface.LLM
+var (
+	_ iface.LLM         = (*LLM)(nil)
+	_ iface.ModelGetter = (*LLM)(nil)
+)
+
+func New(llm iface.LLM, config *Config) *LLM {
+	c := &LLM{llm: llm}
+	if config != nil {
+		c.config = *config
+	}
+	if c.config.Store == nil {
+		c.config.Store = NewMemoryStore(DefaultCapacity)
+	}
+	return c
+}
+
+// Stats returns the hit and miss counts so far.
+func (c *LLM) Stats() Stats {
+	return Stats{Hits: c.hits.Load(), Misses: c.misses.Load()}
+}
+
+// Key returns the cache key for a request: the hex SHA-256 of the operation,
+// namespace and the request's canonical JSON encoding. Struct fields encode
+// in declaration order and map keys sorted, so equal requests always hash
+// the same.
+func (c *LLM) Key(operation string, request any) (string, error) {
+	data, err := json.Marshal(request)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	for _, part := range []string{keyVersion, c.config.Namespace, operation} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func (c *LLM) ListModels(ctx context.Context) ([]*models.Model, error) {
+	return c.llm.ListModels(ctx)
+}
+
+func (c *LLM) GetModel(ctx context.Context, name string) (*models.Model, error) {
+	return iface.GetModel(ctx, c.llm, name)
+}
+
+type generateEntry struct {
+	Response *models.GenerateResponse `json:"response"`
+	Chunks   []string                 `json:"chunks,omitempty"`
+}
+
+func (c *LLM) Generate(ctx context.Context, r *models.GenerateRequest, stream ...func(chunk []byte) error) (*models.GenerateResponse, error) {
+	keyed := *r
+	keyed.Stream = false
+	var entry generateEntry
+	callback := streamCallback(stream)
+	key, hit := c.lookup(ctx, "Generate", &keyed, &entry)
+	if hit {
+		if err := replay(entry.Chunks, entry.Response.Text, callback); err != nil {
+			return nil, err
+		}
+		return entry.Response, nil
+	}
+	recorded, record := recorder(callback)
+	resp, err := c.llm.Generate(ctx, r, recorded...)
+	if err != nil {
+		return nil, err
+	}
+	c.store(key, generateEntry{Response: resp, Chunks: record()})
+	return resp, nil
+}
+
+type chatEntry struct {
+	Response *models.ChatResponse `json:"response"`
+	Chunks   []string             `json:"chunks,omitempty"`
+}
+
+func (c *LLM) Chat(ctx context.Context, r *models.ChatRequest, stream ...func(chunk []byte) error) (*models.ChatResponse, error) {
+	keyed := *r
+	keyed.Stream = false
+	var entry chatEntry
+	callback := streamCallback(stream)
+	key, hit := c.lookup(ctx, "Chat", &keyed, &entry)
+	if hit {
+		if err := replay(entry.Chunks, entry.Response.Content, callback); err != nil {
+			return nil, err
+		}
+		return entry.Response, nil
+	}
+	recorded, record := recorder(callback)
+	resp, err := c.llm.Chat(ctx, r, recorded...)
+	if err != nil {
+		return nil, err
+	}
+	c.store(key, chatEntry{Response: resp, Chunks: record()})
+	return resp, nil
+}
+
+func (c *LLM) Embeddings(ctx context.Context, r *models.EmbeddingsRequest) (*models.EmbeddingsResponse, error) {
+	var entry models.EmbeddingsResponse
+	key, hit := c.lookup(ctx, "Embeddings", r, &entry)
+	if hit {
+		return &entry, nil
+	}
+	resp, err := c.llm.Embeddings(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	c.store(key, resp)
+	return resp, nil
+}
+
+// BatchEmbeddings caches each input separately under the same key as the
+// equivalent Embeddings request, and sends only the misses to the wrapped
+// LLM, so an interrupted ingestion run resumes where it stopped.
+func (c *LLM) BatchEmbeddings(ctx context.Context, r *models.BatchEmbeddingsRequest) (*models.BatchEmbeddingsResponse, error) {
+	result := &models.BatchEmbeddingsResponse{Embeddings: make([][]float32, len(r.Inputs))}
+	keys := make([]string, len(r.Inputs))
+	var missing []int
+	for i, input := range r.Inputs {
+		var entry models.EmbeddingsResponse
+		key, hit := c.lookup(ctx, "Embeddings", &models.EmbeddingsRequest{
+			Model:      r.Model,
+			Dimensions: r.Dimensions,
+			Content:    input,
+		}, &entry)
+		if hit {
+			result.Embeddings[i] = entry.Embeddings
+			continue
+		}
+		keys[i] = key
+		missing = append(missing, i)
+	}
+	if len(missing) == 0 {
+		return result, nil
+	}
+
+	inner := *r
+	inner.Inputs = make([]string, len(missing))
+	for j, i := range missing {
+		inner.Inputs[j] = r.Inputs[i]
+	}
+	resp, err := c.llm.BatchEmbeddings(ctx, &inner)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Embeddings) != len(missing) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(missing), len(resp.Embeddings))
+	}
+	for j, i := range missing {
+		result.Embeddings[i] = resp.Embeddings[j]
+		c.store(keys[i], &models.EmbeddingsResponse{Embeddings: resp.Embeddings[j]})
+	}
+	return result, nil
+}
+
+// lookup derives the key for request and decodes a cached entry into entry.
+// The returned key is empty when the request must not be cached.
+func (c *LLM) lookup(ctx context.Context, operation string, request, entry any) (string, bool) {
+	if flag(ctx, bypassKey) {
+		return "", false
+	}
+	key, err := c.Key(operation, request)
+	if err != nil {
+		slog.Warn("cache: failed to derive key", "operation", operation, "error", err)
+		return "", false
+	}
+	if flag(ctx, refreshKey) {
+		c.misses.Add(1)
+		return key, false
+	}
+	data, ok, err := c.config.Store.Get(key)
+	if err != nil {
+		slog.Warn("cache: lookup failed", "operation", operation, "error", err)
+	}
+	if ok && json.Unmarshal(data, entry) == nil {
+		c.hits.Add(1)
+		return key, true
+	}
+	c.misses.Add(1)
+	return key, false
+}
+
+// store saves value under key. Failures are logged rather than returned: a
+// cache that cannot write should not fail the call it is caching.
+func (c *LLM) store(key string, value any) {
+	if key == "" {
+		return
+	}
+	data, err := json.Marshal(value)
+	if err == nil {
+		err = c.config.Store.Set(key, data, c.config.TTL)
+	}
+	if err != nil {
+		slog.Warn("cache: store failed", "error", err)
+	}
+}
+
+func streamCallback(stream []func(chunk []byte) error) func(chunk []byte) error {
+	if len(stream) > 0 {
+		return stream[0]
+	}
+	return nil
+}
+
+// recorder wraps callback so the chunks it receives can be stored and
+// replayed later.
+func recorder(callback func(chunk []byte) error) ([]func(chunk []byte) error, func() []string) {
+	if callback == nil {
+		return nil, func() []string { return nil }
+	}
+	var chunks []string
+	return []func(chunk []byte) error{func(chunk []byte) error {
+		chunks = append(chunks, string(chunk))
+		return callback(chunk)
+	}}, func() []string { return chunks }
+}
+
+// replay sends recorded chunks to callback, or the whole text as one chunk
+// when the cached call was not streamed.
+func replay(chunks []string, text string, callback func(chunk []byte) error) error {
+	if callback == nil {
+		return nil
+	}
+	if chunks == nil && text != "" {
+		chunks = []string{text}
+	}
+	for _, chunk := range chunks {
+		if err := callback([]byte(chunk)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,216 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/aqua777/ai-flow/llm/models"
+	mocks "github.com/aqua777/ai-flow/mocks/llm"
+)
+
+// countingLLM streams its answer in two chunks and counts calls.
+type countingLLM struct {
+	mocks.MockLLM
+	chatCalls  int
+	batchCalls [][]string
+	err        error
+}
+
+func (c *countingLLM) Chat(ctx context.Context, r *models.ChatRequest, stream ...func(chunk []byte) error) (*models.ChatResponse, error) {
+	c.chatCalls++
+	if c.err != nil {
+		return nil, c.err
+	}
+	if len(stream) > 0 && stream[0] != nil {
+		for _, chunk := range []string{"Hello", " world"} {
+			if err := stream[0]([]byte(chunk)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return &models.ChatResponse{Content: "Hello world", Metadata: &models.ChatResponseMetadata{TotalTokens: 3}}, nil
+}
+
+func (c *countingLLM) BatchEmbeddings(ctx context.Context, r *models.BatchEmbeddingsRequest) (*models.BatchEmbeddingsResponse, error) {
+	c.batchCalls = append(c.batchCalls, r.Inputs)
+	return c.MockLLM.BatchEmbeddings(ctx, r)
+}
+
+type CacheTestSuite struct {
+	suite.Suite
+	inner *countingLLM
+	cache *LLM
+}
+
+func TestCacheTestSuite(t *testing.T) {
+	suite.Run(t, new(CacheTestSuite))
+}
+
+func (s *CacheTestSuite) SetupTest() {
+	s.inner = &countingLLM{}
+	s.cache = New(s.inner, nil)
+}
+
+func request(content string) *models.ChatRequest {
+	return &models.ChatRequest{
+		Model:    "m",
+		Messages: []*models.Message{{Role: models.UserRole, Content: content}},
+		Tools:    []*models.Tool{{Name: "t", Parameters: map[string]any{"b": 1, "a": 2}}},
+		Options:  models.RequestOptions{Temperature: 0.5},
+	}
+}
+
+func (s *CacheTestSuite) TestKeyIsCanonical() {
+	k1, err := s.cache.Key("Chat", request("hi"))
+	s.Require().NoError(err)
+	k2, _ := s.cache.Key("Chat", request("hi"))
+	s.Equal(k1, k2)
+	k3, _ := s.cache.Key("Chat", request("hello"))
+	s.NotEqual(k1, k3)
+	k4, _ := s.cache.Key("Generate", request("hi"))
+	s.NotEqual(k1, k4)
+
+	other := request("hi")
+	other.Options.Temperature = 0.6
+	k5, _ := s.cache.Key("Chat", other)
+	s.NotEqual(k1, k5)
+
+	namespaced := New(s.inner, &Config{Namespace: "openai"})
+	k6, _ := namespaced.Key("Chat", request("hi"))
+	s.NotEqual(k1, k6)
+}
+
+func (s *CacheTestSuite) TestChatHit() {
+	first, err := s.cache.Chat(context.Background(), request("hi"))
+	s.Require().NoError(err)
+	second, err := s.cache.Chat(context.Background(), request("hi"))
+	s.Require().NoError(err)
+	s.Equal(1, s.inner.chatCalls)
+	s.Equal(first, second)
+	s.Equal(Stats{Hits: 1, Misses: 1}, s.cache.Stats())
+}
+
+func (s *CacheTestSuite) TestStreamReplay() {
+	var chunks []string
+	callback := func(chunk []byte) error {
+		chunks = append(chunks, string(chunk))
+		return nil
+	}
+	_, err := s.cache.Chat(context.Background(), request("hi"), callback)
+	s.Require().NoError(err)
+	resp, err := s.cache.Chat(context.Background(), request("hi"), callback)
+	s.Require().NoError(err)
+	s.Equal(1, s.inner.chatCalls)
+	s.Equal("Hello world", resp.Content)
+	s.Equal([]string{"Hello", " world", "Hello", " world"}, chunks)
+
+	// A cached non-streamed answer replays as a single chunk.
+	chunks = nil
+	_, err = s.cache.Chat(context.Background(), request("other"))
+	s.Require().NoError(err)
+	_, err = s.cache.Chat(context.Background(), request("other"), callback)
+	s.Require().NoError(err)
+	s.Equal([]string{"Hello world"}, chunks)
+}
+
+func (s *CacheTestSuite) TestErrorsAreNotCached() {
+	s.inner.err = errors.New("boom")
+	_, err := s.cache.Chat(context.Background(), request("hi"))
+	s.Error(err)
+	s.inner.err = nil
+	_, err = s.cache.Chat(context.Background(), request("hi"))
+	s.Require().NoError(err)
+	s.Equal(2, s.inner.chatCalls)
+}
+
+func (s *CacheTestSuite) TestBypassAndRefresh() {
+	ctx := context.Background()
+	_, _ = s.cache.Chat(WithBypass(ctx), request("hi"))
+	_, _ = s.cache.Chat(WithBypass(ctx), request("hi"))
+	s.Equal(2, s.inner.chatCalls)
+
+	_, _ = s.cache.Chat(ctx, request("hi"))
+	s.Equal(3, s.inner.chatCalls, "bypassed calls are not stored")
+	_, _ = s.cache.Chat(WithRefresh(ctx), request("hi"))
+	s.Equal(4, s.inner.chatCalls)
+	_, _ = s.cache.Chat(ctx, request("hi"))
+	s.Equal(4, s.inner.chatCalls)
+}
+
+func (s *CacheTestSuite) TestBatchEmbeddingsPerInput() {
+	ctx := context.Background()
+	_, err := s.cache.Embeddings(ctx, &models.EmbeddingsRequest{Model: "e", Content: "b"})
+	s.Require().NoError(err)
+	resp, err := s.cache.BatchEmbeddings(ctx, &models.BatchEmbeddingsRequest{Model: "e", Inputs: []string{"a", "b", "c"}})
+	s.Require().NoError(err)
+	s.Len(resp.Embeddings, 3)
+	s.Equal([][]string{{"a", "c"}}, s.inner.batchCalls)
+
+	_, err = s.cache.BatchEmbeddings(ctx, &models.BatchEmbeddingsRequest{Model: "e", Inputs: []string{"c", "a"}})
+	s.Require().NoError(err)
+	s.Len(s.inner.batchCalls, 1)
+}
+
+func (s *CacheTestSuite) TestTTL() {
+	store := NewMemoryStore(10)
+	now := time.Now()
+	store.now = func() time.Time { return now }
+	s.cache = New(s.inner, &Config{Store: store, TTL: time.Minute})
+	_, _ = s.cache.Chat(context.Background(), request("hi"))
+	now = now.Add(59 * time.Second)
+	_, _ = s.cache.Chat(context.Background(), request("hi"))
+	s.Equal(1, s.inner.chatCalls)
+	now = now.Add(time.Second)
+	_, _ = s.cache.Chat(context.Background(), request("hi"))
+	s.Equal(2, s.inner.chatCalls)
+}
+
+func (s *CacheTestSuite) TestMemoryStoreLRU() {
+	store := NewMemoryStore(2)
+	s.Require().NoError(store.Set("a", []byte("1"), 0))
+	s.Require().NoError(store.Set("b", []byte("2"), 0))
+	_, ok, _ := store.Get("a")
+	s.True(ok)
+	s.Require().NoError(store.Set("c", []byte("3"), 0))
+	_, ok, _ = store.Get("b")
+	s.False(ok, "least recently used entry is evicted")
+	_, ok, _ = store.Get("a")
+	s.True(ok)
+	s.Equal(2, store.Len())
+	s.Require().NoError(store.Delete("a"))
+	s.Equal(1, store.Len())
+}
+
+func (s *CacheTestSuite) TestDiskStore() {
+	dir := s.T().TempDir()
+	store, err := NewDiskStore(dir)
+	s.Require().NoError(err)
+	s.cache = New(s.inner, &Config{Store: store})
+	_, err = s.cache.Chat(context.Background(), request("hi"))
+	s.Require().NoError(err)
+
+	// A new decorator over the same directory sees the entry.
+	reopened, err := NewDiskStore(dir)
+	s.Require().NoError(err)
+	resp, err := New(s.inner, &Config{Store: reopened}).Chat(context.Background(), request("hi"))
+	s.Require().NoError(err)
+	s.Equal("Hello world", resp.Content)
+	s.Equal(1, s.inner.chatCalls)
+
+	now := time.Now()
+	store.now = func() time.Time { return now }
+	s.Require().NoError(store.Set("abcd", []byte(`"x"`), time.Second))
+	s.FileExists(filepath.Join(dir, "ab", "abcd.json"))
+	now = now.Add(time.Second)
+	_, ok, err := store.Get("abcd")
+	s.Require().NoError(err)
+	s.False(ok)
+	_, statErr := os.Stat(filepath.Join(dir, "ab", "abcd.json"))
+	s.True(os.IsNotExist(statErr), "expired entries are removed")
+}
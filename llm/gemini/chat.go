@@ -0,0 +1,89 @@
+package gemini
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/aqua777/ai-flow/llm/models"
+)
+
+var dataPrefix = []byte("data:")
+
+func (c *Client) Chat(ctx context.Context, r *models.ChatRequest, stream ...func(chunk []byte) error) (*models.ChatResponse, error) {
+	req, err := toGenerateContentRequest(r)
+	if err != nil {
+		return nil, err
+	}
+	body, err := models.MergeExtra(req, r.Options.ExtraFor(models.GEMINI))
+	if err != nil {
+		return nil, err
+	}
+	if len(stream) > 0 && stream[0] != nil {
+		return c.streamChat(ctx, r.Model, body, stream[0])
+	}
+
+	var resp GenerateContentResponse
+	if err := c.client.Post(ctx, modelPath(r.Model)+":generateContent", body, &resp, c.headers()); err != nil {
+		return nil, err
+	}
+	acc := &responseAccumulator{}
+	if _, err := acc.add(&resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Candidates) == 0 {
+		return nil, errNoCandidates
+	}
+	return acc.response(), nil
+}
+
+// streamChat reads the server-sent events of streamGenerateContent; each
+// event is a complete GenerateContentResponse holding the next parts.
+func (c *Client) streamChat(ctx context.Context, model string, body any, callback func(chunk []byte) error) (*models.ChatResponse, error) {
+	acc := &responseAccumulator{}
+	err := c.client.PostStream(ctx, modelPath(model)+":streamGenerateContent?alt=sse", body, c.headers(), func(line []byte) error {
+		if !bytes.HasPrefix(line, dataPrefix) {
+			return nil
+		}
+		var chunk GenerateContentResponse
+		if err := json.Unmarshal(bytes.TrimSpace(line[len(dataPrefix):]), &chunk); err != nil {
+			return fmt.Errorf("failed to decode stream chunk: %w", err)
+		}
+		text, err := acc.add(&chunk)
+		if err != nil {
+			return err
+		}
+		if text != "" {
+			return callback([]byte(text))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if acc.finishReason == "" {
+		return nil, errIncomplete
+	}
+	return acc.response(), nil
+}
+
+func (c *Client) Generate(ctx context.Context, r *models.GenerateRequest, stream ...func(chunk []byte) error) (*models.GenerateResponse, error) {
+	resp, err := c.Chat(ctx, &models.ChatRequest{
+		Model:          r.Model,
+		Messages:       []*models.Message{{Role: models.UserRole, Content: r.Prompt}},
+		ResponseFormat: r.ResponseFormat,
+		Options:        r.Options,
+	}, stream...)
+	if err != nil {
+		return nil, err
+	}
+	return &models.GenerateResponse{
+		Text:             resp.Content,
+		Model:            r.Model,
+		PromptTokens:     resp.Metadata.PromptTokens,
+		CompletionTokens: resp.Metadata.CompletionTokens,
+		TotalTokens:      resp.Metadata.TotalTokens,
+		FinishReason:     resp.FinishReason,
+	}, nil
+}
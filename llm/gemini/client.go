@@ -0,0 +1,121 @@
+package gemini
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/aqua777/ai-flow/http"
+	"github.com/aqua777/ai-flow/llm/iface"
+	"github.com/aqua777/ai-flow/llm/models"
+)
+
+const (
+	apiKeyHeader = "x-goog-api-key"
+	modelPrefix  = "models/"
+)
+
+type Client struct {
+	config *models.LLMConfig
+	client *http.JsonClient
+}
+
+// Ensure Client implements iface.LLM
+var (
+	_ iface.LLM         = (*Client)(nil)
+	_ iface.ModelGetter = (*Client)(nil)
+)
+
+func NewClient(optionalConfig ...*models.LLMConfig) (*Client, error) {
+	config := models.OptionalConfig(optionalConfig).GetConfig(models.GEMINI)
+	client, err := http.NewJsonClient(config.Url)
+	if err != nil {
+		return nil, err
+	}
+	client.ErrorMapper = toProviderError
+	return &Client{
+		config: config,
+		client: client,
+	}, nil
+}
+
+// headers returns a fresh header map per call; JsonClient writes into it.
+func (c *Client) headers() map[string]string {
+	return map[string]string{
+		apiKeyHeader: c.config.ApiKey,
+	}
+}
+
+// modelPath turns "gemini-2.0-flash" or "models/gemini-2.0-flash" into the
+// "/models/gemini-2.0-flash" resource path.
+func modelPath(model string) string {
+	return "/" + modelPrefix + strings.TrimPrefix(model, modelPrefix)
+}
+
+type GeminiModel struct {
+	Name                       string   `json:"name"`
+	BaseModelID                string   `json:"baseModelId"`
+	Version                    string   `json:"version"`
+	DisplayName                string   `json:"displayName"`
+	Description                string   `json:"description"`
+	InputTokenLimit            int      `json:"inputTokenLimit"`
+	OutputTokenLimit           int      `json:"outputTokenLimit"`
+	SupportedGenerationMethods []string `json:"supportedGenerationMethods"`
+	Thinking                   bool     `json:"thinking"`
+}
+
+func (m *GeminiModel) toModel() *models.Model {
+	id := strings.TrimPrefix(m.Name, modelPrefix)
+	result := &models.Model{
+		ID:          id,
+		Name:        m.DisplayName,
+		Model:       id,
+		Description: m.Description,
+		ContextSize: m.InputTokenLimit,
+		Family:      m.BaseModelID,
+	}
+	for _, method := range m.SupportedGenerationMethods {
+		switch method {
+		case "generateContent":
+			result.Capabilities = append(result.Capabilities, models.CapabilityCompletion)
+		case "embedContent":
+			result.Capabilities = append(result.Capabilities, models.CapabilityEmbedding)
+		}
+	}
+	if m.Thinking {
+		result.Capabilities = append(result.Capabilities, models.CapabilityThinking)
+	}
+	return result
+}
+
+type ListModelsResponse struct {
+	Models        []*GeminiModel `json:"models"`
+	NextPageToken string         `json:"nextPageToken"`
+}
+
+func (c *Client) ListModels(ctx context.Context) ([]*models.Model, error) {
+	var results []*models.Model
+	path := "/models?pageSize=1000"
+	for {
+		var response ListModelsResponse
+		if err := c.client.Get(ctx, path, &response, c.headers()); err != nil {
+			return nil, err
+		}
+		for _, m := range response.Models {
+			results = append(results, m.toModel())
+		}
+		if response.NextPageToken == "" {
+			return results, nil
+		}
+		path = fmt.Sprintf("/models?pageSize=1000&pageToken=%s", url.QueryEscape(response.NextPageToken))
+	}
+}
+
+func (c *Client) GetModel(ctx context.Context, name string) (*models.Model, error) {
+	var response GeminiModel
+	if err := c.client.Get(ctx, modelPath(name), &response, c.headers()); err != nil {
+		return nil, err
+	}
+	return response.toModel(), nil
+}
@@ -0,0 +1,294 @@
+package gemini
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/aqua777/ai-flow/llm/models"
+)
+
+type ClientTestSuite struct {
+	suite.Suite
+	server  *httptest.Server
+	handler http.HandlerFunc
+	lastReq *GenerateContentRequest
+	path    string
+	headers http.Header
+	client  *Client
+}
+
+func TestClientTestSuite(t *testing.T) {
+	suite.Run(t, new(ClientTestSuite))
+}
+
+func (s *ClientTestSuite) SetupTest() {
+	s.lastReq = nil
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.headers = r.Header.Clone()
+		s.path = r.URL.Path
+		if r.Method == http.MethodPost {
+			s.lastReq = new(GenerateContentRequest)
+			s.Require().NoError(json.NewDecoder(r.Body).Decode(s.lastReq))
+		}
+		s.handler(w, r)
+	}))
+	client, err := NewClient(&models.LLMConfig{Url: s.server.URL + "/v1beta", ApiKey: "secret"})
+	s.Require().NoError(err)
+	s.client = client
+}
+
+func (s *ClientTestSuite) TearDownTest() {
+	s.server.Close()
+}
+
+func (s *ClientTestSuite) TestChat() {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"candidates":[{"finishReason":"STOP","content":{"role":"model","parts":[
+			{"text":"User wants weather.","thought":true},
+			{"text":"Let me check."},
+			{"functionCall":{"name":"get_weather","args":{"city":"Paris"}}}]}}],
+			"usageMetadata":{"promptTokenCount":20,"candidatesTokenCount":6,"thoughtsTokenCount":2,"totalTokenCount":28}}`)
+	}
+	call := &models.ToolCall{ID: "call_0", Name: "get_time", Arguments: `{}`}
+	resp, err := s.client.Chat(context.Background(), &models.ChatRequest{
+		Model: "gemini-2.0-flash",
+		Messages: []*models.Message{
+			{Role: models.SystemRole, Content: "Be brief."},
+			{Role: models.UserRole, Content: "Weather in Paris?", Images: []*models.Image{models.NewImage([]byte("png"), "image/png")}},
+			{Role: models.AssistantRole, ToolCalls: []*models.ToolCall{call}},
+			models.NewToolResultMessage(call, "12:00"),
+			{Role: models.UserRole, Content: "And the weather?"},
+		},
+		Tools:          []*models.Tool{{Name: "get_weather", Parameters: map[string]any{"type": "object"}}},
+		ResponseFormat: &models.ResponseFormat{Type: models.ResponseFormatJSON},
+		Options:        models.RequestOptions{Temperature: 0.2, MaxTokens: 64},
+	})
+	s.Require().NoError(err)
+	s.Equal("Let me check.", resp.Content)
+	s.Equal("User wants weather.", resp.Reasoning)
+	s.Require().Len(resp.ToolCalls, 1)
+	s.Equal("get_weather", resp.ToolCalls[0].Name)
+	s.JSONEq(`{"city":"Paris"}`, resp.ToolCalls[0].Arguments)
+	s.Equal(&models.ChatResponseMetadata{PromptTokens: 20, CompletionTokens: 8, TotalTokens: 28}, resp.Metadata)
+	s.Equal(models.FinishReasonToolCalls, resp.FinishReason)
+
+	s.Equal("/v1beta/models/gemini-2.0-flash:generateContent", s.path)
+	s.Equal("secret", s.headers.Get("x-goog-api-key"))
+	s.Equal("Be brief.", s.lastReq.SystemInstruction.Parts[0].Text)
+	s.Equal(0.2, *s.lastReq.GenerationConfig.Temperature)
+	s.Equal(64, s.lastReq.GenerationConfig.MaxOutputTokens)
+	s.Equal("application/json", s.lastReq.GenerationConfig.ResponseMimeType)
+	s.Equal("get_weather", s.lastReq.Tools[0].FunctionDeclarations[0].Name)
+
+	s.Require().Len(s.lastReq.Contents, 3, "tool result and follow-up user text merge into one user turn")
+	s.Equal("image/png", s.lastReq.Contents[0].Parts[1].InlineData.MimeType)
+	s.Equal("model", s.lastReq.Contents[1].Role)
+	s.Equal("get_time", s.lastReq.Contents[1].Parts[0].FunctionCall.Name)
+	userTurn := s.lastReq.Contents[2]
+	s.Equal("user", userTurn.Role)
+	s.Equal("get_time", userTurn.Parts[0].FunctionResponse.Name)
+	s.Equal(map[string]any{"content": "12:00"}, userTurn.Parts[0].FunctionResponse.Response)
+	s.Equal("And the weather?", userTurn.Parts[1].Text)
+}
+
+func (s *ClientTestSuite) TestChat_Stream() {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		s.Equal("sse", r.URL.Query().Get("alt"))
+		w.Header().Set("Content-Type", "text/event-stream")
+		events := []string{
+			`{"candidates":[{"content":{"role":"model","parts":[{"text":"Hmm.","thought":true}]}}]}`,
+			`{"candidates":[{"content":{"role":"model","parts":[{"text":"Hello"}]}}]}`,
+			`{"candidates":[{"content":{"role":"model","parts":[{"text":" there"}]},"finishReason":"STOP"}],"usageMetadata":{"promptTokenCount":12,"candidatesTokenCount":15,"totalTokenCount":27}}`,
+		}
+		for _, event := range events {
+			fmt.Fprintf(w, "data: %s\n\n", event)
+		}
+	}
+	var chunks []string
+	resp, err := s.client.Chat(context.Background(), &models.ChatRequest{
+		Model:    "gemini-2.0-flash",
+		Messages: []*models.Message{{Role: models.UserRole, Content: "hi"}},
+	}, func(chunk []byte) error {
+		chunks = append(chunks, string(chunk))
+		return nil
+	})
+	s.Require().NoError(err)
+	s.Equal("/v1beta/models/gemini-2.0-flash:streamGenerateContent", s.path)
+	s.Equal([]string{"Hello", " there"}, chunks)
+	s.Equal("Hello there", resp.Content)
+	s.Equal("Hmm.", resp.Reasoning)
+	s.Equal(&models.ChatResponseMetadata{PromptTokens: 12, CompletionTokens: 15, TotalTokens: 27}, resp.Metadata)
+	s.Equal(models.FinishReasonStop, resp.FinishReason)
+}
+
+func (s *ClientTestSuite) TestChat_StreamTruncated() {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"candidates\":[{\"content\":{\"role\":\"model\",\"parts\":[{\"text\":\"Hel\"}]}}]}\n\n")
+	}
+	var chunks []string
+	_, err := s.client.Chat(context.Background(), &models.ChatRequest{
+		Model:    "gemini-2.0-flash",
+		Messages: []*models.Message{{Role: models.UserRole, Content: "hi"}},
+	}, func(chunk []byte) error {
+		chunks = append(chunks, string(chunk))
+		return nil
+	})
+	s.ErrorIs(err, errIncomplete)
+	s.Equal([]string{"Hel"}, chunks)
+}
+
+func (s *ClientTestSuite) TestChat_Blocked() {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"prompt", `{"promptFeedback":{"blockReason":"SAFETY","safetyRatings":[{"category":"HARM_CATEGORY_HARASSMENT","probability":"HIGH"}]}}`},
+		{"candidate", `{"candidates":[{"finishReason":"RECITATION"}]}`},
+	}
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			s.handler = func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, tt.body)
+			}
+			_, err := s.client.Chat(context.Background(), &models.ChatRequest{
+				Model:    "gemini-2.0-flash",
+				Messages: []*models.Message{{Role: models.UserRole, Content: "hi"}},
+			})
+			s.ErrorIs(err, models.ErrContentFiltered)
+			var blocked *BlockedError
+			s.Require().ErrorAs(err, &blocked)
+			s.NotEmpty(blocked.Reason)
+		})
+	}
+}
+
+func (s *ClientTestSuite) TestChat_Images() {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"candidates":[{"content":{"parts":[{"text":"A cat."}]},"finishReason":"STOP"}]}`)
+	}
+	image := &models.Image{Data: []byte("\x89PNG\r\n\x1a\n")}
+	_, err := s.client.Chat(context.Background(), &models.ChatRequest{
+		Model:    "gemini-2.0-flash",
+		Messages: []*models.Message{{Role: models.UserRole, Content: "What is this?", Images: []*models.Image{image}}},
+	})
+	s.Require().NoError(err)
+	s.Equal("image/png", s.lastReq.Contents[0].Parts[1].InlineData.MimeType, "MIME type is sniffed when empty")
+
+	s.lastReq = nil
+	_, err = s.client.Chat(context.Background(), &models.ChatRequest{
+		Model:    "gemini-2.0-flash",
+		Messages: []*models.Message{{Role: models.UserRole, Content: "What is this?", Images: []*models.Image{{}}}},
+	})
+	s.ErrorIs(err, models.ErrInvalidImage)
+	s.Nil(s.lastReq, "invalid image must not be sent")
+
+	images := []*models.Image{
+		models.NewImageURL("https://example.com/cat.jpg?size=large"),
+		{URL: "gs://bucket/cat", MIMEType: "image/webp"},
+		models.NewImageURL("data:image/gif;base64,R0lGOD=="),
+	}
+	_, err = s.client.Chat(context.Background(), &models.ChatRequest{
+		Model:    "gemini-2.0-flash",
+		Messages: []*models.Message{{Role: models.UserRole, Content: "What is this?", Images: images}},
+	})
+	s.Require().NoError(err)
+	parts := s.lastReq.Contents[0].Parts
+	s.Equal(&FileData{MimeType: "image/jpeg", FileURI: "https://example.com/cat.jpg?size=large"}, parts[1].FileData)
+	s.Equal(&FileData{MimeType: "image/webp", FileURI: "gs://bucket/cat"}, parts[2].FileData)
+	s.Equal(&Blob{MimeType: "image/gif", Data: "R0lGOD=="}, parts[3].InlineData, "data URLs are sent inline")
+
+	s.lastReq = nil
+	_, err = s.client.Chat(context.Background(), &models.ChatRequest{
+		Model:    "gemini-2.0-flash",
+		Messages: []*models.Message{{Role: models.UserRole, Content: "What is this?", Images: []*models.Image{models.NewImageURL("https://example.com/cat")}}},
+	})
+	s.ErrorIs(err, models.ErrInvalidImage, "URL images need a known MIME type")
+	s.Nil(s.lastReq)
+}
+
+func (s *ClientTestSuite) TestGenerate_Schema() {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"candidates":[{"content":{"parts":[{"text":"{\"a\":1}"}]},"finishReason":"STOP"}]}`)
+	}
+	resp, err := s.client.Generate(context.Background(), &models.GenerateRequest{
+		Model:          "gemini-2.0-flash",
+		Prompt:         "give me a",
+		ResponseFormat: models.JSONSchemaFormat("thing", map[string]any{"type": "object"}),
+	})
+	s.Require().NoError(err)
+	s.Equal(`{"a":1}`, resp.Text)
+	s.Equal(map[string]any{"type": "object"}, s.lastReq.GenerationConfig.ResponseJsonSchema)
+}
+
+func (s *ClientTestSuite) TestListModels() {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		s.Equal("/v1beta/models", r.URL.Path)
+		if r.URL.Query().Get("pageToken") == "" {
+			fmt.Fprint(w, `{"models":[{"name":"models/gemini-a","displayName":"Gemini A","inputTokenLimit":1000,"supportedGenerationMethods":["generateContent","countTokens"],"thinking":true}],"nextPageToken":"next"}`)
+			return
+		}
+		fmt.Fprint(w, `{"models":[{"name":"models/gemini-b","displayName":"Gemini B"}]}`)
+	}
+	result, err := s.client.ListModels(context.Background())
+	s.Require().NoError(err)
+	s.Require().Len(result, 2)
+	s.Equal("gemini-a", result[0].ID)
+	s.Equal(1000, result[0].ContextSize)
+	s.Equal([]models.Capability{models.CapabilityCompletion, models.CapabilityThinking}, result[0].Capabilities)
+	s.Equal("Gemini B", result[1].Name)
+}
+
+func (s *ClientTestSuite) TestGetModel() {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		s.Equal("/v1beta/models/text-embedding-004", r.URL.Path)
+		fmt.Fprint(w, `{"name":"models/text-embedding-004","displayName":"Text Embedding 004","inputTokenLimit":2048,"supportedGenerationMethods":["embedContent"]}`)
+	}
+	m, err := s.client.GetModel(context.Background(), "text-embedding-004")
+	s.Require().NoError(err)
+	s.Equal("text-embedding-004", m.ID)
+	s.True(m.HasCapability(models.CapabilityEmbedding))
+	s.False(m.HasCapability(models.CapabilityCompletion))
+}
+
+func (s *ClientTestSuite) TestEmbeddings() {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"embedding":{"values":[0.1,0.2]}}`)
+	}
+	resp, err := s.client.Embeddings(context.Background(), &models.EmbeddingsRequest{Model: "text-embedding-004", Content: "x"})
+	s.Require().NoError(err)
+	s.Equal("/v1beta/models/text-embedding-004:embedContent", s.path)
+	s.Equal([]float32{0.1, 0.2}, resp.Embeddings)
+}
+
+func (s *ClientTestSuite) TestBatchEmbeddings() {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"embeddings":[{"values":[1]},{"values":[2]}]}`)
+	}
+	resp, err := s.client.BatchEmbeddings(context.Background(), &models.BatchEmbeddingsRequest{
+		Model:  "text-embedding-004",
+		Inputs: []string{"a", "b"},
+	})
+	s.Require().NoError(err)
+	s.Equal("/v1beta/models/text-embedding-004:batchEmbedContents", s.path)
+	s.Equal([][]float32{{1}, {2}}, resp.Embeddings)
+}
+
+func (s *ClientTestSuite) TestChat_Errors() {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+		fmt.Fprint(w, `{"error":{"code":429,"message":"Quota exceeded","status":"RESOURCE_EXHAUSTED"}}`)
+	}
+	_, err := s.client.Chat(context.Background(), &models.ChatRequest{Model: "gemini-2.0-flash"})
+	s.ErrorIs(err, models.ErrRateLimited)
+	var providerErr *models.ProviderError
+	s.Require().ErrorAs(err, &providerErr)
+	s.Equal("RESOURCE_EXHAUSTED", providerErr.Code)
+	s.Equal("Quota exceeded", providerErr.Message)
+}
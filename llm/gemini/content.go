@@ -0,0 +1,402 @@
+package gemini
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/aqua777/ai-flow/llm/models"
+)
+
+const modelRole = "model"
+
+var (
+	errNoCandidates = errors.New("no candidates returned")
+	errIncomplete   = errors.New("stream ended without a finish reason")
+)
+
+type Blob struct {
+	MimeType string `json:"mimeType"`
+	Data     string `json:"data"`
+}
+
+type FileData struct {
+	MimeType string `json:"mimeType,omitempty"`
+	FileURI  string `json:"fileUri"`
+}
+
+type FunctionCall struct {
+	ID   string          `json:"id,omitempty"`
+	Name string          `json:"name"`
+	Args json.RawMessage `json:"args,omitempty"`
+}
+
+type FunctionResponse struct {
+	ID       string `json:"id,omitempty"`
+	Name     string `json:"name"`
+	Response any    `json:"response"`
+}
+
+type Part struct {
+	Text             string            `json:"text,omitempty"`
+	Thought          bool              `json:"thought,omitempty"`
+	InlineData       *Blob             `json:"inlineData,omitempty"`
+	FileData         *FileData         `json:"fileData,omitempty"`
+	FunctionCall     *FunctionCall     `json:"functionCall,omitempty"`
+	FunctionResponse *FunctionResponse `json:"functionResponse,omitempty"`
+}
+
+type Content struct {
+	Role  string  `json:"role,omitempty"`
+	Parts []*Part `json:"parts"`
+}
+
+type FunctionDeclaration struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Parameters  any    `json:"parametersJsonSchema,omitempty"`
+}
+
+type Tool struct {
+	FunctionDeclarations []*FunctionDeclaration `json:"functionDeclarations"`
+}
+
+type GenerationConfig struct {
+	Temperature        *float64 `json:"temperature,omitempty"`
+	TopP               *float64 `json:"topP,omitempty"`
+	TopK               int      `json:"topK,omitempty"`
+	MaxOutputTokens    int      `json:"maxOutputTokens,omitempty"`
+	StopSequences      []string `json:"stopSequences,omitempty"`
+	Seed               *int     `json:"seed,omitempty"`
+	PresencePenalty    *float64 `json:"presencePenalty,omitempty"`
+	FrequencyPenalty   *float64 `json:"frequencyPenalty,omitempty"`
+	ResponseMimeType   string   `json:"responseMimeType,omitempty"`
+	ResponseJsonSchema any      `json:"responseJsonSchema,omitempty"`
+}
+
+type GenerateContentRequest struct {
+	Contents          []*Content        `json:"contents"`
+	SystemInstruction *Content          `json:"systemInstruction,omitempty"`
+	Tools             []*Tool           `json:"tools,omitempty"`
+	GenerationConfig  *GenerationConfig `json:"generationConfig,omitempty"`
+}
+
+type SafetyRating struct {
+	Category    string `json:"category"`
+	Probability string `json:"probability"`
+	Blocked     bool   `json:"blocked,omitempty"`
+}
+
+type Candidate struct {
+	Content       *Content        `json:"content"`
+	FinishReason  string          `json:"finishReason"`
+	SafetyRatings []*SafetyRating `json:"safetyRatings,omitempty"`
+}
+
+type PromptFeedback struct {
+	BlockReason   string          `json:"blockReason"`
+	SafetyRatings []*SafetyRating `json:"safetyRatings,omitempty"`
+}
+
+type UsageMetadata struct {
+	PromptTokenCount     int `json:"promptTokenCount"`
+	CandidatesTokenCount int `json:"candidatesTokenCount"`
+	ThoughtsTokenCount   int `json:"thoughtsTokenCount"`
+	TotalTokenCount      int `json:"totalTokenCount"`
+}
+
+type GenerateContentResponse struct {
+	Candidates     []*Candidate    `json:"candidates"`
+	PromptFeedback *PromptFeedback `json:"promptFeedback,omitempty"`
+	UsageMetadata  *UsageMetadata  `json:"usageMetadata,omitempty"`
+	ModelVersion   string          `json:"modelVersion,omitempty"`
+}
+
+// BlockedError reports a prompt or candidate stopped by Gemini's safety
+// system. It matches models.ErrContentFiltered with errors.Is.
+type BlockedError struct {
+	Reason  string
+	Ratings []*SafetyRating
+}
+
+func (e *BlockedError) Error() string {
+	var blocked []string
+	for _, rating := range e.Ratings {
+		if rating.Blocked || rating.Probability == "HIGH" || rating.Probability == "MEDIUM" {
+			blocked = append(blocked, fmt.Sprintf("%s=%s", rating.Category, rating.Probability))
+		}
+	}
+	if len(blocked) == 0 {
+		return fmt.Sprintf("%s: %s", models.ErrContentFiltered, e.Reason)
+	}
+	return fmt.Sprintf("%s: %s (%s)", models.ErrContentFiltered, e.Reason, strings.Join(blocked, ", "))
+}
+
+func (e *BlockedError) Unwrap() error {
+	return models.ErrContentFiltered
+}
+
+// blockingFinishReasons are the finish reasons that mean the candidate was
+// withheld rather than completed.
+var blockingFinishReasons = map[string]bool{
+	"SAFETY":             true,
+	"RECITATION":         true,
+	"BLOCKLIST":          true,
+	"PROHIBITED_CONTENT": true,
+	"SPII":               true,
+	"IMAGE_SAFETY":       true,
+}
+
+// checkBlocked returns a BlockedError when the prompt or the first
+// candidate was blocked.
+func checkBlocked(resp *GenerateContentResponse) error {
+	if fb := resp.PromptFeedback; fb != nil && fb.BlockReason != "" {
+		return &BlockedError{Reason: fb.BlockReason, Ratings: fb.SafetyRatings}
+	}
+	if len(resp.Candidates) > 0 {
+		candidate := resp.Candidates[0]
+		if blockingFinishReasons[candidate.FinishReason] {
+			return &BlockedError{Reason: candidate.FinishReason, Ratings: candidate.SafetyRatings}
+		}
+	}
+	return nil
+}
+
+// toGenerateContentRequest maps a chat request onto generateContent. System
+// messages become the system instruction, "assistant" becomes "model" and
+// tool results become user functionResponse parts.
+func toGenerateContentRequest(r *models.ChatRequest) (*GenerateContentRequest, error) {
+	req := &GenerateContentRequest{}
+	var system []*Part
+	for _, msg := range r.Messages {
+		if msg.Role == models.SystemRole {
+			system = append(system, &Part{Text: msg.Content})
+			continue
+		}
+		role, parts, err := toParts(msg)
+		if err != nil {
+			return nil, err
+		}
+		if n := len(req.Contents); n > 0 && req.Contents[n-1].Role == role {
+			req.Contents[n-1].Parts = append(req.Contents[n-1].Parts, parts...)
+			continue
+		}
+		req.Contents = append(req.Contents, &Content{Role: role, Parts: parts})
+	}
+	if len(system) > 0 {
+		req.SystemInstruction = &Content{Parts: system}
+	}
+
+	if len(r.Tools) > 0 {
+		tool := &Tool{}
+		for _, t := range r.Tools {
+			tool.FunctionDeclarations = append(tool.FunctionDeclarations, &FunctionDeclaration{
+				Name:        t.Name,
+				Description: t.Description,
+				Parameters:  t.Parameters,
+			})
+		}
+		req.Tools = []*Tool{tool}
+	}
+
+	config, err := toGenerationConfig(&r.Options, r.ResponseFormat)
+	if err != nil {
+		return nil, err
+	}
+	req.GenerationConfig = config
+	return req, nil
+}
+
+func toParts(msg *models.Message) (string, []*Part, error) {
+	if msg.Role == models.ToolRole {
+		// functionResponse.response must be an object; plain results are wrapped.
+		var response map[string]any
+		if err := json.Unmarshal([]byte(msg.Content), &response); err != nil || response == nil {
+			response = map[string]any{"content": msg.Content}
+		}
+		return string(models.UserRole), []*Part{{
+			FunctionResponse: &FunctionResponse{Name: msg.ToolName, Response: response},
+		}}, nil
+	}
+
+	role := string(msg.Role)
+	if msg.Role == models.AssistantRole {
+		role = modelRole
+	}
+	var parts []*Part
+	if msg.Content != "" {
+		parts = append(parts, &Part{Text: msg.Content})
+	}
+	for _, image := range msg.Images {
+		if err := image.Validate(); err != nil {
+			return "", nil, err
+		}
+		if image.Inline() {
+			data, err := image.Base64()
+			if err != nil {
+				return "", nil, err
+			}
+			parts = append(parts, &Part{InlineData: &Blob{MimeType: image.ContentType(), Data: data}})
+			continue
+		}
+		mimeType := urlContentType(image)
+		if mimeType == "" {
+			return "", nil, fmt.Errorf("%w: cannot determine MIME type of %s; set MIMEType", models.ErrInvalidImage, image.URL)
+		}
+		parts = append(parts, &Part{FileData: &FileData{MimeType: mimeType, FileURI: image.URL}})
+	}
+	for _, call := range msg.ToolCalls {
+		args := json.RawMessage(call.Arguments)
+		if len(args) == 0 {
+			args = json.RawMessage("{}")
+		} else if !json.Valid(args) {
+			return "", nil, fmt.Errorf("tool call %s has invalid JSON arguments", call.Name)
+		}
+		parts = append(parts, &Part{FunctionCall: &FunctionCall{Name: call.Name, Args: args}})
+	}
+	if len(parts) == 0 {
+		parts = append(parts, &Part{Text: msg.Content})
+	}
+	return role, parts, nil
+}
+
+func toGenerationConfig(o *models.RequestOptions, rf *models.ResponseFormat) (*GenerationConfig, error) {
+	config := &GenerationConfig{
+		TopK:            o.TopK,
+		MaxOutputTokens: o.MaxTokens,
+		StopSequences:   o.Stop,
+		Seed:            o.Seed,
+	}
+	if o.Temperature != 0 {
+		config.Temperature = &o.Temperature
+	}
+	if o.TopP != 0 {
+		config.TopP = &o.TopP
+	}
+	if o.PresencePenalty != 0 {
+		config.PresencePenalty = &o.PresencePenalty
+	}
+	if o.FrequencyPenalty != 0 {
+		config.FrequencyPenalty = &o.FrequencyPenalty
+	}
+	if rf != nil {
+		switch rf.Type {
+		case "", models.ResponseFormatText:
+		case models.ResponseFormatJSON:
+			config.ResponseMimeType = "application/json"
+		case models.ResponseFormatJSONSchema:
+			if rf.Schema == nil {
+				return nil, fmt.Errorf("response format %s requires a schema", rf.Type)
+			}
+			config.ResponseMimeType = "application/json"
+			config.ResponseJsonSchema = rf.Schema
+		default:
+			return nil, fmt.Errorf("unsupported response format type: %s", rf.Type)
+		}
+	}
+	return config, nil
+}
+
+// responseAccumulator folds one or more generateContent responses (a single
+// reply or the chunks of a stream) into a chat response.
+// urlContentType returns the MIME type of a URL image, which Gemini requires
+// for fileData parts. An explicit MIMEType wins over the URL's extension.
+func urlContentType(image *models.Image) string {
+	if mimeType := image.ContentType(); mimeType != "" {
+		return mimeType
+	}
+	u, err := url.Parse(image.URL)
+	if err != nil {
+		return ""
+	}
+	mimeType, _, _ := mime.ParseMediaType(mime.TypeByExtension(path.Ext(u.Path)))
+	return mimeType
+}
+
+type responseAccumulator struct {
+	content      strings.Builder
+	reasoning    strings.Builder
+	toolCalls    []*models.ToolCall
+	usage        *UsageMetadata
+	finishReason string
+}
+
+// add folds in a response and returns the answer text it contributed.
+func (a *responseAccumulator) add(resp *GenerateContentResponse) (string, error) {
+	if err := checkBlocked(resp); err != nil {
+		return "", err
+	}
+	if resp.UsageMetadata != nil {
+		a.usage = resp.UsageMetadata
+	}
+	if len(resp.Candidates) > 0 && resp.Candidates[0].FinishReason != "" {
+		a.finishReason = resp.Candidates[0].FinishReason
+	}
+	if len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil {
+		return "", nil
+	}
+	var text strings.Builder
+	for _, part := range resp.Candidates[0].Content.Parts {
+		switch {
+		case part.FunctionCall != nil:
+			args := string(part.FunctionCall.Args)
+			if args == "" || args == "null" {
+				args = "{}"
+			}
+			id := part.FunctionCall.ID
+			if id == "" {
+				id = fmt.Sprintf("call_%d", len(a.toolCalls))
+			}
+			a.toolCalls = append(a.toolCalls, &models.ToolCall{ID: id, Name: part.FunctionCall.Name, Arguments: args})
+		case part.Thought:
+			a.reasoning.WriteString(part.Text)
+		default:
+			text.WriteString(part.Text)
+		}
+	}
+	a.content.WriteString(text.String())
+	return text.String(), nil
+}
+
+func (a *responseAccumulator) response() *models.ChatResponse {
+	resp := &models.ChatResponse{
+		Content:   a.content.String(),
+		Reasoning: a.reasoning.String(),
+		ToolCalls: a.toolCalls,
+	}
+	resp.FinishReason = toFinishReason(a.finishReason, len(a.toolCalls) > 0)
+	if a.usage != nil {
+		completion := a.usage.CandidatesTokenCount + a.usage.ThoughtsTokenCount
+		resp.Metadata = &models.ChatResponseMetadata{
+			PromptTokens:     a.usage.PromptTokenCount,
+			CompletionTokens: completion,
+			TotalTokens:      a.usage.TotalTokenCount,
+		}
+	} else {
+		resp.Metadata = &models.ChatResponseMetadata{}
+	}
+	return resp
+}
+
+// toFinishReason maps a Gemini finishReason onto models.FinishReason. Gemini
+// reports STOP for function calls, so tool calls take precedence.
+func toFinishReason(reason string, hasToolCalls bool) models.FinishReason {
+	switch {
+	case reason == "":
+		return ""
+	case hasToolCalls:
+		return models.FinishReasonToolCalls
+	case reason == "STOP":
+		return models.FinishReasonStop
+	case reason == "MAX_TOKENS":
+		return models.FinishReasonLength
+	case blockingFinishReasons[reason]:
+		return models.FinishReasonContentFilter
+	default:
+		return models.FinishReason(reason)
+	}
+}
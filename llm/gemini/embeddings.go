@@ -0,0 +1,74 @@
+package gemini
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aqua777/ai-flow/llm/batch"
+	"github.com/aqua777/ai-flow/llm/models"
+)
+
+// MaxEmbeddingsBatchSize is the most requests batchEmbedContents accepts per call.
+const MaxEmbeddingsBatchSize = 100
+
+type EmbedContentRequest struct {
+	Model                string   `json:"model,omitempty"`
+	Content              *Content `json:"content"`
+	OutputDimensionality int      `json:"outputDimensionality,omitempty"`
+}
+
+type ContentEmbedding struct {
+	Values []float32 `json:"values"`
+}
+
+type EmbedContentResponse struct {
+	Embedding *ContentEmbedding `json:"embedding"`
+}
+
+type BatchEmbedContentsRequest struct {
+	Requests []*EmbedContentRequest `json:"requests"`
+}
+
+type BatchEmbedContentsResponse struct {
+	Embeddings []*ContentEmbedding `json:"embeddings"`
+}
+
+func (c *Client) Embeddings(ctx context.Context, cr *models.EmbeddingsRequest) (*models.EmbeddingsResponse, error) {
+	req := EmbedContentRequest{
+		Content:              &Content{Parts: []*Part{{Text: cr.Content}}},
+		OutputDimensionality: cr.Dimensions,
+	}
+	var resp EmbedContentResponse
+	if err := c.client.Post(ctx, modelPath(cr.Model)+":embedContent", req, &resp, c.headers()); err != nil {
+		return nil, err
+	}
+	if resp.Embedding == nil {
+		return nil, fmt.Errorf("no embeddings found in the response")
+	}
+	return &models.EmbeddingsResponse{
+		Embeddings: resp.Embedding.Values,
+	}, nil
+}
+
+func (c *Client) BatchEmbeddings(ctx context.Context, r *models.BatchEmbeddingsRequest) (*models.BatchEmbeddingsResponse, error) {
+	path := modelPath(r.Model)
+	return batch.Embed(ctx, r, MaxEmbeddingsBatchSize, func(ctx context.Context, inputs []string) ([][]float32, error) {
+		req := BatchEmbedContentsRequest{Requests: make([]*EmbedContentRequest, len(inputs))}
+		for i, input := range inputs {
+			req.Requests[i] = &EmbedContentRequest{
+				Model:                path[1:],
+				Content:              &Content{Parts: []*Part{{Text: input}}},
+				OutputDimensionality: r.Dimensions,
+			}
+		}
+		var resp BatchEmbedContentsResponse
+		if err := c.client.Post(ctx, path+":batchEmbedContents", req, &resp, c.headers()); err != nil {
+			return nil, err
+		}
+		vectors := make([][]float32, len(resp.Embeddings))
+		for i, embedding := range resp.Embeddings {
+			vectors[i] = embedding.Values
+		}
+		return vectors, nil
+	})
+}
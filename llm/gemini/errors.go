@@ -0,0 +1,31 @@
+package gemini
+
+import (
+	"encoding/json"
+
+	"github.com/aqua777/ai-flow/http"
+	"github.com/aqua777/ai-flow/llm/models"
+)
+
+type errorResponse struct {
+	Error *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Status  string `json:"status"`
+	} `json:"error"`
+}
+
+// toProviderError maps a Gemini error response, whose body is
+// {"error":{"code":429,"message":"...","status":"RESOURCE_EXHAUSTED"}}, onto
+// models.ProviderError.
+func toProviderError(err *http.StatusError) error {
+	code, message := "", string(err.Body)
+	var body errorResponse
+	if json.Unmarshal(err.Body, &body) == nil && body.Error != nil {
+		code, message = body.Error.Status, body.Error.Message
+	}
+	providerErr := models.NewProviderError(models.GEMINI, err.StatusCode, code, message, err)
+	providerErr.Body = err.Body
+	providerErr.RetryAfter = err.RetryAfter
+	return providerErr
+}
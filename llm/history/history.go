@@ -0,0 +1,255 @@
+// Package history fits chat histories into a model's context window by
+// dropping or summarizing the oldest turns.
+package history
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aqua777/ai-flow/llm/models"
+	"github.com/aqua777/ai-flow/textsplitter"
+)
+
+// DefaultMessageOverhead approximates the tokens providers spend on role
+// markers and separators around each message.
+const DefaultMessageOverhead = 4
+
+// ErrBudgetExceeded is returned when the pinned system messages and the latest
+// turn alone do not fit the budget.
+var ErrBudgetExceeded = fmt.Errorf("%w: history cannot be trimmed to fit", models.ErrContextLengthExceeded)
+
+// Counter counts the tokens of chat messages. Images are not counted.
+type Counter struct {
+	Tokenizer       textsplitter.Tokenizer
+	MessageOverhead int
+}
+
+// Count returns the tokens of one message: content, tool calls, tool
+// result ids and the per-message overhead.
+func (c *Counter) Count(m *models.Message) int {
+	n := c.MessageOverhead + c.tokens(m.Content)
+	for _, call := range m.ToolCalls {
+		n += c.tokens(call.Name) + c.tokens(call.Arguments)
+	}
+	if m.ToolCallID != "" {
+		n += c.tokens(m.ToolCallID)
+	}
+	return n
+}
+
+// CountAll returns the tokens of all messages.
+func (c *Counter) CountAll(messages []*models.Message) int {
+	n := 0
+	for _, m := range messages {
+		n += c.Count(m)
+	}
+	return n
+}
+
+func (c *Counter) tokens(text string) int {
+	if text == "" {
+		return 0
+	}
+	return len(c.Tokenizer.Encode(text))
+}
+
+// Budget returns the prompt tokens available on a model: its context size
+// minus the tokens reserved for the answer. It is zero when the context size
+// is unknown.
+func Budget(model *models.Model, maxTokens int) int {
+	if model == nil || model.ContextSize <= 0 {
+		return 0
+	}
+	return max(model.ContextSize-maxTokens, 0)
+}
+
+// Report describes what a trim did.
+type Report struct {
+	// Strategy names the strategy that trimmed, e.g. "drop_oldest".
+	Strategy     string
+	Budget       int
+	TokensBefore int
+	TokensAfter  int
+	// Dropped are the messages removed from the history, oldest first.
+	Dropped []*models.Message
+	// Summary is the message that replaced the dropped ones, if any.
+	Summary *models.Message
+}
+
+// Trimmed reports whether any message was removed.
+func (r *Report) Trimmed() bool {
+	return len(r.Dropped) > 0
+}
+
+// Strategy shortens a history that does not fit budget. It receives the
+// history split into pinned system messages and turns, oldest first, and
+// returns the messages to send.
+type Strategy interface {
+	Trim(ctx context.Context, h *History, budget int, report *Report) ([]*models.Message, error)
+}
+
+// Config controls a Trimmer. Zero values take the defaults.
+type Config struct {
+	// Tokenizer counts tokens. Defaults to textsplitter.SimpleTokenizer;
+	// use a model-specific tokenizer such as TikTokenTokenizer for accuracy.
+	Tokenizer       textsplitter.Tokenizer
+	MessageOverhead int
+	// Strategy defaults to DropOldest.
+	Strategy Strategy
+	// OnTrim, if set, is called with the report of every trim that removed
+	// messages.
+	OnTrim func(*Report)
+}
+
+// Trimmer fits chat histories to a token budget.
+type Trimmer struct {
+	counter  *Counter
+	strategy Strategy
+	onTrim   func(*Report)
+}
+
+// New returns a Trimmer configured by config; a nil config uses the defaults.
+func New(config *Config) *Trimmer {
+	c := Config{}
+	if config != nil {
+		c = *config
+	}
+	if c.Tokenizer == nil {
+		c.Tokenizer = textsplitter.NewSimpleTokenizer()
+	}
+	if c.MessageOverhead <= 0 {
+		c.MessageOverhead = DefaultMessageOverhead
+	}
+	if c.Strategy == nil {
+		c.Strategy = &DropOldest{}
+	}
+	return &Trimmer{
+		counter:  &Counter{Tokenizer: c.Tokenizer, MessageOverhead: c.MessageOverhead},
+		strategy: c.Strategy,
+		onTrim:   c.OnTrim,
+	}
+}
+
+// Counter returns the counter the trimmer measures messages with.
+func (t *Trimmer) Counter() *Counter {
+	return t.counter
+}
+
+// Fit returns messages trimmed to budget tokens. A budget of zero or less
+// means unlimited and returns messages unchanged. When trimming, system
+// messages are kept and moved to the front. The input slice is not modified.
+func (t *Trimmer) Fit(ctx context.Context, messages []*models.Message, budget int) ([]*models.Message, *Report, error) {
+	report := &Report{Budget: budget, TokensBefore: t.counter.CountAll(messages)}
+	report.TokensAfter = report.TokensBefore
+	if budget <= 0 || report.TokensBefore <= budget {
+		return messages, report, nil
+	}
+	result, err := t.strategy.Trim(ctx, split(messages, t.counter), budget, report)
+	if err != nil {
+		return nil, report, err
+	}
+	report.TokensAfter = t.counter.CountAll(result)
+	if t.onTrim != nil && report.Trimmed() {
+		t.onTrim(report)
+	}
+	return result, report, nil
+}
+
+// FitRequest trims r.Messages in place to the budget of model, reserving
+// r.Options.MaxTokens for the answer.
+func (t *Trimmer) FitRequest(ctx context.Context, r *models.ChatRequest, model *models.Model) (*Report, error) {
+	messages, report, err := t.Fit(ctx, r.Messages, Budget(model, r.Options.MaxTokens))
+	if err != nil {
+		return report, err
+	}
+	r.Messages = messages
+	return report, nil
+}
+
+// Turn is a user message with the assistant and tool messages that answer
+// it. Tool calls and their results always stay in the same turn.
+type Turn struct {
+	Messages []*models.Message
+	Tokens   int
+}
+
+// History is a chat history split for trimming.
+type History struct {
+	// System holds the system messages, which are never dropped.
+	System []*models.Message
+	// Turns are the remaining messages grouped into turns, oldest first.
+	Turns   []*Turn
+	counter *Counter
+}
+
+// Counter returns the counter the history was measured with.
+func (h *History) Counter() *Counter {
+	return h.counter
+}
+
+// SystemTokens returns the tokens of the pinned system messages.
+func (h *History) SystemTokens() int {
+	return h.counter.CountAll(h.System)
+}
+
+// Messages reassembles the system messages, extra and turns[from:].
+func (h *History) Messages(from int, extra ...*models.Message) []*models.Message {
+	result := append([]*models.Message{}, h.System...)
+	result = append(result, extra...)
+	for _, turn := range h.Turns[from:] {
+		result = append(result, turn.Messages...)
+	}
+	return result
+}
+
+// split separates system messages and groups the rest into turns that
+// start at each user message. Messages before the first user message form
+// a turn of their own.
+func split(messages []*models.Message, counter *Counter) *History {
+	h := &History{counter: counter}
+	var current *Turn
+	for _, m := range messages {
+		if m.Role == models.SystemRole {
+			h.System = append(h.System, m)
+			continue
+		}
+		if current == nil || m.Role == models.UserRole {
+			current = &Turn{}
+			h.Turns = append(h.Turns, current)
+		}
+		current.Messages = append(current.Messages, m)
+		current.Tokens += counter.Count(m)
+	}
+	return h
+}
+
+// KeepFrom returns the index of the oldest turn that can be kept so that
+// fixed tokens plus Turns[from:] fit budget. The latest turn is always kept;
+// ErrBudgetExceeded is returned when even that does not fit.
+func (h *History) KeepFrom(fixed, budget int) (int, error) {
+	if len(h.Turns) == 0 {
+		if fixed > budget {
+			return 0, ErrBudgetExceeded
+		}
+		return 0, nil
+	}
+	total := fixed
+	from := len(h.Turns)
+	for from > 0 && total+h.Turns[from-1].Tokens <= budget {
+		from--
+		total += h.Turns[from].Tokens
+	}
+	if from == len(h.Turns) {
+		return 0, ErrBudgetExceeded
+	}
+	return from, nil
+}
+
+// Dropped returns the messages of Turns[:from].
+func (h *History) Dropped(from int) []*models.Message {
+	var result []*models.Message
+	for _, turn := range h.Turns[:from] {
+		result = append(result, turn.Messages...)
+	}
+	return result
+}
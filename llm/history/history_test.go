@@ -0,0 +1,129 @@
+package history
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/aqua777/ai-flow/llm/models"
+	mocks "github.com/aqua777/ai-flow/mocks/llm"
+)
+
+// summarizer answers every chat with a fixed summary and records requests.
+type summarizer struct {
+	mocks.MockLLM
+	summary  string
+	requests []*models.ChatRequest
+}
+
+func (s *summarizer) Chat(ctx context.Context, r *models.ChatRequest, stream ...func(chunk []byte) error) (*models.ChatResponse, error) {
+	s.requests = append(s.requests, r)
+	return &models.ChatResponse{Content: " " + s.summary + "\n"}, nil
+}
+
+type HistoryTestSuite struct {
+	suite.Suite
+	messages []*models.Message
+}
+
+func TestHistoryTestSuite(t *testing.T) {
+	suite.Run(t, new(HistoryTestSuite))
+}
+
+// With SimpleTokenizer and an overhead of 1 a message costs its word count
+// plus one: system 3, first turn 7, second turn 9, last turn 2.
+func (s *HistoryTestSuite) SetupTest() {
+	call := &models.ToolCall{ID: "c1", Name: "lookup", Arguments: "{}"}
+	s.messages = []*models.Message{
+		{Role: models.SystemRole, Content: "be brief"},
+		{Role: models.UserRole, Content: "one two three"},
+		{Role: models.AssistantRole, Content: "four five"},
+		{Role: models.UserRole, Content: "six seven"},
+		{Role: models.AssistantRole, ToolCalls: []*models.ToolCall{call}},
+		models.NewToolResultMessage(call, "ok"),
+		{Role: models.UserRole, Content: "eight"},
+	}
+}
+
+func (s *HistoryTestSuite) TestDropOldest() {
+	var reports []*Report
+	trimmer := New(&Config{MessageOverhead: 1, OnTrim: func(r *Report) { reports = append(reports, r) }})
+	s.Equal(21, trimmer.Counter().CountAll(s.messages))
+
+	result, report, err := trimmer.Fit(context.Background(), s.messages, 14)
+	s.Require().NoError(err)
+	s.Equal([]*models.Message{s.messages[0], s.messages[3], s.messages[4], s.messages[5], s.messages[6]}, result)
+	s.Equal(&Report{
+		Strategy:     "drop_oldest",
+		Budget:       14,
+		TokensBefore: 21,
+		TokensAfter:  14,
+		Dropped:      s.messages[1:3],
+	}, report)
+	s.Equal([]*Report{report}, reports)
+	s.Len(s.messages, 7, "the input is not modified")
+
+	result, report, err = trimmer.Fit(context.Background(), s.messages, 21)
+	s.Require().NoError(err)
+	s.Equal(s.messages, result)
+	s.False(report.Trimmed())
+	result, _, err = trimmer.Fit(context.Background(), s.messages, 0)
+	s.Require().NoError(err)
+	s.Equal(s.messages, result, "a zero budget is unlimited")
+	s.Len(reports, 1)
+
+	_, _, err = trimmer.Fit(context.Background(), s.messages, 4)
+	s.ErrorIs(err, ErrBudgetExceeded)
+	s.ErrorIs(err, models.ErrContextLengthExceeded)
+}
+
+func (s *HistoryTestSuite) TestFitRequest() {
+	req := &models.ChatRequest{Messages: s.messages, Options: models.RequestOptions{MaxTokens: 6}}
+	report, err := New(&Config{MessageOverhead: 1}).FitRequest(context.Background(), req, &models.Model{ContextSize: 20})
+	s.Require().NoError(err)
+	s.Equal(14, report.Budget)
+	s.Len(req.Messages, 5)
+
+	s.Equal(0, Budget(&models.Model{}, 100), "unknown context size")
+	s.Equal(0, Budget(&models.Model{ContextSize: 50}, 100))
+}
+
+func (s *HistoryTestSuite) TestSummarize() {
+	llm := &summarizer{summary: "talked numbers"}
+	trimmer := New(&Config{MessageOverhead: 1, Strategy: &Summarize{LLM: llm, Model: "small", MaxTokens: 2}})
+
+	result, report, err := trimmer.Fit(context.Background(), s.messages, 20)
+	s.Require().NoError(err)
+	summary := &models.Message{Role: models.SystemRole, Content: SummaryPrefix + "talked numbers"}
+	s.Equal([]*models.Message{s.messages[0], summary, s.messages[6]}, result)
+	s.Equal("summarize", report.Strategy)
+	s.Equal(s.messages[1:6], report.Dropped)
+	s.Equal(summary, report.Summary)
+	s.Equal(13, report.TokensAfter)
+
+	s.Require().Len(llm.requests, 1)
+	s.Equal("small", llm.requests[0].Model)
+	s.Equal(2, llm.requests[0].Options.MaxTokens)
+	prompt := llm.requests[0].Messages[0].Content
+	s.Contains(prompt, "user: one two three\nassistant: four five\n")
+	s.Contains(prompt, "assistant called lookup({})\ntool: ok\n")
+	s.NotContains(prompt, "eight")
+
+	// A later trim folds the previous summary into the new one.
+	llm.summary = "all of it"
+	next := append(result, &models.Message{Role: models.UserRole, Content: "nine ten eleven twelve"}, &models.Message{Role: models.AssistantRole, Content: "x"})
+	result, report, err = trimmer.Fit(context.Background(), next, 19)
+	s.Require().NoError(err)
+	s.Equal([]*models.Message{s.messages[0], report.Summary, next[3], next[4]}, result)
+	s.Equal(SummaryPrefix+"all of it", report.Summary.Content)
+	s.Equal([]*models.Message{summary, s.messages[6]}, report.Dropped)
+	s.Contains(llm.requests[1].Messages[0].Content, SummaryPrefix+"talked numbers\n\nuser: eight\n")
+}
+
+func (s *HistoryTestSuite) TestSummarize_TooLong() {
+	llm := &summarizer{summary: "a summary that runs far longer than the tokens reserved for it"}
+	trimmer := New(&Config{MessageOverhead: 1, Strategy: &Summarize{LLM: llm, MaxTokens: 2}})
+	_, _, err := trimmer.Fit(context.Background(), s.messages, 20)
+	s.ErrorIs(err, ErrBudgetExceeded)
+}
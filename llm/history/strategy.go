@@ -0,0 +1,144 @@
+package history
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aqua777/ai-flow/llm/iface"
+	"github.com/aqua777/ai-flow/llm/models"
+)
+
+const (
+	DefaultSummaryTokens = 512
+
+	// SummaryPrefix starts the system message that carries a summary, so a
+	// later trim can recognise it and fold it into the next summary.
+	SummaryPrefix = "Summary of the earlier conversation:\n"
+
+	DefaultSummaryPrompt = `Summarize the conversation below between a user and an assistant. ` +
+		`Keep the facts, decisions, names and open questions that later messages may rely on. ` +
+		`Reply with the summary only.
+
+%s`
+)
+
+// DropOldest drops the oldest turns until the history fits, keeping system
+// messages and the latest turn.
+type DropOldest struct{}
+
+func (d *DropOldest) Trim(ctx context.Context, h *History, budget int, report *Report) ([]*models.Message, error) {
+	report.Strategy = "drop_oldest"
+	from, err := h.KeepFrom(h.SystemTokens(), budget)
+	if err != nil {
+		return nil, err
+	}
+	report.Dropped = h.Dropped(from)
+	return h.Messages(from), nil
+}
+
+// Summarize replaces the oldest turns with an LLM-written summary, carried
+// in a system message after the pinned ones. A summary left by an earlier
+// trim is folded into the new one.
+type Summarize struct {
+	LLM   iface.LLM
+	Model string
+	// MaxTokens bounds the summary and is reserved from the budget.
+	// Defaults to DefaultSummaryTokens.
+	MaxTokens int
+	// Prompt is a format string with one %s for the transcript. Defaults to
+	// DefaultSummaryPrompt.
+	Prompt string
+}
+
+func (s *Summarize) Trim(ctx context.Context, h *History, budget int, report *Report) ([]*models.Message, error) {
+	report.Strategy = "summarize"
+	maxTokens := s.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = DefaultSummaryTokens
+	}
+
+	var previous *models.Message
+	pinned := &History{Turns: h.Turns, counter: h.counter}
+	for _, m := range h.System {
+		if strings.HasPrefix(m.Content, SummaryPrefix) {
+			previous = m
+		} else {
+			pinned.System = append(pinned.System, m)
+		}
+	}
+	reserve := maxTokens + h.counter.Count(&models.Message{Role: models.SystemRole, Content: SummaryPrefix})
+	from, err := pinned.KeepFrom(pinned.SystemTokens()+reserve, budget)
+	if err != nil {
+		return nil, err
+	}
+
+	text, err := s.summarize(ctx, previous, pinned.Dropped(from), maxTokens)
+	if err != nil {
+		return nil, fmt.Errorf("summarize history: %w", err)
+	}
+	summary := &models.Message{Role: models.SystemRole, Content: SummaryPrefix + text}
+
+	// The tokenizer may disagree with the model about the summary's length;
+	// drop further turns, unsummarized, if it does not fit.
+	total := pinned.SystemTokens() + h.counter.Count(summary) + turnTokens(pinned.Turns[from:])
+	for total > budget {
+		if from >= len(pinned.Turns)-1 {
+			return nil, ErrBudgetExceeded
+		}
+		total -= pinned.Turns[from].Tokens
+		from++
+	}
+	report.Dropped = pinned.Dropped(from)
+	if previous != nil {
+		report.Dropped = append([]*models.Message{previous}, report.Dropped...)
+	}
+	report.Summary = summary
+	return pinned.Messages(from, summary), nil
+}
+
+func (s *Summarize) summarize(ctx context.Context, previous *models.Message, messages []*models.Message, maxTokens int) (string, error) {
+	if s.LLM == nil {
+		return "", errors.New("no LLM configured")
+	}
+	prompt := s.Prompt
+	if prompt == "" {
+		prompt = DefaultSummaryPrompt
+	}
+	var transcript strings.Builder
+	if previous != nil {
+		transcript.WriteString(previous.Content)
+		transcript.WriteString("\n\n")
+	}
+	for _, m := range messages {
+		writeMessage(&transcript, m)
+	}
+	resp, err := s.LLM.Chat(ctx, &models.ChatRequest{
+		Model:    s.Model,
+		Messages: []*models.Message{{Role: models.UserRole, Content: fmt.Sprintf(prompt, transcript.String())}},
+		Options:  models.RequestOptions{MaxTokens: maxTokens},
+	})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(resp.Content), nil
+}
+
+// writeMessage renders m as transcript lines such as "user: hello".
+func writeMessage(w *strings.Builder, m *models.Message) {
+	if m.Content != "" {
+		fmt.Fprintf(w, "%s: %s\n", m.Role, m.Content)
+	}
+	for _, call := range m.ToolCalls {
+		fmt.Fprintf(w, "%s called %s(%s)\n", m.Role, call.Name, call.Arguments)
+	}
+}
+
+func turnTokens(turns []*Turn) int {
+	n := 0
+	for _, turn := range turns {
+		n += turn.Tokens
+	}
+	return n
+}
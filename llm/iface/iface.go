@@ -8,7 +8,8 @@ import (
 
 type LLM interface {
 	ListModels(ctx context.Context) ([]*models.Model, error)
-	Generate(ctx context.Context, r *models.GenerateRequest) (*models.GenerateResponse, error)
+	Generate(ctx context.Context, r *models.GenerateRequest, stream ...func(chunk []byte) error) (*models.GenerateResponse, error)
 	Chat(ctx context.Context, r *models.ChatRequest, stream ...func(chunk []byte) error) (*models.ChatResponse, error)
 	Embeddings(ctx context.Context, cr *models.EmbeddingsRequest) (*models.EmbeddingsResponse, error)
+	BatchEmbeddings(ctx context.Context, r *models.BatchEmbeddingsRequest) (*models.BatchEmbeddingsResponse, error)
 }
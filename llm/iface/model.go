@@ -0,0 +1,33 @@
+package iface
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aqua777/ai-flow/llm/models"
+)
+
+// ModelGetter is implemented by LLMs that can look up a single model's
+// metadata without listing every model.
+type ModelGetter interface {
+	GetModel(ctx context.Context, name string) (*models.Model, error)
+}
+
+// GetModel returns the metadata of the model called name. It uses
+// ModelGetter when llm implements it and otherwise searches ListModels by ID,
+// model and name. Unknown models fail with models.ErrModelNotFound.
+func GetModel(ctx context.Context, llm LLM, name string) (*models.Model, error) {
+	if getter, ok := llm.(ModelGetter); ok {
+		return getter.GetModel(ctx, name)
+	}
+	list, err := llm.ListModels(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range list {
+		if m.ID == name || m.Model == name || m.Name == name {
+			return m, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", models.ErrModelNotFound, name)
+}
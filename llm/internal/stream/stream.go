@@ -0,0 +1,20 @@
+// Package stream holds stream callback helpers shared by the LLM decorators.
+package stream
+
+// NeverStarted is the started func of a call without a stream callback.
+func NeverStarted() bool { return false }
+
+// Track wraps an optional stream callback so a decorator can tell whether
+// any chunk has reached the caller, and so whether a failed call may still
+// be retried or failed over without the caller seeing output twice.
+func Track(stream []func(chunk []byte) error) ([]func(chunk []byte) error, func() bool) {
+	if len(stream) == 0 || stream[0] == nil {
+		return stream, NeverStarted
+	}
+	started := false
+	callback := stream[0]
+	return []func(chunk []byte) error{func(chunk []byte) error {
+		started = true
+		return callback(chunk)
+	}}, func() bool { return started }
+}
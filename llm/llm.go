@@ -1,9 +1,6 @@
 package llm
 
 import (
-	"context"
-	"errors"
-
 	"github.com/aqua777/ai-flow/llm/iface"
 	"github.com/aqua777/ai-flow/llm/models"
 )
@@ -16,6 +13,23 @@ type (
 	ChatCompletionResponse = models.ChatResponse
 )
 
-func New(ctx context.Context, config *LLMConfig) (LLM, error) {
-	return nil, errors.New("not implemented")
-}
+var ErrInvalidConfig = models.ErrInvalidConfig
+
+// Errors returned by providers; see models.ProviderError.
+type ProviderError = models.ProviderError
+
+var (
+	ErrNotSupported          = models.ErrNotSupported
+	ErrContentFiltered       = models.ErrContentFiltered
+	ErrImagesNotSupported    = models.ErrImagesNotSupported
+	ErrAuthentication        = models.ErrAuthentication
+	ErrPermissionDenied      = models.ErrPermissionDenied
+	ErrRateLimited           = models.ErrRateLimited
+	ErrContextLengthExceeded = models.ErrContextLengthExceeded
+	ErrModelNotFound         = models.ErrModelNotFound
+	ErrInvalidRequest        = models.ErrInvalidRequest
+	ErrTimeout               = models.ErrTimeout
+	ErrUnavailable           = models.ErrUnavailable
+	ErrServerError           = models.ErrServerError
+	ErrProvider              = models.ErrProvider
+)
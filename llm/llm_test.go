@@ -0,0 +1,94 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/aqua777/ai-flow/llm/models"
+	"github.com/aqua777/ai-flow/llm/ollama"
+	"github.com/aqua777/ai-flow/llm/openai"
+)
+
+type stubLLM struct {
+	LLM
+	config *LLMConfig
+}
+
+type RegistryTestSuite struct {
+	suite.Suite
+}
+
+func TestRegistryTestSuite(t *testing.T) {
+	suite.Run(t, new(RegistryTestSuite))
+}
+
+func (s *RegistryTestSuite) TearDownTest() {
+	unregister("stub-custom")
+	unregister("stub-failing")
+}
+
+func (s *RegistryTestSuite) TestBuiltinProviders() {
+	s.Contains(Providers(), models.OLLAMA)
+	s.Contains(Providers(), models.OPENAI)
+	s.Contains(Providers(), models.ANTHROPIC)
+	s.Contains(Providers(), models.GEMINI)
+
+	client, err := New(context.Background(), &LLMConfig{Provider: "Ollama", Url: "http://localhost:11434"})
+	s.NoError(err)
+	s.IsType(&ollama.Client{}, client)
+
+	client, err = New(context.Background(), &LLMConfig{Provider: models.OPENAI, ApiKey: "key"})
+	s.NoError(err)
+	s.IsType(&openai.Client{}, client)
+}
+
+func (s *RegistryTestSuite) TestCustomProvider() {
+	Register("stub-custom", func(ctx context.Context, config *LLMConfig) (LLM, error) {
+		return &stubLLM{config: config}, nil
+	})
+	client, err := New(context.Background(), &LLMConfig{Provider: " STUB-custom ", ApiKey: "k"})
+	s.NoError(err)
+	stub, ok := client.(*stubLLM)
+	s.Require().True(ok)
+	s.Equal("stub-custom", stub.config.Provider)
+	s.Equal("k", stub.config.ApiKey)
+
+	s.Panics(func() {
+		Register("stub-custom", func(ctx context.Context, config *LLMConfig) (LLM, error) { return nil, nil })
+	})
+	s.Panics(func() { Register("", func(ctx context.Context, config *LLMConfig) (LLM, error) { return nil, nil }) })
+	s.Panics(func() { Register("stub-nil", nil) })
+}
+
+func (s *RegistryTestSuite) TestFactoryError() {
+	boom := errors.New("boom")
+	Register("stub-failing", func(ctx context.Context, config *LLMConfig) (LLM, error) {
+		return nil, boom
+	})
+	client, err := New(context.Background(), &LLMConfig{Provider: "stub-failing"})
+	s.Nil(client)
+	s.ErrorIs(err, boom)
+}
+
+func (s *RegistryTestSuite) TestInvalidConfig() {
+	testData := []struct {
+		name     string
+		config   *LLMConfig
+		expected error
+	}{
+		{name: "NilConfig", config: nil, expected: ErrInvalidConfig},
+		{name: "EmptyProvider", config: &LLMConfig{}, expected: ErrInvalidConfig},
+		{name: "UrlWithoutHost", config: &LLMConfig{Provider: models.OLLAMA, Url: "localhost"}, expected: ErrInvalidConfig},
+		{name: "UnknownProvider", config: &LLMConfig{Provider: "nope"}, expected: ErrUnknownProvider},
+	}
+	for _, t := range testData {
+		s.Run(t.name, func() {
+			client, err := New(context.Background(), t.config)
+			s.Nil(client)
+			s.ErrorIs(err, t.expected)
+		})
+	}
+}
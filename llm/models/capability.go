@@ -0,0 +1,13 @@
+package models
+
+// Capability is a feature a model supports. The names follow the capability
+// list Ollama reports from /api/show.
+type Capability string
+
+const (
+	CapabilityCompletion Capability = "completion"
+	CapabilityTools      Capability = "tools"
+	CapabilityVision     Capability = "vision"
+	CapabilityEmbedding  Capability = "embedding"
+	CapabilityThinking   Capability = "thinking"
+)
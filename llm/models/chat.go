@@ -4,13 +4,22 @@ type Message struct {
 	Role     Role   `json:"role"`
 	Content  string `json:"content"`
 	Thinking string `json:"thinking,omitempty"`
+	// Images are attached to the message for vision-capable models.
+	Images []*Image `json:"images,omitempty"`
+	// ToolCalls are the calls requested by an assistant message.
+	ToolCalls []*ToolCall `json:"tool_calls,omitempty"`
+	// ToolCallID and ToolName identify the call a tool message answers.
+	ToolCallID string `json:"tool_call_id,omitempty"`
+	ToolName   string `json:"tool_name,omitempty"`
 }
 
 type ChatRequest struct {
-	Model    string                        `json:"model"`
-	Messages []*Message                    `json:"messages"`
-	Stream   bool                          `json:"stream"`
-	Options  RequestOptions `json:"options"`
+	Model          string          `json:"model"`
+	Messages       []*Message      `json:"messages"`
+	Tools          []*Tool         `json:"tools,omitempty"`
+	ResponseFormat *ResponseFormat `json:"response_format,omitempty"`
+	Stream         bool            `json:"stream"`
+	Options        RequestOptions  `json:"options"`
 }
 
 type ChatResponseMetadata struct {
@@ -22,5 +31,20 @@ type ChatResponseMetadata struct {
 type ChatResponse struct {
 	Content   string                `json:"content"`
 	Reasoning string                `json:"reasoning"`
+	ToolCalls []*ToolCall           `json:"tool_calls,omitempty"`
 	Metadata  *ChatResponseMetadata `json:"metadata"`
+	// FinishReason says why generation stopped; FinishReasonLength means
+	// the content is truncated.
+	FinishReason FinishReason `json:"finish_reason,omitempty"`
+}
+
+// Message returns the response as an assistant message, ready to be appended
+// to the conversation history.
+func (r *ChatResponse) Message() *Message {
+	return &Message{
+		Role:      AssistantRole,
+		Content:   r.Content,
+		Thinking:  r.Reasoning,
+		ToolCalls: r.ToolCalls,
+	}
 }
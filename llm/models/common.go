@@ -3,7 +3,8 @@ package models
 type Role string
 
 const (
-	UserRole Role = "user"
+	UserRole      Role = "user"
 	AssistantRole Role = "assistant"
-	SystemRole Role = "system"
+	SystemRole    Role = "system"
+	ToolRole      Role = "tool"
 )
@@ -9,3 +9,22 @@ type EmbeddingsRequest struct {
 type EmbeddingsResponse struct {
 	Embeddings []float32 `json:"embedding"`
 }
+
+type BatchEmbeddingsRequest struct {
+	Model      string   `json:"model"`
+	Dimensions int      `json:"dimensions"`
+	Inputs     []string `json:"inputs"`
+	// BatchSize caps the inputs sent per provider request. Zero uses the
+	// provider's limit; larger values are clamped to it.
+	BatchSize int `json:"batch_size,omitempty"`
+	// Concurrency caps the provider requests in flight. Zero uses
+	// DefaultEmbeddingsConcurrency.
+	Concurrency int `json:"concurrency,omitempty"`
+}
+
+type BatchEmbeddingsResponse struct {
+	// Embeddings holds one vector per input, in input order.
+	Embeddings [][]float32 `json:"embeddings"`
+}
+
+const DefaultEmbeddingsConcurrency = 4
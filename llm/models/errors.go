@@ -0,0 +1,178 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNotSupported    = errors.New("operation not supported by provider")
+	ErrContentFiltered = errors.New("content blocked by provider safety filters")
+
+	// Provider failure kinds. Every error a provider returns for a failed
+	// API call is a *ProviderError that matches one of these with errors.Is.
+	ErrAuthentication        = errors.New("authentication failed")
+	ErrPermissionDenied      = errors.New("permission denied")
+	ErrRateLimited           = errors.New("rate limited")
+	ErrContextLengthExceeded = errors.New("context length exceeded")
+	ErrModelNotFound         = errors.New("model not found")
+	ErrInvalidRequest        = errors.New("invalid request")
+	ErrTimeout               = errors.New("provider timed out")
+	ErrUnavailable           = errors.New("provider unavailable")
+	ErrServerError           = errors.New("provider server error")
+	ErrProvider              = errors.New("provider error")
+)
+
+// ProviderError describes a failed provider API call. It unwraps to both its
+// Kind sentinel and the underlying client error, so errors.Is(err,
+// ErrRateLimited) and errors.As into the transport's own error type both work.
+type ProviderError struct {
+	Provider string
+	// StatusCode is the HTTP status, or zero for errors reported inside an
+	// otherwise successful stream.
+	StatusCode int
+	// Code is the provider's machine-readable error code or type, such as
+	// "rate_limit_exceeded" or "overloaded_error".
+	Code    string
+	Message string
+	Body    []byte
+	// RetryAfter is the delay the provider asked for, if any.
+	RetryAfter time.Duration
+	// Retryable reports whether the same request may succeed if sent again.
+	Retryable bool
+	// Kind is one of the sentinel errors above.
+	Kind error
+	Err  error
+}
+
+func (e *ProviderError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Provider, e.Err)
+	}
+	detail := e.Message
+	if e.Code != "" {
+		detail = e.Code + ": " + detail
+	}
+	if e.StatusCode != 0 {
+		return fmt.Sprintf("%s: status code: %d, error: %s", e.Provider, e.StatusCode, detail)
+	}
+	return fmt.Sprintf("%s: %s", e.Provider, detail)
+}
+
+func (e *ProviderError) Unwrap() []error {
+	if e.Err == nil {
+		return []error{e.Kind}
+	}
+	return []error{e.Kind, e.Err}
+}
+
+// NewProviderError classifies a provider failure from its HTTP status, error
+// code and message. err is the underlying client error and may be nil.
+func NewProviderError(provider string, status int, code, message string, err error) *ProviderError {
+	kind := classifyError(status, code, message)
+	return &ProviderError{
+		Provider:   provider,
+		StatusCode: status,
+		Code:       code,
+		Message:    message,
+		Kind:       kind,
+		Retryable:  isRetryableKind(kind, status, code),
+		Err:        err,
+	}
+}
+
+// errorCodes maps the error codes and types used by OpenAI, Anthropic and
+// Gemini (lowercased) onto error kinds.
+var errorCodes = map[string]error{
+	"context_length_exceeded": ErrContextLengthExceeded,
+	"string_above_max_length": ErrContextLengthExceeded,
+	"invalid_api_key":         ErrAuthentication,
+	"authentication_error":    ErrAuthentication,
+	"unauthenticated":         ErrAuthentication,
+	"permission_error":        ErrPermissionDenied,
+	"permission_denied":       ErrPermissionDenied,
+	"model_not_found":         ErrModelNotFound,
+	"not_found_error":         ErrModelNotFound,
+	"not_found":               ErrModelNotFound,
+	"rate_limit_exceeded":     ErrRateLimited,
+	"rate_limit_error":        ErrRateLimited,
+	"resource_exhausted":      ErrRateLimited,
+	"insufficient_quota":      ErrRateLimited,
+	"overloaded_error":        ErrUnavailable,
+	"unavailable":             ErrUnavailable,
+	"api_error":               ErrServerError,
+	"internal":                ErrServerError,
+	"server_error":            ErrServerError,
+	"deadline_exceeded":       ErrTimeout,
+	"invalid_request_error":   ErrInvalidRequest,
+	"invalid_argument":        ErrInvalidRequest,
+	"request_too_large":       ErrInvalidRequest,
+}
+
+// contextLengthHints are message fragments providers use when a prompt does
+// not fit the model; they often arrive as a plain invalid request.
+var contextLengthHints = []string{
+	"context length",
+	"context_length",
+	"context window",
+	"maximum context",
+	"prompt is too long",
+	"too many tokens",
+	"input token count",
+}
+
+// classifyError maps a failure onto its kind by code, then status. Only an
+// invalid request or an unrecognised failure is refined by contextLengthHints,
+// so a throttle or outage whose message mentions tokens keeps its kind.
+func classifyError(status int, code, message string) error {
+	kind := classifyStatus(status, code)
+	if kind != ErrInvalidRequest && kind != ErrProvider {
+		return kind
+	}
+	lower := strings.ToLower(message)
+	for _, hint := range contextLengthHints {
+		if strings.Contains(lower, hint) {
+			return ErrContextLengthExceeded
+		}
+	}
+	return kind
+}
+
+func classifyStatus(status int, code string) error {
+	if kind, ok := errorCodes[strings.ToLower(code)]; ok {
+		return kind
+	}
+	switch {
+	case status == 401:
+		return ErrAuthentication
+	case status == 403:
+		return ErrPermissionDenied
+	case status == 404:
+		return ErrModelNotFound
+	case status == 408 || status == 504:
+		return ErrTimeout
+	case status == 429:
+		return ErrRateLimited
+	case status == 400 || status == 413 || status == 422:
+		return ErrInvalidRequest
+	case status == 502 || status == 503 || status == 529:
+		return ErrUnavailable
+	case status >= 500:
+		return ErrServerError
+	}
+	return ErrProvider
+}
+
+func isRetryableKind(kind error, status int, code string) bool {
+	if strings.EqualFold(code, "insufficient_quota") {
+		// Out of credit: waiting does not help.
+		return false
+	}
+	switch kind {
+	case ErrRateLimited, ErrTimeout, ErrUnavailable, ErrServerError:
+		return true
+	}
+	return status == 425
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ProviderErrorTestSuite struct {
+	suite.Suite
+}
+
+func TestProviderErrorTestSuite(t *testing.T) {
+	suite.Run(t, new(ProviderErrorTestSuite))
+}
+
+func (s *ProviderErrorTestSuite) TestClassification() {
+	tests := []struct {
+		status    int
+		code      string
+		message   string
+		kind      error
+		retryable bool
+	}{
+		{401, "", "bad key", ErrAuthentication, false},
+		{400, "invalid_api_key", "", ErrAuthentication, false},
+		{403, "", "forbidden", ErrPermissionDenied, false},
+		{404, "", "model 'llama9' not found", ErrModelNotFound, false},
+		{429, "", "slow down", ErrRateLimited, true},
+		{429, "insufficient_quota", "out of credit", ErrRateLimited, false},
+		{400, "", "This model's maximum context length is 8192 tokens", ErrContextLengthExceeded, false},
+		{400, "invalid_request_error", "prompt is too long: 300000 tokens", ErrContextLengthExceeded, false},
+		{400, "invalid_request_error", "bad field", ErrInvalidRequest, false},
+		{529, "overloaded_error", "Overloaded", ErrUnavailable, true},
+		{0, "overloaded_error", "Overloaded", ErrUnavailable, true},
+		{503, "", "loading", ErrUnavailable, true},
+		{500, "", "boom", ErrServerError, true},
+		{504, "", "", ErrTimeout, true},
+		{429, "RESOURCE_EXHAUSTED", "quota", ErrRateLimited, true},
+		{0, "", "model crashed", ErrProvider, false},
+		{0, "", "prompt is too long", ErrContextLengthExceeded, false},
+		{413, "", "too many tokens in request", ErrContextLengthExceeded, false},
+		{429, "", "Rate limit reached: too many tokens per minute", ErrRateLimited, true},
+		{429, "rate_limit_error", "input token count exceeds your per-minute limit", ErrRateLimited, true},
+		{500, "", "failed to compute context length", ErrServerError, true},
+	}
+	for _, tt := range tests {
+		err := NewProviderError("test", tt.status, tt.code, tt.message, nil)
+		s.ErrorIs(err, tt.kind, "%d %s %s", tt.status, tt.code, tt.message)
+		s.Equal(tt.retryable, err.Retryable, "%d %s %s", tt.status, tt.code, tt.message)
+	}
+}
+
+func (s *ProviderErrorTestSuite) TestErrorAndUnwrap() {
+	err := NewProviderError("anthropic", 429, "rate_limit_error", "slow down", nil)
+	s.EqualError(err, "anthropic: status code: 429, error: rate_limit_error: slow down")
+	s.EqualError(NewProviderError("ollama", 0, "", "model crashed", nil), "ollama: model crashed")
+
+	cause := errors.New("status code: 429")
+	err = NewProviderError("ollama", 429, "", "slow down", cause)
+	s.EqualError(err, "ollama: status code: 429")
+	s.ErrorIs(err, cause)
+	s.ErrorIs(err, ErrRateLimited)
+
+	var providerErr *ProviderError
+	s.Require().ErrorAs(errors.Join(errors.New("wrapped"), err), &providerErr)
+	s.Equal("ollama", providerErr.Provider)
+}
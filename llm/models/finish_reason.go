@@ -0,0 +1,23 @@
+package models
+
+// FinishReason says why the model stopped producing output. Providers map
+// their own vocabulary onto the constants below; a reason with no
+// equivalent is passed through as reported, and an empty reason means the
+// provider did not report one.
+type FinishReason string
+
+const (
+	// FinishReasonStop means the model finished naturally or hit a stop sequence.
+	FinishReasonStop FinishReason = "stop"
+	// FinishReasonLength means the answer was cut off by the token limit.
+	FinishReasonLength FinishReason = "length"
+	// FinishReasonToolCalls means the model stopped to call tools.
+	FinishReasonToolCalls FinishReason = "tool_calls"
+	// FinishReasonContentFilter means output was withheld by a safety filter.
+	FinishReasonContentFilter FinishReason = "content_filter"
+)
+
+// Truncated reports whether the answer was cut off before the model finished.
+func (r FinishReason) Truncated() bool {
+	return r == FinishReasonLength
+}
@@ -3,17 +3,21 @@ package models
 import "time"
 
 type GenerateRequest struct {
-	Model string `json:"model"`
-	Prompt string `json:"prompt"`
-	Stream bool `json:"stream"`
-	Options RequestOptions `json:"options,omitempty"`
+	Model          string          `json:"model"`
+	Prompt         string          `json:"prompt"`
+	Stream         bool            `json:"stream"`
+	Options        RequestOptions  `json:"options,omitempty"`
+	ResponseFormat *ResponseFormat `json:"response_format,omitempty"`
 }
 
 type GenerateResponse struct {
-	Text string `json:"text"`
-	Model string `json:"model"`
-	CreatedAt time.Time `json:"created_at"`
-	PromptTokens int `json:"prompt_tokens"`
-	CompletionTokens int `json:"completion_tokens"`
-	TotalTokens int `json:"total_tokens"`
+	Text             string    `json:"text"`
+	Model            string    `json:"model"`
+	CreatedAt        time.Time `json:"created_at"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	TotalTokens      int       `json:"total_tokens"`
+	// FinishReason says why generation stopped; FinishReasonLength means
+	// the text is truncated.
+	FinishReason FinishReason `json:"finish_reason,omitempty"`
 }
@@ -0,0 +1,122 @@
+package models
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+var (
+	ErrImagesNotSupported = errors.New("model does not support image input")
+	ErrInvalidImage       = errors.New("invalid image")
+)
+
+// Image is an image attached to a message, either inline bytes with their
+// MIME type or a URL the provider fetches itself.
+type Image struct {
+	Data     []byte `json:"data,omitempty"`
+	MIMEType string `json:"mime_type,omitempty"`
+	URL      string `json:"url,omitempty"`
+}
+
+// NewImage wraps inline image bytes. The MIME type is sniffed when empty.
+func NewImage(data []byte, mimeType string) *Image {
+	if mimeType == "" {
+		mimeType = http.DetectContentType(data)
+	}
+	return &Image{Data: data, MIMEType: mimeType}
+}
+
+// NewImageURL references an image by URL.
+func NewImageURL(url string) *Image {
+	return &Image{URL: url}
+}
+
+// LoadImage reads an image file from disk.
+func LoadImage(path string) (*Image, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image %s: %w", path, err)
+	}
+	return NewImage(data, ""), nil
+}
+
+// Base64 returns the inline data base64-encoded. For a data URL it returns
+// the encoded payload; for any other URL it returns an error.
+func (i *Image) Base64() (string, error) {
+	if len(i.Data) > 0 {
+		return base64.StdEncoding.EncodeToString(i.Data), nil
+	}
+	if strings.HasPrefix(i.URL, "data:") {
+		if idx := strings.Index(i.URL, ";base64,"); idx >= 0 {
+			return i.URL[idx+len(";base64,"):], nil
+		}
+	}
+	if i.URL != "" {
+		return "", fmt.Errorf("image %s is not inline data", i.URL)
+	}
+	return "", fmt.Errorf("%w: image has neither data nor URL", ErrInvalidImage)
+}
+
+// Inline reports whether the image carries its bytes, either as Data or as
+// a base64 data URL, rather than referencing a URL to fetch.
+func (i *Image) Inline() bool {
+	return len(i.Data) > 0 || strings.HasPrefix(i.URL, "data:")
+}
+
+// ContentType returns the MIME type. When empty it is sniffed from the data
+// or taken from a data URL.
+func (i *Image) ContentType() string {
+	switch {
+	case i.MIMEType != "":
+		return i.MIMEType
+	case len(i.Data) > 0:
+		return http.DetectContentType(i.Data)
+	case strings.HasPrefix(i.URL, "data:"):
+		mediaType, _, _ := strings.Cut(strings.TrimPrefix(i.URL, "data:"), ";")
+		mediaType, _, _ = strings.Cut(mediaType, ",")
+		return mediaType
+	}
+	return ""
+}
+
+// Validate reports an image that carries neither data nor a URL.
+func (i *Image) Validate() error {
+	if len(i.Data) == 0 && i.URL == "" {
+		return fmt.Errorf("%w: image has neither data nor URL", ErrInvalidImage)
+	}
+	return nil
+}
+
+// DataURL returns the URL of the image, or a base64 data URL for inline data.
+func (i *Image) DataURL() string {
+	if len(i.Data) == 0 {
+		return i.URL
+	}
+	return fmt.Sprintf("data:%s;base64,%s", i.ContentType(), base64.StdEncoding.EncodeToString(i.Data))
+}
+
+// ValidateImages checks every image attached to messages.
+func ValidateImages(messages []*Message) error {
+	for _, msg := range messages {
+		for _, image := range msg.Images {
+			if err := image.Validate(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// HasImages reports whether any message carries images.
+func HasImages(messages []*Message) bool {
+	for _, msg := range messages {
+		if len(msg.Images) > 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -1,28 +1,36 @@
 package models
 
 import (
+	"errors"
 	"fmt"
+	"net/url"
 	"os"
 	"strings"
 )
 
 const (
-	OPENAI = "openai"
-	OLLAMA = "ollama"
-	
-	DEFAULT_OPENAI_URL_V1 = "https://api.openai.com/v1"
-	DEFAULT_OLLAMA_URL = "http://localhost:11434"
+	OPENAI    = "openai"
+	OLLAMA    = "ollama"
+	ANTHROPIC = "anthropic"
+	GEMINI    = "gemini"
+
+	DEFAULT_OPENAI_URL_V1    = "https://api.openai.com/v1"
+	DEFAULT_OLLAMA_URL       = "http://localhost:11434"
+	DEFAULT_ANTHROPIC_URL_V1 = "https://api.anthropic.com/v1"
+	DEFAULT_GEMINI_URL       = "https://generativelanguage.googleapis.com/v1beta"
 )
 
 type LLMConfig struct {
 	Provider string `json:"provider"`
-	Url    string `json:"url"`
-	ApiKey string `json:"api_key"`
+	Url      string `json:"url"`
+	ApiKey   string `json:"api_key"`
 }
 
 var providerDefaultUrls = map[string]string{
-	"openai": DEFAULT_OPENAI_URL_V1,
-	"ollama": DEFAULT_OLLAMA_URL,
+	"openai":    DEFAULT_OPENAI_URL_V1,
+	"ollama":    DEFAULT_OLLAMA_URL,
+	"anthropic": DEFAULT_ANTHROPIC_URL_V1,
+	"gemini":    DEFAULT_GEMINI_URL,
 }
 
 func (c *LLMConfig) WithDefaults(provider string) *LLMConfig {
@@ -41,9 +49,31 @@ func (c *LLMConfig) WithDefaults(provider string) *LLMConfig {
 	}
 	return &LLMConfig{
 		Provider: c.Provider,
-		Url:    c.Url,
-		ApiKey: c.ApiKey,
+		Url:      c.Url,
+		ApiKey:   c.ApiKey,
+	}
+}
+
+var ErrInvalidConfig = errors.New("invalid llm config")
+
+// Validate checks that the config names a provider and, when set, a parseable URL.
+func (c *LLMConfig) Validate() error {
+	if c == nil {
+		return fmt.Errorf("%w: config is nil", ErrInvalidConfig)
+	}
+	if strings.TrimSpace(c.Provider) == "" {
+		return fmt.Errorf("%w: provider is required", ErrInvalidConfig)
+	}
+	if c.Url != "" {
+		u, err := url.Parse(c.Url)
+		if err != nil {
+			return fmt.Errorf("%w: url %q: %v", ErrInvalidConfig, c.Url, err)
+		}
+		if u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("%w: url %q must include scheme and host", ErrInvalidConfig, c.Url)
+		}
 	}
+	return nil
 }
 
 type OptionalConfig []*LLMConfig
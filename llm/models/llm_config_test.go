@@ -20,7 +20,7 @@ func TestOptionalConfigTestSuite(t *testing.T) {
 
 func (s *OptionalConfigTestSuite) SetupTest() {
 	s.originalEnvVars = make(map[string]string)
-	for _, provider := range []string{OPENAI, OLLAMA} {
+	for _, provider := range []string{OPENAI, OLLAMA, ANTHROPIC, GEMINI} {
 		for _, envVar := range []string{"URL", "API_KEY"} {
 			envVarName := fmt.Sprintf("%s_%s", strings.ToUpper(provider), strings.ToUpper(envVar))
 			// Save original environment variables
@@ -65,6 +65,13 @@ func (s *OptionalConfigTestSuite) TestAll() {
 		{name: "Ollama_EnvApiKeyDefined_UrlNot", provider: OLLAMA, envUrl: "", envApiKey: "my-key", configUrl: "", configApiKey: "", expectedUrl: "http://localhost:11434", expectedApiKey: "my-key"},
 		{name: "Ollama_ApiKeyAndUrlDefined", provider: OLLAMA, envUrl: "", envApiKey: "", configUrl: "https://custom.ollama.com/v1", configApiKey: "my-key", expectedUrl: "https://custom.ollama.com/v1", expectedApiKey: "my-key"},
 		{name: "Ollama_EnvApiKeyAndUrlDefined", provider: OLLAMA, envUrl: "https://custom.ollama.com/v1", envApiKey: "my-key", configUrl: "", configApiKey: "", expectedUrl: "https://custom.ollama.com/v1", expectedApiKey: "my-key"},
+		// Anthropic
+		{name: "Anthropic_NeitherDefined", provider: ANTHROPIC, envUrl: "", envApiKey: "", configUrl: "", configApiKey: "", expectedUrl: "https://api.anthropic.com/v1", expectedApiKey: ""},
+		{name: "Anthropic_EnvApiKeyDefined_UrlNot", provider: ANTHROPIC, envUrl: "", envApiKey: "my-key", configUrl: "", configApiKey: "", expectedUrl: "https://api.anthropic.com/v1", expectedApiKey: "my-key"},
+		{name: "Anthropic_EnvApiKeyAndUrlDefined", provider: ANTHROPIC, envUrl: "https://proxy.example.com/v1", envApiKey: "my-key", configUrl: "", configApiKey: "", expectedUrl: "https://proxy.example.com/v1", expectedApiKey: "my-key"},
+		// Gemini
+		{name: "Gemini_NeitherDefined", provider: GEMINI, envUrl: "", envApiKey: "", configUrl: "", configApiKey: "", expectedUrl: "https://generativelanguage.googleapis.com/v1beta", expectedApiKey: ""},
+		{name: "Gemini_EnvApiKeyDefined_UrlNot", provider: GEMINI, envUrl: "", envApiKey: "my-key", configUrl: "", configApiKey: "", expectedUrl: "https://generativelanguage.googleapis.com/v1beta", expectedApiKey: "my-key"},
 		// Unknown provider
 		{name: "UnknownProvider", provider: "unknown", envUrl: "", envApiKey: "", configUrl: "", configApiKey: "", expectedUrl: "", expectedApiKey: ""},
 	}
@@ -1,9 +1,27 @@
 package models
 
+import "slices"
+
 type Model struct {
-	ID string `json:"id"`
-	Name string `json:"name"`
-	Model string `json:"model"`
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Model       string `json:"model"`
 	Description string `json:"description"`
+	// ContextSize is the context window in tokens, or zero when unknown.
 	ContextSize int `json:"context_size"`
+	// Family is the model architecture or family, such as "llama" or "gpt-4o".
+	Family string `json:"family,omitempty"`
+	// ParameterSize is the size as reported by the provider, such as "8.0B".
+	ParameterSize string `json:"parameter_size,omitempty"`
+	// Quantization is the weight quantization level, such as "Q4_K_M".
+	Quantization string `json:"quantization,omitempty"`
+	// EmbeddingLength is the embedding dimension, or zero when unknown.
+	EmbeddingLength int `json:"embedding_length,omitempty"`
+	// Capabilities lists what the model supports. Nil means unknown.
+	Capabilities []Capability `json:"capabilities,omitempty"`
+}
+
+// HasCapability reports whether the model lists capability.
+func (m *Model) HasCapability(capability Capability) bool {
+	return slices.Contains(m.Capabilities, capability)
 }
@@ -1,12 +1,51 @@
 package models
 
+import (
+	"encoding/json"
+	"fmt"
+)
+
 type RequestOptions struct {
-	Temperature float64 `json:"temperature"`
-	TopP float64 `json:"top_p"`
-	MaxTokens int `json:"max_tokens"`
-	TopK int `json:"top_k"`
+	Temperature      float64 `json:"temperature"`
+	TopP             float64 `json:"top_p"`
+	MaxTokens        int     `json:"max_tokens"`
+	TopK             int     `json:"top_k"`
 	FrequencyPenalty float64 `json:"frequency_penalty"`
-	PresencePenalty float64 `json:"presence_penalty"`
+	PresencePenalty  float64 `json:"presence_penalty"`
+	// Stop lists sequences that end generation when produced.
+	Stop []string `json:"stop,omitempty"`
+	// Seed requests deterministic sampling where the provider supports it.
+	// It is a pointer because zero is a valid seed.
+	Seed *int `json:"seed,omitempty"`
+	// LogitBias maps token IDs to a bias added to their logits before
+	// sampling (OpenAI only).
+	LogitBias map[string]int `json:"logit_bias,omitempty"`
+	// Extra holds provider-specific parameters keyed by provider name
+	// (OPENAI, OLLAMA, ...). Each provider only reads its own entry: Ollama
+	// merges it into the model options (num_ctx, mirostat, ...), the others
+	// merge it into the request body.
+	Extra map[string]map[string]any `json:"extra,omitempty"`
+}
+
+// WithExtra sets a provider-specific parameter and returns the options for
+// chaining.
+func (o *RequestOptions) WithExtra(provider, key string, value any) *RequestOptions {
+	if o.Extra == nil {
+		o.Extra = make(map[string]map[string]any)
+	}
+	if o.Extra[provider] == nil {
+		o.Extra[provider] = make(map[string]any)
+	}
+	o.Extra[provider][key] = value
+	return o
+}
+
+// ExtraFor returns the provider-specific parameters for a provider, or nil.
+func (o *RequestOptions) ExtraFor(provider string) map[string]any {
+	if o == nil {
+		return nil
+	}
+	return o.Extra[provider]
 }
 
 func (o *RequestOptions) ToMap() map[string]interface{} {
@@ -32,5 +71,46 @@ func (o *RequestOptions) ToMap() map[string]interface{} {
 	if o.PresencePenalty != 0 {
 		result["presence_penalty"] = o.PresencePenalty
 	}
+	if len(o.Stop) > 0 {
+		result["stop"] = o.Stop
+	}
+	if o.Seed != nil {
+		result["seed"] = *o.Seed
+	}
+	if len(o.LogitBias) > 0 {
+		result["logit_bias"] = o.LogitBias
+	}
 	return result
 }
+
+// MergeExtra returns body with the extra fields merged into its JSON object.
+// Nested objects are merged key by key, so an extra can add to a field the
+// request already sets; any other value replaces the existing one. body is
+// returned unchanged when there is nothing to merge.
+func MergeExtra(body any, extra map[string]any) (any, error) {
+	if len(extra) == 0 {
+		return body, nil
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	var merged map[string]any
+	if err := json.Unmarshal(data, &merged); err != nil {
+		return nil, fmt.Errorf("request body is not a JSON object: %w", err)
+	}
+	mergeMaps(merged, extra)
+	return merged, nil
+}
+
+func mergeMaps(dst, src map[string]any) {
+	for key, value := range src {
+		srcMap, srcIsMap := value.(map[string]any)
+		dstMap, dstIsMap := dst[key].(map[string]any)
+		if srcIsMap && dstIsMap {
+			mergeMaps(dstMap, srcMap)
+			continue
+		}
+		dst[key] = value
+	}
+}
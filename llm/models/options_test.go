@@ -0,0 +1,70 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type RequestOptionsTestSuite struct {
+	suite.Suite
+}
+
+func TestRequestOptionsTestSuite(t *testing.T) {
+	suite.Run(t, new(RequestOptionsTestSuite))
+}
+
+func (s *RequestOptionsTestSuite) TestToMap() {
+	seed := 0
+	o := &RequestOptions{
+		Temperature: 0.5,
+		MaxTokens:   10,
+		Stop:        []string{"\n"},
+		Seed:        &seed,
+		LogitBias:   map[string]int{"50256": -100},
+	}
+	s.Equal(map[string]interface{}{
+		"temperature": 0.5,
+		"max_tokens":  10,
+		"stop":        []string{"\n"},
+		"seed":        0,
+		"logit_bias":  map[string]int{"50256": -100},
+	}, o.ToMap())
+
+	var nilOptions *RequestOptions
+	s.Empty(nilOptions.ToMap())
+}
+
+func (s *RequestOptionsTestSuite) TestExtra() {
+	o := &RequestOptions{}
+	s.Nil(o.ExtraFor(OLLAMA))
+	o.WithExtra(OLLAMA, "num_ctx", 8192).WithExtra(OLLAMA, "mirostat", 2)
+	s.Equal(map[string]any{"num_ctx": 8192, "mirostat": 2}, o.ExtraFor(OLLAMA))
+	s.Nil(o.ExtraFor(OPENAI))
+}
+
+func (s *RequestOptionsTestSuite) TestMergeExtra() {
+	type body struct {
+		Model  string         `json:"model"`
+		Config map[string]any `json:"config"`
+	}
+	b := body{Model: "m", Config: map[string]any{"a": 1}}
+
+	unchanged, err := MergeExtra(b, nil)
+	s.Require().NoError(err)
+	s.Equal(b, unchanged)
+
+	merged, err := MergeExtra(b, map[string]any{
+		"user":   "u1",
+		"config": map[string]any{"b": true},
+	})
+	s.Require().NoError(err)
+	s.Equal(map[string]any{
+		"model":  "m",
+		"user":   "u1",
+		"config": map[string]any{"a": float64(1), "b": true},
+	}, merged)
+
+	_, err = MergeExtra([]string{"x"}, map[string]any{"user": "u1"})
+	s.Error(err)
+}
@@ -0,0 +1,31 @@
+package models
+
+type ResponseFormatType string
+
+const (
+	ResponseFormatText       ResponseFormatType = "text"
+	ResponseFormatJSON       ResponseFormatType = "json_object"
+	ResponseFormatJSONSchema ResponseFormatType = "json_schema"
+)
+
+// ResponseFormat constrains the shape of the model's reply.
+// Schema is only used with ResponseFormatJSONSchema and may be anything that
+// marshals to a JSON-schema object (a map, json.RawMessage or a
+// jsonschema.Definition).
+type ResponseFormat struct {
+	Type   ResponseFormatType `json:"type"`
+	Name   string             `json:"name,omitempty"`
+	Schema any                `json:"schema,omitempty"`
+	Strict bool               `json:"strict,omitempty"`
+}
+
+// JSONSchemaFormat returns a JSON-schema response format. It is not strict,
+// matching llm/structured: OpenAI's strict mode rejects schemas with optional
+// properties, so callers opt in by setting Strict.
+func JSONSchemaFormat(name string, schema any) *ResponseFormat {
+	return &ResponseFormat{
+		Type:   ResponseFormatJSONSchema,
+		Name:   name,
+		Schema: schema,
+	}
+}
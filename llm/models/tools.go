@@ -0,0 +1,45 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Tool describes a function the model may call.
+// Parameters holds a JSON-schema object and may be anything that marshals
+// to JSON (a map, json.RawMessage or a jsonschema.Definition).
+type Tool struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Parameters  any    `json:"parameters,omitempty"`
+}
+
+// ToolCall is a single function invocation requested by the model.
+// Arguments is the raw JSON object produced by the model.
+type ToolCall struct {
+	ID        string `json:"id,omitempty"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// DecodeArguments unmarshals the call arguments into v.
+func (c *ToolCall) DecodeArguments(v any) error {
+	args := c.Arguments
+	if args == "" {
+		args = "{}"
+	}
+	if err := json.Unmarshal([]byte(args), v); err != nil {
+		return fmt.Errorf("failed to decode arguments of tool %s: %w", c.Name, err)
+	}
+	return nil
+}
+
+// NewToolResultMessage builds the tool-role message answering the given call.
+func NewToolResultMessage(call *ToolCall, content string) *Message {
+	return &Message{
+		Role:       ToolRole,
+		Content:    content,
+		ToolCallID: call.ID,
+		ToolName:   call.Name,
+	}
+}
@@ -2,56 +2,241 @@ package ollama
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"time"
 
 	"github.com/aqua777/ai-flow/llm/models"
 	"github.com/aqua777/ai-flow/llm/thinking"
 )
 
+type OllamaToolFunction struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Parameters  any    `json:"parameters,omitempty"`
+}
+
+type OllamaTool struct {
+	Type     string             `json:"type"`
+	Function OllamaToolFunction `json:"function"`
+}
+
+type OllamaToolCallFunction struct {
+	Index     int             `json:"index,omitempty"`
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+}
+
+type OllamaToolCall struct {
+	ID       string                 `json:"id,omitempty"`
+	Function OllamaToolCallFunction `json:"function"`
+}
+
+type OllamaMessage struct {
+	Role      models.Role       `json:"role"`
+	Content   string            `json:"content"`
+	Thinking  string            `json:"thinking,omitempty"`
+	Images    []string          `json:"images,omitempty"`
+	ToolCalls []*OllamaToolCall `json:"tool_calls,omitempty"`
+	ToolName  string            `json:"tool_name,omitempty"`
+}
+
 type OllamaChatCompletionRequest struct {
 	Model    string                 `json:"model"`
-	Messages []*models.Message      `json:"messages"`
+	Messages []*OllamaMessage       `json:"messages"`
+	Tools    []*OllamaTool          `json:"tools,omitempty"`
+	Format   json.RawMessage        `json:"format,omitempty"`
 	Stream   bool                   `json:"stream"`
 	Options  map[string]interface{} `json:"options,omitempty"`
 	Context  []int                  `json:"context,omitempty"`
 }
 
 type OllamaChatCompletionResponse struct {
-	Model              string          `json:"model"`
-	CreatedAt          time.Time       `json:"created_at"`
-	Message            *models.Message `json:"message,omitempty"`
-	Response           string          `json:"response,omitempty"`
-	Done               bool            `json:"done"`
-	DoneReason         string          `json:"done_reason,omitempty"`
-	Context            []int           `json:"context,omitempty"`
-	TotalDuration      int64           `json:"total_duration,omitempty"`
-	LoadDuration       int64           `json:"load_duration,omitempty"`
-	PromptEvalCount    int             `json:"prompt_eval_count,omitempty"`
-	PromptEvalDuration int64           `json:"prompt_eval_duration,omitempty"`
-	EvalCount          int             `json:"eval_count,omitempty"`
-	EvalDuration       int64           `json:"eval_duration,omitempty"`
+	Model              string         `json:"model"`
+	CreatedAt          time.Time      `json:"created_at"`
+	Message            *OllamaMessage `json:"message,omitempty"`
+	Response           string         `json:"response,omitempty"`
+	Done               bool           `json:"done"`
+	DoneReason         string         `json:"done_reason,omitempty"`
+	Context            []int          `json:"context,omitempty"`
+	TotalDuration      int64          `json:"total_duration,omitempty"`
+	LoadDuration       int64          `json:"load_duration,omitempty"`
+	PromptEvalCount    int            `json:"prompt_eval_count,omitempty"`
+	PromptEvalDuration int64          `json:"prompt_eval_duration,omitempty"`
+	EvalCount          int            `json:"eval_count,omitempty"`
+	EvalDuration       int64          `json:"eval_duration,omitempty"`
+	Error              string         `json:"error,omitempty"`
+}
+
+func toOllamaTools(tools []*models.Tool) []*OllamaTool {
+	if len(tools) == 0 {
+		return nil
+	}
+	result := make([]*OllamaTool, len(tools))
+	for i, tool := range tools {
+		result[i] = &OllamaTool{
+			Type: "function",
+			Function: OllamaToolFunction{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.Parameters,
+			},
+		}
+	}
+	return result
+}
+
+func toOllamaMessages(messages []*models.Message) ([]*OllamaMessage, error) {
+	result := make([]*OllamaMessage, len(messages))
+	for i, msg := range messages {
+		om := &OllamaMessage{
+			Role:     msg.Role,
+			Content:  msg.Content,
+			Thinking: msg.Thinking,
+			ToolName: msg.ToolName,
+		}
+		for _, image := range msg.Images {
+			data, err := image.Base64()
+			if err != nil {
+				return nil, fmt.Errorf("ollama accepts inline image data only: %w", err)
+			}
+			om.Images = append(om.Images, data)
+		}
+		for _, call := range msg.ToolCalls {
+			args := json.RawMessage(call.Arguments)
+			if len(args) == 0 {
+				args = json.RawMessage("{}")
+			} else if !json.Valid(args) {
+				return nil, fmt.Errorf("tool call %s has invalid JSON arguments", call.Name)
+			}
+			om.ToolCalls = append(om.ToolCalls, &OllamaToolCall{
+				ID:       call.ID,
+				Function: OllamaToolCallFunction{Name: call.Name, Arguments: args},
+			})
+		}
+		result[i] = om
+	}
+	return result, nil
+}
+
+func fromOllamaToolCalls(calls []*OllamaToolCall) []*models.ToolCall {
+	if len(calls) == 0 {
+		return nil
+	}
+	result := make([]*models.ToolCall, len(calls))
+	for i, call := range calls {
+		args := string(call.Function.Arguments)
+		if args == "" || args == "null" {
+			args = "{}"
+		}
+		id := call.ID
+		if id == "" {
+			id = fmt.Sprintf("call_%d", i)
+		}
+		result[i] = &models.ToolCall{
+			ID:        id,
+			Name:      call.Function.Name,
+			Arguments: args,
+		}
+	}
+	return result
 }
 
 func (o *Client) Chat(ctx context.Context, r *models.ChatRequest, stream ...func(chunk []byte) error) (*models.ChatResponse, error) {
+	messages, err := toOllamaMessages(r.Messages)
+	if err != nil {
+		return nil, err
+	}
+	format, err := toOllamaFormat(r.ResponseFormat)
+	if err != nil {
+		return nil, err
+	}
+	if models.HasImages(r.Messages) {
+		if err := o.checkVision(ctx, r.Model); err != nil {
+			return nil, err
+		}
+	}
 	req := OllamaChatCompletionRequest{
 		Model:    r.Model,
-		Messages: r.Messages,
-		Stream:   r.Stream,
-		Options:  r.Options.ToMap(),
+		Messages: messages,
+		Tools:    toOllamaTools(r.Tools),
+		Format:   format,
+		Stream:   len(stream) > 0 && stream[0] != nil,
+		Options:  toOllamaOptions(&r.Options),
+	}
+	var resp *OllamaChatCompletionResponse
+	if req.Stream {
+		resp, err = o.streamChat(ctx, req, stream[0])
+	} else {
+		resp = new(OllamaChatCompletionResponse)
+		err = o.client.Post(ctx, "/api/chat", req, resp, nil)
 	}
-	resp := new(OllamaChatCompletionResponse)
-	err := o.client.Post(ctx, "/api/chat", req, resp, nil)
 	if err != nil {
 		return nil, err
 	}
+	if resp.Message == nil {
+		return nil, fmt.Errorf("no message found in the response")
+	}
 	content, thinking := thinking.ProcessContent(resp.Message.Content)
+	if resp.Message.Thinking != "" {
+		thinking = resp.Message.Thinking
+	}
 	return &models.ChatResponse{
 		Content:   content,
 		Reasoning: thinking,
+		ToolCalls: fromOllamaToolCalls(resp.Message.ToolCalls),
 		Metadata: &models.ChatResponseMetadata{
 			PromptTokens:     resp.PromptEvalCount,
 			CompletionTokens: resp.EvalCount,
 			TotalTokens:      resp.PromptEvalCount + resp.EvalCount,
 		},
+		FinishReason: toFinishReason(resp.DoneReason, len(resp.Message.ToolCalls) > 0),
 	}, nil
 }
+
+// streamChat reads the NDJSON stream of /api/chat, forwarding the answer
+// tokens to callback. Reasoning, whether in message.thinking or inline
+// <think> tags, is kept out of the callback. It folds the chunks into a single
+// response whose message holds the accumulated content, thinking and tool
+// calls, and whose counters come from the final chunk.
+func (o *Client) streamChat(ctx context.Context, req OllamaChatCompletionRequest, callback func(chunk []byte) error) (*OllamaChatCompletionResponse, error) {
+	var result *OllamaChatCompletionResponse
+	var toolCalls []*OllamaToolCall
+	parser := thinking.NewStreamParser()
+	err := o.client.PostStream(ctx, "/api/chat", req, nil, func(line []byte) error {
+		chunk := new(OllamaChatCompletionResponse)
+		if err := json.Unmarshal(line, chunk); err != nil {
+			return fmt.Errorf("failed to decode stream chunk: %w", err)
+		}
+		if chunk.Error != "" {
+			return streamError(chunk.Error)
+		}
+		if msg := chunk.Message; msg != nil {
+			parser.WriteReasoning(msg.Thinking)
+			toolCalls = append(toolCalls, msg.ToolCalls...)
+			if err := thinking.ForwardAnswer(parser.Write(msg.Content), callback); err != nil {
+				return err
+			}
+		}
+		if chunk.Done {
+			result = chunk
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if result == nil {
+		return nil, fmt.Errorf("stream ended before completion")
+	}
+	if err := thinking.ForwardAnswer(parser.Flush(), callback); err != nil {
+		return nil, err
+	}
+	result.Message = &OllamaMessage{
+		Role:      models.AssistantRole,
+		Content:   parser.Content(),
+		Thinking:  parser.Reasoning(),
+		ToolCalls: toolCalls,
+	}
+	return result, nil
+}
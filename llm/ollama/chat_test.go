@@ -0,0 +1,296 @@
+package ollama
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/aqua777/ai-flow/llm/models"
+)
+
+type ChatTestSuite struct {
+	suite.Suite
+	server  *httptest.Server
+	handler http.HandlerFunc
+	lastReq *OllamaChatCompletionRequest
+	client  *Client
+}
+
+func TestChatTestSuite(t *testing.T) {
+	suite.Run(t, new(ChatTestSuite))
+}
+
+func (s *ChatTestSuite) SetupTest() {
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/chat" {
+			s.lastReq = new(OllamaChatCompletionRequest)
+			s.Require().NoError(json.NewDecoder(r.Body).Decode(s.lastReq))
+		}
+		s.handler(w, r)
+	}))
+	client, err := NewClient(&models.LLMConfig{Url: s.server.URL})
+	s.Require().NoError(err)
+	s.client = client
+}
+
+func (s *ChatTestSuite) TearDownTest() {
+	s.server.Close()
+}
+
+func (s *ChatTestSuite) TestChat_ToolCalls() {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"model":"llama3.1","message":{"role":"assistant","content":"","tool_calls":[{"function":{"name":"get_weather","arguments":{"city":"Paris"}}}]},"done":true,"prompt_eval_count":7,"eval_count":3}`)
+	}
+	call := &models.ToolCall{ID: "call_0", Name: "get_time", Arguments: `{"tz":"UTC"}`}
+	resp, err := s.client.Chat(context.Background(), &models.ChatRequest{
+		Model: "llama3.1",
+		Messages: []*models.Message{
+			{Role: models.UserRole, Content: "Weather in Paris?"},
+			{Role: models.AssistantRole, ToolCalls: []*models.ToolCall{call}},
+			models.NewToolResultMessage(call, "12:00"),
+		},
+		Tools: []*models.Tool{{Name: "get_weather", Parameters: map[string]any{"type": "object"}}},
+	})
+	s.Require().NoError(err)
+	s.Require().Len(resp.ToolCalls, 1)
+	s.Equal("get_weather", resp.ToolCalls[0].Name)
+	s.JSONEq(`{"city":"Paris"}`, resp.ToolCalls[0].Arguments)
+	s.Equal(10, resp.Metadata.TotalTokens)
+
+	s.Require().Len(s.lastReq.Tools, 1)
+	s.Equal("function", s.lastReq.Tools[0].Type)
+	s.Equal("get_weather", s.lastReq.Tools[0].Function.Name)
+	s.JSONEq(`{"tz":"UTC"}`, string(s.lastReq.Messages[1].ToolCalls[0].Function.Arguments))
+	s.Equal(models.ToolRole, s.lastReq.Messages[2].Role)
+	s.Equal("get_time", s.lastReq.Messages[2].ToolName)
+}
+
+func (s *ChatTestSuite) TestChat_InvalidToolArguments() {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {}
+	_, err := s.client.Chat(context.Background(), &models.ChatRequest{
+		Model: "llama3.1",
+		Messages: []*models.Message{
+			{Role: models.AssistantRole, ToolCalls: []*models.ToolCall{{Name: "broken", Arguments: "{"}}},
+		},
+	})
+	s.Error(err)
+	s.Nil(s.lastReq)
+}
+
+func (s *ChatTestSuite) TestChat_Stream() {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		fmt.Fprintln(w, `{"model":"qwen3","message":{"role":"assistant","content":"","thinking":"Let me think."},"done":false}`)
+		fmt.Fprintln(w, `{"model":"qwen3","message":{"role":"assistant","content":"Hello"},"done":false}`)
+		fmt.Fprintln(w, `{"model":"qwen3","message":{"role":"assistant","content":" world"},"done":false}`)
+		fmt.Fprintln(w, `{"model":"qwen3","message":{"role":"assistant","content":""},"done":true,"done_reason":"stop","prompt_eval_count":4,"eval_count":2}`)
+	}
+	var chunks []string
+	resp, err := s.client.Chat(context.Background(), &models.ChatRequest{
+		Model:    "qwen3",
+		Messages: []*models.Message{{Role: models.UserRole, Content: "hi"}},
+	}, func(chunk []byte) error {
+		chunks = append(chunks, string(chunk))
+		return nil
+	})
+	s.Require().NoError(err)
+	s.True(s.lastReq.Stream)
+	s.Equal([]string{"Hello", " world"}, chunks)
+	s.Equal("Hello world", resp.Content)
+	s.Equal("Let me think.", resp.Reasoning)
+	s.Equal(&models.ChatResponseMetadata{PromptTokens: 4, CompletionTokens: 2, TotalTokens: 6}, resp.Metadata)
+	s.Equal(models.FinishReasonStop, resp.FinishReason)
+}
+
+func (s *ChatTestSuite) TestChat_StreamInlineThinking() {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		for _, content := range []string{"<thi", "nk>Plan it.</th", "ink>\n\nDone", "."} {
+			fmt.Fprintf(w, `{"model":"deepseek-r1","message":{"role":"assistant","content":%q},"done":false}`+"\n", content)
+		}
+		fmt.Fprintln(w, `{"model":"deepseek-r1","message":{"role":"assistant","content":""},"done":true,"done_reason":"stop"}`)
+	}
+	var chunks []string
+	resp, err := s.client.Chat(context.Background(), &models.ChatRequest{Model: "deepseek-r1"}, func(chunk []byte) error {
+		chunks = append(chunks, string(chunk))
+		return nil
+	})
+	s.Require().NoError(err)
+	s.Equal([]string{"Done", "."}, chunks)
+	s.Equal("Done.", resp.Content)
+	s.Equal("Plan it.", resp.Reasoning)
+}
+
+func (s *ChatTestSuite) TestChat_StreamError() {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"model":"qwen3","message":{"role":"assistant","content":"Hel"},"done":false}`)
+		fmt.Fprintln(w, `{"error":"model crashed"}`)
+	}
+	_, err := s.client.Chat(context.Background(), &models.ChatRequest{Model: "qwen3"}, func(chunk []byte) error { return nil })
+	s.ErrorContains(err, "model crashed")
+}
+
+func (s *ChatTestSuite) TestChat_StreamCancelled() {
+	release := make(chan struct{})
+	defer close(release)
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"model":"qwen3","message":{"role":"assistant","content":"Hel"},"done":false}`)
+		w.(http.Flusher).Flush()
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	_, err := s.client.Chat(ctx, &models.ChatRequest{Model: "qwen3"}, func(chunk []byte) error {
+		cancel()
+		return nil
+	})
+	s.ErrorIs(err, context.Canceled)
+}
+
+func (s *ChatTestSuite) TestGenerate_Stream() {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		var req OllamaGenerateRequest
+		s.Require().NoError(json.NewDecoder(r.Body).Decode(&req))
+		s.True(req.Stream)
+		fmt.Fprintln(w, `{"model":"llama3","response":"The sky","done":false}`)
+		fmt.Fprintln(w, `{"model":"llama3","response":" is blue.","done":false}`)
+		fmt.Fprintln(w, `{"model":"llama3","response":"","done":true,"done_reason":"stop","prompt_eval_count":5,"eval_count":4}`)
+	}
+	var chunks []string
+	resp, err := s.client.Generate(context.Background(), &models.GenerateRequest{Model: "llama3", Prompt: "Why?"}, func(chunk []byte) error {
+		chunks = append(chunks, string(chunk))
+		return nil
+	})
+	s.Require().NoError(err)
+	s.Equal([]string{"The sky", " is blue."}, chunks)
+	s.Equal("The sky is blue.", resp.Text)
+	s.Equal("llama3", resp.Model)
+	s.Equal(9, resp.TotalTokens)
+	s.Equal(models.FinishReasonStop, resp.FinishReason)
+}
+
+func (s *ChatTestSuite) TestGenerate_StreamTruncated() {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"model":"llama3","response":"The sky","done":false}`)
+	}
+	_, err := s.client.Generate(context.Background(), &models.GenerateRequest{Model: "llama3"}, func(chunk []byte) error { return nil })
+	s.ErrorContains(err, "before completion")
+}
+
+func (s *ChatTestSuite) TestChat_ResponseFormat() {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"model":"llama3","message":{"role":"assistant","content":"{\"ok\":true}"},"done":true}`)
+	}
+	schema := map[string]any{"type": "object", "properties": map[string]any{"ok": map[string]any{"type": "boolean"}}}
+	_, err := s.client.Chat(context.Background(), &models.ChatRequest{
+		Model:          "llama3",
+		ResponseFormat: models.JSONSchemaFormat("result", schema),
+	})
+	s.Require().NoError(err)
+	s.JSONEq(`{"type":"object","properties":{"ok":{"type":"boolean"}}}`, string(s.lastReq.Format))
+
+	_, err = s.client.Chat(context.Background(), &models.ChatRequest{
+		Model:          "llama3",
+		ResponseFormat: &models.ResponseFormat{Type: models.ResponseFormatJSON},
+	})
+	s.Require().NoError(err)
+	s.Equal(`"json"`, string(s.lastReq.Format))
+}
+
+func (s *ChatTestSuite) TestChat_Options() {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"model":"llama3","message":{"role":"assistant","content":"ok"},"done":true}`)
+	}
+	seed := 42
+	options := models.RequestOptions{
+		Temperature: 0.4,
+		MaxTokens:   128,
+		Stop:        []string{"END"},
+		Seed:        &seed,
+		LogitBias:   map[string]int{"1": 5},
+	}
+	options.WithExtra(models.OLLAMA, "num_ctx", 8192).WithExtra(models.OLLAMA, "mirostat", 2)
+	_, err := s.client.Chat(context.Background(), &models.ChatRequest{Model: "llama3", Options: options})
+	s.Require().NoError(err)
+	s.Equal(map[string]interface{}{
+		"temperature": 0.4,
+		"num_predict": float64(128),
+		"stop":        []any{"END"},
+		"seed":        float64(42),
+		"num_ctx":     float64(8192),
+		"mirostat":    float64(2),
+	}, s.lastReq.Options)
+}
+
+func (s *ChatTestSuite) TestChat_Images() {
+	capabilities := `["completion","vision"]`
+	shows := 0
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/show" {
+			shows++
+			fmt.Fprintf(w, `{"capabilities":%s}`, capabilities)
+			return
+		}
+		fmt.Fprint(w, `{"model":"llava","message":{"role":"assistant","content":"A cat."},"done":true}`)
+	}
+	req := &models.ChatRequest{
+		Model: "llava",
+		Messages: []*models.Message{{
+			Role:    models.UserRole,
+			Content: "What is this?",
+			Images:  []*models.Image{models.NewImage([]byte("png-bytes"), "image/png"), models.NewImageURL("data:image/png;base64,AAAA")},
+		}},
+	}
+	resp, err := s.client.Chat(context.Background(), req)
+	s.Require().NoError(err)
+	s.Equal("A cat.", resp.Content)
+	s.Equal([]string{"cG5nLWJ5dGVz", "AAAA"}, s.lastReq.Messages[0].Images)
+
+	_, err = s.client.Chat(context.Background(), req)
+	s.Require().NoError(err)
+	s.Equal(1, shows, "capabilities should be cached per model")
+
+	capabilities = `["completion"]`
+	req.Model = "llama3"
+	s.lastReq = nil
+	_, err = s.client.Chat(context.Background(), req)
+	s.ErrorIs(err, models.ErrImagesNotSupported)
+	s.Nil(s.lastReq)
+
+	req.Model = "llava"
+	req.Messages[0].Images = []*models.Image{models.NewImageURL("https://example.com/cat.png")}
+	_, err = s.client.Chat(context.Background(), req)
+	s.ErrorContains(err, "inline image data")
+}
+
+func (s *ChatTestSuite) TestChat_Errors() {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"error":"model 'llama9' not found, try pulling it first"}`)
+	}
+	_, err := s.client.Chat(context.Background(), &models.ChatRequest{Model: "llama9"})
+	s.ErrorIs(err, models.ErrModelNotFound)
+	var providerErr *models.ProviderError
+	s.Require().ErrorAs(err, &providerErr)
+	s.Equal(models.OLLAMA, providerErr.Provider)
+	s.Equal(http.StatusNotFound, providerErr.StatusCode)
+	s.False(providerErr.Retryable)
+
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "2")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprint(w, `{"error":"server busy"}`)
+	}
+	_, err = s.client.Chat(context.Background(), &models.ChatRequest{Model: "qwen3"})
+	s.ErrorIs(err, models.ErrUnavailable)
+	s.Require().ErrorAs(err, &providerErr)
+	s.True(providerErr.Retryable)
+	s.Equal(2*time.Second, providerErr.RetryAfter)
+}
@@ -2,6 +2,9 @@ package ollama
 
 import (
 	"context"
+	"fmt"
+	"slices"
+	"sync"
 
 	"github.com/aqua777/ai-flow/http"
 	"github.com/aqua777/ai-flow/llm/models"
@@ -28,8 +31,52 @@ import (
 type Client struct {
 	config *models.LLMConfig
 	client *http.JsonClient
+	// capabilities caches the capability list reported by /api/show per model.
+	capabilities sync.Map
 }
 
+type OllamaShowRequest struct {
+	Model string `json:"model"`
+}
+
+func (o *Client) showModel(ctx context.Context, name string) (*OllamaModel, error) {
+	var response OllamaModel
+	err := o.client.Post(ctx, "/api/show", OllamaShowRequest{Model: name}, &response, nil)
+	if err != nil {
+		return nil, err
+	}
+	if response.Name == "" {
+		response.Name = name
+	}
+	if response.Model == "" {
+		response.Model = name
+	}
+	o.capabilities.Store(name, response.Capabilities)
+	return &response, nil
+}
+
+// checkVision fails with models.ErrImagesNotSupported when the model reports
+// its capabilities and "vision" is not among them. Older servers that do not
+// report capabilities are given the benefit of the doubt.
+func (o *Client) checkVision(ctx context.Context, name string) error {
+	var capabilities []string
+	if cached, ok := o.capabilities.Load(name); ok {
+		capabilities = cached.([]string)
+	} else if model, err := o.showModel(ctx, name); err == nil {
+		capabilities = model.Capabilities
+	}
+	if len(capabilities) > 0 && !slices.Contains(capabilities, "vision") {
+		return fmt.Errorf("%w: %s", models.ErrImagesNotSupported, name)
+	}
+	return nil
+}
+
+// showConcurrency bounds the /api/show calls ListModels makes in parallel.
+const showConcurrency = 4
+
+// ListModels lists the local models from /api/tags and fills in context
+// length, embedding length and capabilities from /api/show. Models whose
+// details cannot be loaded are returned with what /api/tags reports.
 func (o *Client) ListModels(ctx context.Context) ([]*models.Model, error) {
 	var response ListModelResponse
 	err := o.client.Get(ctx, "/api/tags", &response, nil)
@@ -37,22 +84,46 @@ func (o *Client) ListModels(ctx context.Context) ([]*models.Model, error) {
 		return nil, err
 	}
 	results := make([]*models.Model, len(response.Models))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, showConcurrency)
 	for idx, model := range response.Models {
-		results[idx] = &models.Model{
-			ID:    model.Name,
-			Name:  model.Name,
-			Model: model.Model,
-		}
+		results[idx] = toModel(model)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			details, err := o.showModel(ctx, model.Name)
+			if err != nil {
+				return
+			}
+			details.Name, details.Model = model.Name, model.Model
+			results[idx] = toModel(details)
+		}()
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
 	return results, nil
 }
 
+// GetModel returns the metadata /api/show reports for name.
+func (o *Client) GetModel(ctx context.Context, name string) (*models.Model, error) {
+	details, err := o.showModel(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return toModel(details), nil
+}
+
 func NewClient(optionalConfig ...*models.LLMConfig) (*Client, error) {
 	config := models.OptionalConfig(optionalConfig).GetConfig(models.OLLAMA)
 	client, err := http.NewJsonClient(config.Url)
 	if err != nil {
 		return nil, err
 	}
+	client.ErrorMapper = toProviderError
 	return &Client{
 		config: config,
 		client: client,
@@ -3,16 +3,23 @@ package ollama
 import (
 	"context"
 	"fmt"
+
+	"github.com/aqua777/ai-flow/llm/batch"
 	"github.com/aqua777/ai-flow/llm/models"
 )
 
+// MaxEmbeddingsBatchSize caps the inputs sent in a single /api/embed call.
+const MaxEmbeddingsBatchSize = 512
+
 type OllamaEmbeddingRequest struct {
 	Model string `json:"model"`
-	Input string `json:"input"`
+	// Input is either a single string or a []string.
+	Input      any `json:"input"`
+	Dimensions int `json:"dimensions,omitempty"`
 }
 
 type OllamaEmbeddingResponse struct {
-	Model string `json:"model"`
+	Model      string      `json:"model"`
 	Embeddings [][]float32 `json:"embeddings"`
 }
 
@@ -33,3 +40,18 @@ func (o *Client) Embeddings(ctx context.Context, cr *models.EmbeddingsRequest) (
 	}
 	return result, nil
 }
+
+func (o *Client) BatchEmbeddings(ctx context.Context, r *models.BatchEmbeddingsRequest) (*models.BatchEmbeddingsResponse, error) {
+	return batch.Embed(ctx, r, MaxEmbeddingsBatchSize, func(ctx context.Context, inputs []string) ([][]float32, error) {
+		req := OllamaEmbeddingRequest{
+			Model:      r.Model,
+			Input:      inputs,
+			Dimensions: r.Dimensions,
+		}
+		var resp OllamaEmbeddingResponse
+		if err := o.client.Post(ctx, "/api/embed", req, &resp, nil); err != nil {
+			return nil, err
+		}
+		return resp.Embeddings, nil
+	})
+}
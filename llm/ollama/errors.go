@@ -0,0 +1,25 @@
+package ollama
+
+import (
+	"github.com/aqua777/ai-flow/http"
+	"github.com/aqua777/ai-flow/llm/models"
+)
+
+// toProviderError maps an Ollama error response onto models.ProviderError.
+// Ollama reports failures as {"error": "..."} without a code, so they are
+// classified by status and message.
+func toProviderError(err *http.StatusError) error {
+	message := err.Message
+	if message == "" {
+		message = string(err.Body)
+	}
+	providerErr := models.NewProviderError(models.OLLAMA, err.StatusCode, "", message, err)
+	providerErr.Body = err.Body
+	providerErr.RetryAfter = err.RetryAfter
+	return providerErr
+}
+
+// streamError maps an error reported inside a streamed response.
+func streamError(message string) error {
+	return models.NewProviderError(models.OLLAMA, 0, "", "stream error: "+message, nil)
+}
@@ -0,0 +1,33 @@
+package ollama
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/aqua777/ai-flow/llm/models"
+)
+
+// toOllamaFormat maps a response format onto the "format" field, which takes
+// either the string "json" or a JSON-schema object.
+func toOllamaFormat(rf *models.ResponseFormat) (json.RawMessage, error) {
+	if rf == nil {
+		return nil, nil
+	}
+	switch rf.Type {
+	case "", models.ResponseFormatText:
+		return nil, nil
+	case models.ResponseFormatJSON:
+		return json.RawMessage(`"json"`), nil
+	case models.ResponseFormatJSONSchema:
+		if rf.Schema == nil {
+			return nil, fmt.Errorf("response format %s requires a schema", rf.Type)
+		}
+		data, err := json.Marshal(rf.Schema)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal response schema: %w", err)
+		}
+		return data, nil
+	default:
+		return nil, fmt.Errorf("unsupported response format type: %s", rf.Type)
+	}
+}
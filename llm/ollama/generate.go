@@ -2,49 +2,101 @@ package ollama
 
 import (
 	"context"
-	"log/slog"
+	"encoding/json"
+	"fmt"
 	"time"
+
 	"github.com/aqua777/ai-flow/llm/models"
+	"github.com/aqua777/ai-flow/llm/thinking"
 )
 
 type OllamaGenerateRequest struct {
-	Model string `json:"model"`
-	Prompt string `json:"prompt"`
-	Stream bool `json:"stream"`
-	Options  map[string]interface{} `json:"options,omitempty"`
+	Model   string                 `json:"model"`
+	Prompt  string                 `json:"prompt"`
+	Format  json.RawMessage        `json:"format,omitempty"`
+	Stream  bool                   `json:"stream"`
+	Options map[string]interface{} `json:"options,omitempty"`
 }
 
 type OllamaGenerateResponse struct {
-	Response string `json:"response"`
-	Model string `json:"model"`
-	CreatedAt time.Time `json:"created_at"`
-	Done bool `json:"done"`
-	DoneReason string `json:"done_reason"`
+	Response   string    `json:"response"`
+	Thinking   string    `json:"thinking,omitempty"`
+	Model      string    `json:"model"`
+	CreatedAt  time.Time `json:"created_at"`
+	Done       bool      `json:"done"`
+	DoneReason string    `json:"done_reason"`
 	// Context []int `json:"context"`
-	PromptEvalCount int `json:"prompt_eval_count"`
-	EvalCount int `json:"eval_count"`
+	PromptEvalCount int    `json:"prompt_eval_count"`
+	EvalCount       int    `json:"eval_count"`
+	Error           string `json:"error,omitempty"`
 }
 
-func (o *Client) Generate(ctx context.Context, r *models.GenerateRequest) (*models.GenerateResponse, error) {
+func (o *Client) Generate(ctx context.Context, r *models.GenerateRequest, stream ...func(chunk []byte) error) (*models.GenerateResponse, error) {
+	format, err := toOllamaFormat(r.ResponseFormat)
+	if err != nil {
+		return nil, err
+	}
 	req := OllamaGenerateRequest{
-		Model: r.Model,
-		Prompt: r.Prompt,
-		Stream: r.Stream,
-		Options: r.Options.ToMap(),
+		Model:   r.Model,
+		Prompt:  r.Prompt,
+		Format:  format,
+		Stream:  len(stream) > 0 && stream[0] != nil,
+		Options: toOllamaOptions(&r.Options),
 	}
-	slog.Info("Generate request", "request", req)
 	var resp OllamaGenerateResponse
-	err := o.client.Post(ctx, "/api/generate", req, &resp, nil)
+	if req.Stream {
+		resp, err = o.streamGenerate(ctx, req, stream[0])
+	} else {
+		err = o.client.Post(ctx, "/api/generate", req, &resp, nil)
+		resp.Response, _ = thinking.ProcessContent(resp.Response)
+	}
 	if err != nil {
 		return nil, err
 	}
-	slog.Info("Generate response", "response", resp)
 	return &models.GenerateResponse{
-		Text: resp.Response,
-		Model: resp.Model,
-		CreatedAt: resp.CreatedAt,
-		PromptTokens: resp.PromptEvalCount,
+		Text:             resp.Response,
+		Model:            resp.Model,
+		CreatedAt:        resp.CreatedAt,
+		PromptTokens:     resp.PromptEvalCount,
 		CompletionTokens: resp.EvalCount,
-		TotalTokens: resp.PromptEvalCount + resp.EvalCount,
+		TotalTokens:      resp.PromptEvalCount + resp.EvalCount,
+		FinishReason:     toFinishReason(resp.DoneReason, false),
 	}, nil
-}
\ No newline at end of file
+}
+
+// streamGenerate reads the NDJSON stream of /api/generate, forwarding the
+// answer tokens to callback with any reasoning left out. The returned response
+// carries the full answer and the counters reported by the final chunk.
+func (o *Client) streamGenerate(ctx context.Context, req OllamaGenerateRequest, callback func(chunk []byte) error) (OllamaGenerateResponse, error) {
+	var result OllamaGenerateResponse
+	parser := thinking.NewStreamParser()
+	err := o.client.PostStream(ctx, "/api/generate", req, nil, func(line []byte) error {
+		var chunk OllamaGenerateResponse
+		if err := json.Unmarshal(line, &chunk); err != nil {
+			return fmt.Errorf("failed to decode stream chunk: %w", err)
+		}
+		if chunk.Error != "" {
+			return streamError(chunk.Error)
+		}
+		parser.WriteReasoning(chunk.Thinking)
+		if err := thinking.ForwardAnswer(parser.Write(chunk.Response), callback); err != nil {
+			return err
+		}
+		if chunk.Done {
+			result = chunk
+		}
+		return nil
+	})
+	if err != nil {
+		return result, err
+	}
+	if !result.Done {
+		return result, fmt.Errorf("stream ended before completion")
+	}
+	if err := thinking.ForwardAnswer(parser.Flush(), callback); err != nil {
+		return result, err
+	}
+	result.Response = parser.Content()
+	result.Thinking = parser.Reasoning()
+	return result, nil
+}
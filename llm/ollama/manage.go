@@ -0,0 +1,193 @@
+package ollama
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aqua777/ai-flow/llm/models"
+)
+
+// ProgressEvent is one status update streamed by /api/pull and /api/create.
+// Total and Completed are byte counts for the layer named by Digest and are
+// zero for status-only events such as "verifying sha256 digest".
+type ProgressEvent struct {
+	Status    string `json:"status"`
+	Digest    string `json:"digest,omitempty"`
+	Total     int64  `json:"total,omitempty"`
+	Completed int64  `json:"completed,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Done reports whether this is the final event of a successful operation.
+func (e *ProgressEvent) Done() bool {
+	return e.Status == "success"
+}
+
+type PullRequest struct {
+	Model string `json:"model"`
+	// Insecure allows pulling from a registry without verified TLS.
+	Insecure bool `json:"insecure,omitempty"`
+	Stream   bool `json:"stream"`
+}
+
+// CreateRequest creates a model from an existing one. It mirrors the body of
+// /api/create; see ParseModelfile to build one from a Modelfile.
+type CreateRequest struct {
+	Model      string           `json:"model"`
+	From       string           `json:"from,omitempty"`
+	Template   string           `json:"template,omitempty"`
+	System     string           `json:"system,omitempty"`
+	License    []string         `json:"license,omitempty"`
+	Parameters map[string]any   `json:"parameters,omitempty"`
+	Messages   []*CreateMessage `json:"messages,omitempty"`
+	// Quantize converts a non-quantized model, e.g. "q4_K_M".
+	Quantize string `json:"quantize,omitempty"`
+	Stream   bool   `json:"stream"`
+}
+
+// CreateMessage is an example conversation turn baked into a created model.
+type CreateMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type CopyRequest struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+}
+
+type DeleteRequest struct {
+	Model string `json:"model"`
+}
+
+type keepAliveRequest struct {
+	Model     string `json:"model"`
+	KeepAlive string `json:"keep_alive,omitempty"`
+	Stream    bool   `json:"stream"`
+}
+
+var errIncompleteProgress = errors.New("stream ended before success")
+
+// Pull downloads model from the registry, passing each progress event to
+// progress, which may be nil. Returning an error from progress or cancelling
+// ctx aborts the download; the server keeps completed layers for next time.
+func (o *Client) Pull(ctx context.Context, model string, progress func(event ProgressEvent) error) error {
+	return o.streamProgress(ctx, "/api/pull", PullRequest{Model: model, Stream: true}, progress)
+}
+
+// Create builds a new model as described by r, passing each progress event to
+// progress, which may be nil.
+func (o *Client) Create(ctx context.Context, r *CreateRequest, progress func(event ProgressEvent) error) error {
+	req := *r
+	req.Stream = true
+	return o.streamProgress(ctx, "/api/create", req, progress)
+}
+
+// CreateFromModelfile parses modelfile and creates model from it.
+func (o *Client) CreateFromModelfile(ctx context.Context, model, modelfile string, progress func(event ProgressEvent) error) error {
+	req, err := ParseModelfile(modelfile)
+	if err != nil {
+		return err
+	}
+	req.Model = model
+	return o.Create(ctx, req, progress)
+}
+
+// streamProgress posts req to path and reads the NDJSON progress stream until
+// the server reports success.
+func (o *Client) streamProgress(ctx context.Context, path string, req any, progress func(event ProgressEvent) error) error {
+	done := false
+	err := o.client.PostStream(ctx, path, req, nil, func(line []byte) error {
+		var event ProgressEvent
+		if err := json.Unmarshal(line, &event); err != nil {
+			return fmt.Errorf("failed to decode progress event: %w", err)
+		}
+		if event.Error != "" {
+			return streamError(event.Error)
+		}
+		done = event.Done()
+		if progress != nil {
+			return progress(event)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if !done {
+		return fmt.Errorf("%s: %w", path, errIncompleteProgress)
+	}
+	return nil
+}
+
+// Delete removes model and any data not shared with other models.
+func (o *Client) Delete(ctx context.Context, model string) error {
+	return o.client.Delete(ctx, "/api/delete", DeleteRequest{Model: model}, nil, nil)
+}
+
+// Copy creates destination as another name for source.
+func (o *Client) Copy(ctx context.Context, source, destination string) error {
+	return o.client.Post(ctx, "/api/copy", CopyRequest{Source: source, Destination: destination}, nil, nil)
+}
+
+// Show returns the details, Modelfile parameters, template and capabilities
+// of model. Unknown models fail with models.ErrModelNotFound.
+func (o *Client) Show(ctx context.Context, model string) (*OllamaModel, error) {
+	return o.showModel(ctx, model)
+}
+
+// Running lists the models currently loaded in memory.
+func (o *Client) Running(ctx context.Context) ([]*RunningModel, error) {
+	var response RunningModelsResponse
+	if err := o.client.Get(ctx, "/api/ps", &response, nil); err != nil {
+		return nil, err
+	}
+	return response.Models, nil
+}
+
+// Load loads model into memory and keeps it there for keepAlive after its
+// last use. A negative keepAlive keeps it loaded until Unload; zero uses the
+// server's default.
+func (o *Client) Load(ctx context.Context, model string, keepAlive time.Duration) error {
+	req := keepAliveRequest{Model: model}
+	if keepAlive != 0 {
+		req.KeepAlive = keepAlive.String()
+	}
+	return o.postUntimed(ctx, "/api/generate", req)
+}
+
+// Unload evicts model from memory.
+func (o *Client) Unload(ctx context.Context, model string) error {
+	return o.postUntimed(ctx, "/api/generate", keepAliveRequest{Model: model, KeepAlive: "0s"})
+}
+
+// postUntimed posts req to path through the streaming client, which has no
+// request timeout: loading a large model can take minutes, so only ctx
+// bounds the call.
+func (o *Client) postUntimed(ctx context.Context, path string, req any) error {
+	return o.client.PostStream(ctx, path, req, nil, func(line []byte) error {
+		var resp struct {
+			Error string `json:"error"`
+		}
+		if err := json.Unmarshal(line, &resp); err != nil {
+			return fmt.Errorf("failed to decode response: %w", err)
+		}
+		if resp.Error != "" {
+			return streamError(resp.Error)
+		}
+		return nil
+	})
+}
+
+// EnsureModel pulls model unless it is already available locally, so services
+// can check their required models at startup.
+func (o *Client) EnsureModel(ctx context.Context, model string, progress func(event ProgressEvent) error) error {
+	_, err := o.Show(ctx, model)
+	if !errors.Is(err, models.ErrModelNotFound) {
+		return err
+	}
+	return o.Pull(ctx, model, progress)
+}
@@ -0,0 +1,235 @@
+package ollama
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/aqua777/ai-flow/llm/models"
+)
+
+type ManageTestSuite struct {
+	suite.Suite
+	server   *httptest.Server
+	handler  http.HandlerFunc
+	requests []string
+	bodies   []map[string]any
+	client   *Client
+}
+
+func TestManageTestSuite(t *testing.T) {
+	suite.Run(t, new(ManageTestSuite))
+}
+
+func (s *ManageTestSuite) SetupTest() {
+	s.requests, s.bodies = nil, nil
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.requests = append(s.requests, r.Method+" "+r.URL.Path)
+		body := map[string]any{}
+		if data, _ := io.ReadAll(r.Body); len(data) > 0 {
+			s.Require().NoError(json.Unmarshal(data, &body))
+		}
+		s.bodies = append(s.bodies, body)
+		s.handler(w, r)
+	}))
+	client, err := NewClient(&models.LLMConfig{Url: s.server.URL})
+	s.Require().NoError(err)
+	s.client = client
+}
+
+func (s *ManageTestSuite) TearDownTest() {
+	s.server.Close()
+}
+
+func (s *ManageTestSuite) TestPull() {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"status":"pulling manifest"}`)
+		fmt.Fprintln(w, `{"status":"pulling 6a0746a1ec1a","digest":"sha256:6a0746a1ec1a","total":100,"completed":40}`)
+		fmt.Fprintln(w, `{"status":"pulling 6a0746a1ec1a","digest":"sha256:6a0746a1ec1a","total":100,"completed":100}`)
+		fmt.Fprintln(w, `{"status":"success"}`)
+	}
+	var events []ProgressEvent
+	err := s.client.Pull(context.Background(), "llama3.1", func(event ProgressEvent) error {
+		events = append(events, event)
+		return nil
+	})
+	s.Require().NoError(err)
+	s.Equal([]string{"POST /api/pull"}, s.requests)
+	s.Equal(map[string]any{"model": "llama3.1", "stream": true}, s.bodies[0])
+	s.Require().Len(events, 4)
+	s.Equal(int64(40), events[1].Completed)
+	s.True(events[3].Done())
+}
+
+func (s *ManageTestSuite) TestPull_Errors() {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"status":"pulling manifest"}`)
+		fmt.Fprintln(w, `{"error":"pull model manifest: file does not exist"}`)
+	}
+	err := s.client.Pull(context.Background(), "nope", nil)
+	s.ErrorContains(err, "file does not exist")
+
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"status":"pulling manifest"}`)
+	}
+	err = s.client.Pull(context.Background(), "llama3.1", nil)
+	s.ErrorIs(err, errIncompleteProgress)
+
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"status":"pulling manifest"}`)
+		fmt.Fprintln(w, `{"status":"success"}`)
+	}
+	stop := errors.New("stop")
+	err = s.client.Pull(context.Background(), "llama3.1", func(event ProgressEvent) error { return stop })
+	s.ErrorIs(err, stop)
+}
+
+func (s *ManageTestSuite) TestCreateFromModelfile() {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"status":"using existing layer sha256:abc"}`)
+		fmt.Fprintln(w, `{"status":"success"}`)
+	}
+	err := s.client.CreateFromModelfile(context.Background(), "mario", "FROM llama3.1\nSYSTEM You are Mario.\nPARAMETER temperature 0.5", nil)
+	s.Require().NoError(err)
+	s.Equal([]string{"POST /api/create"}, s.requests)
+	s.Equal(map[string]any{
+		"model":      "mario",
+		"from":       "llama3.1",
+		"system":     "You are Mario.",
+		"parameters": map[string]any{"temperature": 0.5},
+		"stream":     true,
+	}, s.bodies[0])
+}
+
+func (s *ManageTestSuite) TestDeleteCopyRunning() {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/ps" {
+			fmt.Fprint(w, `{"models":[{"name":"llama3.1:latest","model":"llama3.1:latest","size":5137025024,"size_vram":5137025024,"expires_at":"2025-01-01T00:05:00Z","context_length":4096}]}`)
+		}
+	}
+	ctx := context.Background()
+	s.Require().NoError(s.client.Copy(ctx, "llama3.1", "llama3.1-backup"))
+	s.Require().NoError(s.client.Delete(ctx, "llama3.1-backup"))
+	running, err := s.client.Running(ctx)
+	s.Require().NoError(err)
+
+	s.Equal([]string{"POST /api/copy", "DELETE /api/delete", "GET /api/ps"}, s.requests)
+	s.Equal(map[string]any{"source": "llama3.1", "destination": "llama3.1-backup"}, s.bodies[0])
+	s.Equal(map[string]any{"model": "llama3.1-backup"}, s.bodies[1])
+	s.Require().Len(running, 1)
+	s.Equal(int64(5137025024), running[0].SizeVRAM)
+	s.Equal(4096, running[0].ContextLength)
+	s.Equal(time.Date(2025, 1, 1, 0, 5, 0, 0, time.UTC), running[0].ExpiresAt)
+}
+
+func (s *ManageTestSuite) TestDelete_NotFound() {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"error":"model 'nope' not found"}`)
+	}
+	s.ErrorIs(s.client.Delete(context.Background(), "nope"), models.ErrModelNotFound)
+}
+
+func (s *ManageTestSuite) TestLoadUnload() {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"model":"llama3.1","done":true,"done_reason":"load"}`)
+	}
+	ctx := context.Background()
+	s.Require().NoError(s.client.Load(ctx, "llama3.1", 10*time.Minute))
+	s.Require().NoError(s.client.Load(ctx, "llama3.1", -1))
+	s.Require().NoError(s.client.Load(ctx, "llama3.1", 0))
+	s.Require().NoError(s.client.Unload(ctx, "llama3.1"))
+	s.Equal("10m0s", s.bodies[0]["keep_alive"])
+	s.Equal("-1ns", s.bodies[1]["keep_alive"])
+	s.NotContains(s.bodies[2], "keep_alive")
+	s.Equal("0s", s.bodies[3]["keep_alive"])
+	s.Equal(false, s.bodies[3]["stream"])
+}
+
+func (s *ManageTestSuite) TestLoad_NoRequestTimeout() {
+	s.client.client.Client.WithTimeout(20 * time.Millisecond)
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+		fmt.Fprint(w, `{"model":"llama3.1","done":true,"done_reason":"load"}`)
+	}
+	s.Require().NoError(s.client.Load(context.Background(), "llama3.1", -1), "loading may outlast the request timeout")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	s.ErrorIs(s.client.Unload(ctx, "llama3.1"), context.DeadlineExceeded, "the context still bounds the call")
+}
+
+func (s *ManageTestSuite) TestEnsureModel() {
+	pulled := false
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/show":
+			if !pulled {
+				w.WriteHeader(http.StatusNotFound)
+				fmt.Fprint(w, `{"error":"model 'llama3.1' not found"}`)
+				return
+			}
+			fmt.Fprint(w, `{"capabilities":["completion"]}`)
+		case "/api/pull":
+			pulled = true
+			fmt.Fprintln(w, `{"status":"success"}`)
+		}
+	}
+	ctx := context.Background()
+	s.Require().NoError(s.client.EnsureModel(ctx, "llama3.1", nil))
+	s.Require().NoError(s.client.EnsureModel(ctx, "llama3.1", nil))
+	s.Equal([]string{"POST /api/show", "POST /api/pull", "POST /api/show"}, s.requests)
+}
+
+func (s *ManageTestSuite) TestParseModelfile() {
+	req, err := ParseModelfile(`# Mario
+FROM llama3.1:8b
+PARAMETER temperature 0.7
+PARAMETER num_ctx 8192
+PARAMETER stop "<|start_header_id|>"
+PARAMETER stop "<|eot_id|>"
+template """{{ if .System }}{{ .System }}
+{{ end }}{{ .Prompt }}"""
+SYSTEM """
+You are Mario.
+"""
+MESSAGE user Who are you?
+MESSAGE assistant It's-a me, Mario!
+LICENSE MIT
+`)
+	s.Require().NoError(err)
+	s.Equal(&CreateRequest{
+		From:     "llama3.1:8b",
+		Template: "{{ if .System }}{{ .System }}\n{{ end }}{{ .Prompt }}",
+		System:   "\nYou are Mario.\n",
+		License:  []string{"MIT"},
+		Parameters: map[string]any{
+			"temperature": 0.7,
+			"num_ctx":     8192,
+			"stop":        []string{"<|start_header_id|>", "<|eot_id|>"},
+		},
+		Messages: []*CreateMessage{
+			{Role: "user", Content: "Who are you?"},
+			{Role: "assistant", Content: "It's-a me, Mario!"},
+		},
+	}, req)
+
+	_, err = ParseModelfile("FROM ./model.gguf")
+	s.ErrorIs(err, ErrModelfileUnsupported)
+	_, err = ParseModelfile("FROM llama3.1\nADAPTER ./lora.gguf")
+	s.ErrorIs(err, ErrModelfileUnsupported)
+	_, err = ParseModelfile("SYSTEM hi")
+	s.EqualError(err, "modelfile has no FROM instruction")
+	_, err = ParseModelfile("FROM llama3.1\nSYSTEM \"\"\"never closed")
+	s.EqualError(err, "modelfile line 2: unterminated triple-quoted string")
+	_, err = ParseModelfile("FROM llama3.1\nQUANTIZE q4")
+	s.EqualError(err, `modelfile line 2: unknown instruction "QUANTIZE"`)
+}
@@ -0,0 +1,131 @@
+package ollama
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+var ErrModelfileUnsupported = errors.New("modelfile instruction needs a local file upload, which is not supported")
+
+const tripleQuote = `"""`
+
+// ParseModelfile converts a Modelfile into a CreateRequest. Instructions are
+// case-insensitive and values may span lines inside triple quotes. FROM must
+// name an existing model: local weights and ADAPTER need blob uploads and
+// fail with ErrModelfileUnsupported.
+func ParseModelfile(modelfile string) (*CreateRequest, error) {
+	req := &CreateRequest{}
+	lines := strings.Split(strings.ReplaceAll(modelfile, "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		instruction, rest, _ := strings.Cut(line, " ")
+		rest = strings.TrimSpace(rest)
+		lineNo := i + 1
+		if strings.HasPrefix(rest, tripleQuote) {
+			value, next, err := readTripleQuoted(lines, i, rest)
+			if err != nil {
+				return nil, fmt.Errorf("modelfile line %d: %w", lineNo, err)
+			}
+			rest, i = value, next
+		} else {
+			rest = unquote(rest)
+		}
+
+		switch strings.ToUpper(instruction) {
+		case "FROM":
+			if isLocalPath(rest) {
+				return nil, fmt.Errorf("modelfile line %d: FROM %s: %w", lineNo, rest, ErrModelfileUnsupported)
+			}
+			req.From = rest
+		case "ADAPTER":
+			return nil, fmt.Errorf("modelfile line %d: ADAPTER: %w", lineNo, ErrModelfileUnsupported)
+		case "TEMPLATE":
+			req.Template = rest
+		case "SYSTEM":
+			req.System = rest
+		case "LICENSE":
+			req.License = append(req.License, rest)
+		case "PARAMETER":
+			key, value, ok := strings.Cut(rest, " ")
+			if !ok {
+				return nil, fmt.Errorf("modelfile line %d: PARAMETER needs a name and a value", lineNo)
+			}
+			if req.Parameters == nil {
+				req.Parameters = map[string]any{}
+			}
+			addParameter(req.Parameters, key, unquote(strings.TrimSpace(value)))
+		case "MESSAGE":
+			role, content, ok := strings.Cut(rest, " ")
+			if !ok {
+				return nil, fmt.Errorf("modelfile line %d: MESSAGE needs a role and content", lineNo)
+			}
+			req.Messages = append(req.Messages, &CreateMessage{Role: role, Content: unquote(strings.TrimSpace(content))})
+		default:
+			return nil, fmt.Errorf("modelfile line %d: unknown instruction %q", lineNo, instruction)
+		}
+	}
+	if req.From == "" {
+		return nil, errors.New("modelfile has no FROM instruction")
+	}
+	return req, nil
+}
+
+// readTripleQuoted returns the text between the triple quotes that open rest
+// on lines[start], and the index of the line holding the closing quotes.
+func readTripleQuoted(lines []string, start int, rest string) (string, int, error) {
+	text := strings.TrimPrefix(rest, tripleQuote)
+	if value, _, ok := strings.Cut(text, tripleQuote); ok {
+		return value, start, nil
+	}
+	parts := []string{text}
+	for i := start + 1; i < len(lines); i++ {
+		if value, _, ok := strings.Cut(lines[i], tripleQuote); ok {
+			return strings.Join(append(parts, value), "\n"), i, nil
+		}
+		parts = append(parts, lines[i])
+	}
+	return "", 0, errors.New("unterminated triple-quoted string")
+}
+
+func unquote(value string) string {
+	if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+func isLocalPath(from string) bool {
+	if strings.HasPrefix(from, ".") || strings.HasPrefix(from, "/") || strings.HasPrefix(from, "~") {
+		return true
+	}
+	switch strings.ToLower(filepath.Ext(from)) {
+	case ".gguf", ".safetensors", ".bin":
+		return true
+	}
+	return false
+}
+
+// addParameter stores value with its JSON type. stop may repeat and always
+// becomes a list.
+func addParameter(params map[string]any, key, value string) {
+	if key == "stop" {
+		stops, _ := params[key].([]string)
+		params[key] = append(stops, value)
+		return
+	}
+	if n, err := strconv.Atoi(value); err == nil {
+		params[key] = n
+	} else if f, err := strconv.ParseFloat(value, 64); err == nil {
+		params[key] = f
+	} else if b, err := strconv.ParseBool(value); err == nil {
+		params[key] = b
+	} else {
+		params[key] = value
+	}
+}
@@ -2,6 +2,8 @@ package ollama
 
 import (
 	"time"
+
+	"github.com/aqua777/ai-flow/llm/models"
 )
 
 type OllamaModelDetails struct {
@@ -9,8 +11,8 @@ type OllamaModelDetails struct {
 	Format            string   `json:"format"`
 	Family            string   `json:"family"`
 	Families          []string `json:"families"`
-	ParamaterSize     int64    `json:"paramater_size"`
-	QuantizationLevel string   `json:"quantization"`
+	ParameterSize     string   `json:"parameter_size"`
+	QuantizationLevel string   `json:"quantization_level"`
 }
 
 type OllamaModel struct {
@@ -19,6 +21,8 @@ type OllamaModel struct {
 	ModelFile    string             `json:"modelfile"`
 	Parameters   string             `json:"parameters"`
 	Template     string             `json:"template"`
+	System       string             `json:"system,omitempty"`
+	License      string             `json:"license,omitempty"`
 	Size         int64              `json:"size"`
 	Digest       string             `json:"digest"`
 	Details      OllamaModelDetails `json:"details"`
@@ -31,3 +35,47 @@ type ListModelResponse struct {
 	Models []*OllamaModel `json:"models"`
 }
 
+// RunningModel is a model currently loaded in memory, as reported by /api/ps.
+type RunningModel struct {
+	Name          string             `json:"name"`
+	Model         string             `json:"model"`
+	Size          int64              `json:"size"`
+	SizeVRAM      int64              `json:"size_vram"`
+	Digest        string             `json:"digest"`
+	Details       OllamaModelDetails `json:"details"`
+	ExpiresAt     time.Time          `json:"expires_at"`
+	ContextLength int                `json:"context_length"`
+}
+
+type RunningModelsResponse struct {
+	Models []*RunningModel `json:"models"`
+}
+
+// toModel converts a model from /api/tags or /api/show. Context and embedding
+// lengths are only present in the model_info that /api/show returns, keyed by
+// architecture, e.g. "llama.context_length".
+func toModel(m *OllamaModel) *models.Model {
+	result := &models.Model{
+		ID:            m.Name,
+		Name:          m.Name,
+		Model:         m.Model,
+		Family:        m.Details.Family,
+		ParameterSize: m.Details.ParameterSize,
+		Quantization:  m.Details.QuantizationLevel,
+	}
+	if arch, ok := m.ModelInfo["general.architecture"].(string); ok {
+		result.ContextSize = infoInt(m.ModelInfo, arch+".context_length")
+		result.EmbeddingLength = infoInt(m.ModelInfo, arch+".embedding_length")
+	}
+	for _, c := range m.Capabilities {
+		result.Capabilities = append(result.Capabilities, models.Capability(c))
+	}
+	return result
+}
+
+func infoInt(info map[string]any, key string) int {
+	if v, ok := info[key].(float64); ok {
+		return int(v)
+	}
+	return 0
+}
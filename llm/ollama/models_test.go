@@ -0,0 +1,90 @@
+package ollama
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/aqua777/ai-flow/llm/models"
+)
+
+type ModelsTestSuite struct {
+	suite.Suite
+	server *httptest.Server
+	client *Client
+}
+
+func TestModelsTestSuite(t *testing.T) {
+	suite.Run(t, new(ModelsTestSuite))
+}
+
+const llamaShowResponse = `{
+	"details":{"format":"gguf","family":"llama","families":["llama"],"parameter_size":"8.0B","quantization_level":"Q4_K_M"},
+	"model_info":{"general.architecture":"llama","llama.context_length":131072,"llama.embedding_length":4096},
+	"capabilities":["completion","tools"]
+}`
+
+func (s *ModelsTestSuite) SetupTest() {
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/tags":
+			fmt.Fprint(w, `{"models":[
+				{"name":"llama3.1:8b","model":"llama3.1:8b","details":{"family":"llama","parameter_size":"8.0B","quantization_level":"Q4_K_M"}},
+				{"name":"broken:latest","model":"broken:latest","details":{"family":"bert"}}
+			]}`)
+		case "/api/show":
+			var req OllamaShowRequest
+			s.Require().NoError(json.NewDecoder(r.Body).Decode(&req))
+			if req.Model != "llama3.1:8b" {
+				w.WriteHeader(http.StatusNotFound)
+				fmt.Fprintf(w, `{"error":"model '%s' not found"}`, req.Model)
+				return
+			}
+			fmt.Fprint(w, llamaShowResponse)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	client, err := NewClient(&models.LLMConfig{Url: s.server.URL})
+	s.Require().NoError(err)
+	s.client = client
+}
+
+func (s *ModelsTestSuite) TearDownTest() {
+	s.server.Close()
+}
+
+func (s *ModelsTestSuite) TestListModels() {
+	result, err := s.client.ListModels(context.Background())
+	s.Require().NoError(err)
+	s.Require().Len(result, 2)
+	s.Equal(&models.Model{
+		ID:              "llama3.1:8b",
+		Name:            "llama3.1:8b",
+		Model:           "llama3.1:8b",
+		ContextSize:     131072,
+		Family:          "llama",
+		ParameterSize:   "8.0B",
+		Quantization:    "Q4_K_M",
+		EmbeddingLength: 4096,
+		Capabilities:    []models.Capability{models.CapabilityCompletion, models.CapabilityTools},
+	}, result[0])
+	s.Equal("bert", result[1].Family, "falls back to /api/tags details when /api/show fails")
+	s.Nil(result[1].Capabilities)
+}
+
+func (s *ModelsTestSuite) TestGetModel() {
+	m, err := s.client.GetModel(context.Background(), "llama3.1:8b")
+	s.Require().NoError(err)
+	s.Equal(131072, m.ContextSize)
+	s.True(m.HasCapability(models.CapabilityTools))
+	s.False(m.HasCapability(models.CapabilityVision))
+
+	_, err = s.client.GetModel(context.Background(), "missing")
+	s.ErrorIs(err, models.ErrModelNotFound)
+}
@@ -0,0 +1,38 @@
+package ollama
+
+import "github.com/aqua777/ai-flow/llm/models"
+
+// toOllamaOptions maps request options onto the Ollama "options" object.
+// Ollama calls the token limit num_predict and has no logit bias; anything
+// under the OLLAMA extras (num_ctx, mirostat, repeat_penalty, ...) is passed
+// through as is and wins over the generic fields.
+func toOllamaOptions(o *models.RequestOptions) map[string]interface{} {
+	options := o.ToMap()
+	if maxTokens, ok := options["max_tokens"]; ok {
+		delete(options, "max_tokens")
+		options["num_predict"] = maxTokens
+	}
+	delete(options, "logit_bias")
+	for key, value := range o.ExtraFor(models.OLLAMA) {
+		options[key] = value
+	}
+	if len(options) == 0 {
+		return nil
+	}
+	return options
+}
+
+// toFinishReason maps Ollama's done_reason onto models.FinishReason. Ollama
+// reports "stop" when the model calls tools, so tool calls take precedence.
+func toFinishReason(doneReason string, hasToolCalls bool) models.FinishReason {
+	switch {
+	case hasToolCalls:
+		return models.FinishReasonToolCalls
+	case doneReason == "stop":
+		return models.FinishReasonStop
+	case doneReason == "length":
+		return models.FinishReasonLength
+	default:
+		return models.FinishReason(doneReason)
+	}
+}
@@ -0,0 +1,77 @@
+package openai
+
+import (
+	"strings"
+
+	"github.com/aqua777/ai-flow/llm/models"
+)
+
+// ModelSpec describes what an OpenAI model family supports. The models
+// endpoint reports only IDs, so this is kept by hand.
+type ModelSpec struct {
+	ContextSize     int
+	EmbeddingLength int
+	Capabilities    []models.Capability
+}
+
+var (
+	chatCapabilities      = []models.Capability{models.CapabilityCompletion, models.CapabilityTools}
+	visionCapabilities    = []models.Capability{models.CapabilityCompletion, models.CapabilityTools, models.CapabilityVision}
+	reasoningCapabilities = []models.Capability{models.CapabilityCompletion, models.CapabilityTools, models.CapabilityVision, models.CapabilityThinking}
+	embeddingCapabilities = []models.Capability{models.CapabilityEmbedding}
+)
+
+// KnownModels maps model ID prefixes to their specs; the longest matching
+// prefix wins, so dated snapshots such as "gpt-4o-2024-08-06" resolve to
+// "gpt-4o". Callers may add entries for models released after this table.
+var KnownModels = map[string]ModelSpec{
+	"gpt-3.5-turbo":          {ContextSize: 16385, Capabilities: chatCapabilities},
+	"gpt-3.5-turbo-instruct": {ContextSize: 4096, Capabilities: []models.Capability{models.CapabilityCompletion}},
+	"gpt-4":                  {ContextSize: 8192, Capabilities: chatCapabilities},
+	"gpt-4-32k":              {ContextSize: 32768, Capabilities: chatCapabilities},
+	"gpt-4-1106":             {ContextSize: 128000, Capabilities: chatCapabilities},
+	"gpt-4-0125":             {ContextSize: 128000, Capabilities: chatCapabilities},
+	"gpt-4-turbo":            {ContextSize: 128000, Capabilities: visionCapabilities},
+	"gpt-4o":                 {ContextSize: 128000, Capabilities: visionCapabilities},
+	"gpt-4.1":                {ContextSize: 1047576, Capabilities: visionCapabilities},
+	"gpt-4.5":                {ContextSize: 128000, Capabilities: visionCapabilities},
+	"gpt-5":                  {ContextSize: 400000, Capabilities: reasoningCapabilities},
+	"o1":                     {ContextSize: 200000, Capabilities: reasoningCapabilities},
+	"o1-mini":                {ContextSize: 128000, Capabilities: []models.Capability{models.CapabilityCompletion, models.CapabilityThinking}},
+	"o1-preview":             {ContextSize: 128000, Capabilities: []models.Capability{models.CapabilityCompletion, models.CapabilityThinking}},
+	"o3":                     {ContextSize: 200000, Capabilities: reasoningCapabilities},
+	"o3-mini":                {ContextSize: 200000, Capabilities: []models.Capability{models.CapabilityCompletion, models.CapabilityTools, models.CapabilityThinking}},
+	"o4-mini":                {ContextSize: 200000, Capabilities: reasoningCapabilities},
+	"text-embedding-3-small": {ContextSize: 8191, EmbeddingLength: 1536, Capabilities: embeddingCapabilities},
+	"text-embedding-3-large": {ContextSize: 8191, EmbeddingLength: 3072, Capabilities: embeddingCapabilities},
+	"text-embedding-ada-002": {ContextSize: 8191, EmbeddingLength: 1536, Capabilities: embeddingCapabilities},
+}
+
+// lookupSpec finds the spec for id by longest prefix. Fine-tuned models
+// ("ft:gpt-4o-mini:org::id") match their base model.
+func lookupSpec(id string) (string, ModelSpec, bool) {
+	base := strings.TrimPrefix(id, "ft:")
+	family := ""
+	for prefix := range KnownModels {
+		if strings.HasPrefix(base, prefix) && len(prefix) > len(family) {
+			family = prefix
+		}
+	}
+	spec, ok := KnownModels[family]
+	return family, spec, ok
+}
+
+func toModel(id string) *models.Model {
+	result := &models.Model{
+		ID:    id,
+		Name:  id, // OpenAI doesn't have a separate name field usually
+		Model: id,
+	}
+	if family, spec, ok := lookupSpec(id); ok {
+		result.Family = family
+		result.ContextSize = spec.ContextSize
+		result.EmbeddingLength = spec.EmbeddingLength
+		result.Capabilities = spec.Capabilities
+	}
+	return result
+}
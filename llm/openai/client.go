@@ -3,23 +3,37 @@ package openai
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
+	"net/http"
+	"strings"
 
+	"github.com/aqua777/ai-flow/llm/batch"
 	"github.com/aqua777/ai-flow/llm/iface"
 	"github.com/aqua777/ai-flow/llm/models"
+	"github.com/aqua777/ai-flow/llm/thinking"
 	openai "github.com/sashabaranov/go-openai"
 )
 
 const (
 	OpenAI_API_URL_v1 = "https://api.openai.com/v1"
+
+	// MaxEmbeddingsBatchSize is the most inputs the embeddings endpoint accepts per call.
+	MaxEmbeddingsBatchSize = 2048
 )
 
 type Client struct {
 	client *openai.Client
+	// passthrough is set when the HTTP client was built by newHTTPClient,
+	// so extras go-openai does not model can be sent.
+	passthrough bool
 }
 
 // Ensure Client implements iface.LLM
-var _ iface.LLM = (*Client)(nil)
+var (
+	_ iface.LLM         = (*Client)(nil)
+	_ iface.ModelGetter = (*Client)(nil)
+)
 
 func NewClient(optionalConfig ...*models.LLMConfig) (*Client, error) {
 	// var config *models.LLMConfig
@@ -32,13 +46,19 @@ func NewClient(optionalConfig ...*models.LLMConfig) (*Client, error) {
 	config := models.OptionalConfig(optionalConfig).GetConfig(models.OPENAI)
 	openaiConfig := openai.DefaultConfig(config.ApiKey)
 	openaiConfig.BaseURL = config.Url
+	openaiConfig.HTTPClient = newHTTPClient()
 	client := openai.NewClientWithConfig(openaiConfig)
 
 	return &Client{
-		client: client,
+		client:      client,
+		passthrough: true,
 	}, nil
 }
 
+// NewClientWithOpenAIClient wraps an existing go-openai client. Retry-After
+// is only captured when its HTTP client was built by this package, so errors
+// from it carry no ProviderError.RetryAfter, and extra options must name
+// fields go-openai knows.
 func NewClientWithOpenAIClient(client *openai.Client) *Client {
 	return &Client{
 		client: client,
@@ -46,23 +66,32 @@ func NewClientWithOpenAIClient(client *openai.Client) *Client {
 }
 
 func (c *Client) ListModels(ctx context.Context) ([]*models.Model, error) {
+	ctx = withRetryAfter(ctx)
 	resp, err := c.client.ListModels(ctx)
 	if err != nil {
-		return nil, err
+		return nil, toProviderError(ctx, err)
 	}
 
 	var result []*models.Model
 	for _, m := range resp.Models {
-		result = append(result, &models.Model{
-			ID:    m.ID,
-			Name:  m.ID, // OpenAI doesn't have a separate name field usually
-			Model: m.ID,
-		})
+		result = append(result, toModel(m.ID))
 	}
 	return result, nil
 }
 
-func (c *Client) Generate(ctx context.Context, r *models.GenerateRequest) (*models.GenerateResponse, error) {
+// GetModel looks name up on the models endpoint and fills in its context
+// size and capabilities from KnownModels.
+func (c *Client) GetModel(ctx context.Context, name string) (*models.Model, error) {
+	ctx = withRetryAfter(ctx)
+	resp, err := c.client.GetModel(ctx, name)
+	if err != nil {
+		return nil, toProviderError(ctx, err)
+	}
+	return toModel(resp.ID), nil
+}
+
+func (c *Client) Generate(ctx context.Context, r *models.GenerateRequest, stream ...func(chunk []byte) error) (*models.GenerateResponse, error) {
+	ctx = withRetryAfter(ctx)
 	// OpenAI Chat Completion as Generate
 	messages := []openai.ChatCompletionMessage{
 		{
@@ -71,78 +100,166 @@ func (c *Client) Generate(ctx context.Context, r *models.GenerateRequest) (*mode
 		},
 	}
 
+	responseFormat, err := toOpenAIResponseFormat(r.ResponseFormat)
+	if err != nil {
+		return nil, err
+	}
+
 	req := openai.ChatCompletionRequest{
-		Model:    r.Model,
-		Messages: messages,
+		Model:          r.Model,
+		Messages:       messages,
+		ResponseFormat: responseFormat,
+	}
+	extra, err := applyOptions(&req, &r.Options, c.passthrough)
+	if err != nil {
+		return nil, err
+	}
+	ctx = withExtraBody(ctx, extra)
+
+	if len(stream) > 0 && stream[0] != nil {
+		chatResp, err := c.streamChat(ctx, req, stream[0])
+		if err != nil {
+			return nil, err
+		}
+		return &models.GenerateResponse{
+			Text:             chatResp.Content,
+			Model:            r.Model,
+			PromptTokens:     chatResp.Metadata.PromptTokens,
+			CompletionTokens: chatResp.Metadata.CompletionTokens,
+			TotalTokens:      chatResp.Metadata.TotalTokens,
+			FinishReason:     chatResp.FinishReason,
+		}, nil
 	}
-	// Map options if needed, skipping for now as options are generic map
 
 	resp, err := c.client.CreateChatCompletion(ctx, req)
 	if err != nil {
-		return nil, err
+		return nil, toProviderError(ctx, err)
 	}
 
 	if len(resp.Choices) == 0 {
 		return nil, errors.New("no choices returned")
 	}
 
+	text, _ := thinking.ProcessContent(resp.Choices[0].Message.Content)
 	return &models.GenerateResponse{
-		Text:             resp.Choices[0].Message.Content,
+		Text:             text,
 		Model:            resp.Model,
 		PromptTokens:     resp.Usage.PromptTokens,
 		CompletionTokens: resp.Usage.CompletionTokens,
 		TotalTokens:      resp.Usage.TotalTokens,
+		FinishReason:     toFinishReason(resp.Choices[0].FinishReason),
 	}, nil
 }
 
 func (c *Client) Chat(ctx context.Context, r *models.ChatRequest, stream ...func(chunk []byte) error) (*models.ChatResponse, error) {
-	openaiMessages := make([]openai.ChatCompletionMessage, len(r.Messages))
-	for i, msg := range r.Messages {
-		openaiMessages[i] = openai.ChatCompletionMessage{
-			Role:    string(msg.Role),
-			Content: msg.Content,
-		}
+	ctx = withRetryAfter(ctx)
+	responseFormat, err := toOpenAIResponseFormat(r.ResponseFormat)
+	if err != nil {
+		return nil, err
+	}
+	if err := models.ValidateImages(r.Messages); err != nil {
+		return nil, err
 	}
 
 	req := openai.ChatCompletionRequest{
-		Model:    r.Model,
-		Messages: openaiMessages,
-		Stream:   len(stream) > 0 && stream[0] != nil,
+		Model:          r.Model,
+		Messages:       toOpenAIMessages(r.Messages),
+		Tools:          toOpenAITools(r.Tools),
+		ResponseFormat: responseFormat,
+		Stream:         len(stream) > 0 && stream[0] != nil,
 	}
+	extra, err := applyOptions(&req, &r.Options, c.passthrough)
+	if err != nil {
+		return nil, err
+	}
+	ctx = withExtraBody(ctx, extra)
 
 	if req.Stream {
-		return c.streamChat(ctx, req, stream[0])
+		resp, err := c.streamChat(ctx, req, stream[0])
+		if err != nil {
+			return nil, imageError(r, err)
+		}
+		return resp, nil
 	}
 
 	resp, err := c.client.CreateChatCompletion(ctx, req)
 	if err != nil {
-		return nil, err
+		return nil, imageError(r, toProviderError(ctx, err))
 	}
 
 	if len(resp.Choices) == 0 {
 		return nil, errors.New("no choices returned")
 	}
 
-	content := resp.Choices[0].Message.Content
-	
+	message := resp.Choices[0].Message
+	// OpenAI-compatible servers return reasoning either in reasoning_content
+	// or inline as <think> tags.
+	content, reasoning := thinking.ProcessContent(message.Content)
+	if message.ReasoningContent != "" {
+		reasoning = message.ReasoningContent
+	}
+
 	return &models.ChatResponse{
-		Content: content,
+		Content:   content,
+		Reasoning: reasoning,
+		ToolCalls: fromOpenAIToolCalls(message.ToolCalls),
 		Metadata: &models.ChatResponseMetadata{
 			PromptTokens:     resp.Usage.PromptTokens,
 			CompletionTokens: resp.Usage.CompletionTokens,
 			TotalTokens:      resp.Usage.TotalTokens,
 		},
+		FinishReason: toFinishReason(resp.Choices[0].FinishReason),
 	}, nil
 }
 
+// toFinishReason maps an OpenAI finish reason onto models.FinishReason.
+func toFinishReason(reason openai.FinishReason) models.FinishReason {
+	switch reason {
+	case openai.FinishReasonStop:
+		return models.FinishReasonStop
+	case openai.FinishReasonLength:
+		return models.FinishReasonLength
+	case openai.FinishReasonToolCalls, openai.FinishReasonFunctionCall:
+		return models.FinishReasonToolCalls
+	case openai.FinishReasonContentFilter:
+		return models.FinishReasonContentFilter
+	case openai.FinishReasonNull:
+		return ""
+	default:
+		return models.FinishReason(reason)
+	}
+}
+
+// imageError marks errors the API raises for image input to a model without
+// vision so callers can match them with models.ErrImagesNotSupported.
+func imageError(r *models.ChatRequest, err error) error {
+	if !models.HasImages(r.Messages) {
+		return err
+	}
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) && apiErr.HTTPStatusCode == http.StatusBadRequest &&
+		strings.Contains(strings.ToLower(apiErr.Message), "image") {
+		return fmt.Errorf("%w: %s: %w", models.ErrImagesNotSupported, r.Model, err)
+	}
+	return err
+}
+
+// streamChat forwards answer deltas to callback, keeping reasoning from
+// reasoning_content or inline <think> tags out of it. Usage is requested with
+// stream_options.include_usage and arrives in a final chunk without choices.
 func (c *Client) streamChat(ctx context.Context, req openai.ChatCompletionRequest, callback func(chunk []byte) error) (*models.ChatResponse, error) {
+	req.Stream = true
+	req.StreamOptions = &openai.StreamOptions{IncludeUsage: true}
 	stream, err := c.client.CreateChatCompletionStream(ctx, req)
 	if err != nil {
-		return nil, err
+		return nil, toProviderError(ctx, err)
 	}
 	defer stream.Close()
 
-	var fullContent string
+	parser := thinking.NewStreamParser()
+	var finishReason openai.FinishReason
+	metadata := &models.ChatResponseMetadata{}
+	toolCalls := newToolCallAccumulator()
 
 	for {
 		response, err := stream.Recv()
@@ -150,28 +267,41 @@ func (c *Client) streamChat(ctx context.Context, req openai.ChatCompletionReques
 			break
 		}
 		if err != nil {
-			return nil, err
+			return nil, toProviderError(ctx, err)
 		}
 
+		if response.Usage != nil {
+			metadata.PromptTokens = response.Usage.PromptTokens
+			metadata.CompletionTokens = response.Usage.CompletionTokens
+			metadata.TotalTokens = response.Usage.TotalTokens
+		}
 		if len(response.Choices) > 0 {
-			delta := response.Choices[0].Delta.Content
-			if delta != "" {
-				fullContent += delta
-				if err := callback([]byte(delta)); err != nil {
-					return nil, err
-				}
+			choice := response.Choices[0]
+			if choice.FinishReason != "" {
+				finishReason = choice.FinishReason
+			}
+			toolCalls.add(choice.Delta.ToolCalls)
+			parser.WriteReasoning(choice.Delta.ReasoningContent)
+			if err := thinking.ForwardAnswer(parser.Write(choice.Delta.Content), callback); err != nil {
+				return nil, err
 			}
 		}
 	}
+	if err := thinking.ForwardAnswer(parser.Flush(), callback); err != nil {
+		return nil, err
+	}
 
-	// Streaming response usually doesn't have full usage stats in the stream chunks easily aggregated 
-	// without counting tokens ourselves, returning basic response.
 	return &models.ChatResponse{
-		Content: fullContent,
+		Content:      parser.Content(),
+		Reasoning:    parser.Reasoning(),
+		ToolCalls:    toolCalls.calls(),
+		Metadata:     metadata,
+		FinishReason: toFinishReason(finishReason),
 	}, nil
 }
 
 func (c *Client) Embeddings(ctx context.Context, cr *models.EmbeddingsRequest) (*models.EmbeddingsResponse, error) {
+	ctx = withRetryAfter(ctx)
 	model := openai.EmbeddingModel(cr.Model)
 	if model == "" {
 		model = openai.SmallEmbedding3
@@ -182,7 +312,7 @@ func (c *Client) Embeddings(ctx context.Context, cr *models.EmbeddingsRequest) (
 		Model: model,
 	})
 	if err != nil {
-		return nil, err
+		return nil, toProviderError(ctx, err)
 	}
 
 	if len(resp.Data) == 0 {
@@ -194,3 +324,35 @@ func (c *Client) Embeddings(ctx context.Context, cr *models.EmbeddingsRequest) (
 	}, nil
 }
 
+func (c *Client) BatchEmbeddings(ctx context.Context, r *models.BatchEmbeddingsRequest) (*models.BatchEmbeddingsResponse, error) {
+	ctx = withRetryAfter(ctx)
+	model := openai.EmbeddingModel(r.Model)
+	if model == "" {
+		model = openai.SmallEmbedding3
+	}
+
+	return batch.Embed(ctx, r, MaxEmbeddingsBatchSize, func(ctx context.Context, inputs []string) ([][]float32, error) {
+		resp, err := c.client.CreateEmbeddings(ctx, openai.EmbeddingRequest{
+			Input:      inputs,
+			Model:      model,
+			Dimensions: r.Dimensions,
+		})
+		if err != nil {
+			return nil, toProviderError(ctx, err)
+		}
+		// The API documents data as ordered by index; sort defensively anyway.
+		vectors := make([][]float32, len(inputs))
+		for _, d := range resp.Data {
+			if d.Index < 0 || d.Index >= len(vectors) {
+				return nil, fmt.Errorf("embedding index %d out of range", d.Index)
+			}
+			vectors[d.Index] = d.Embedding
+		}
+		for i, vector := range vectors {
+			if vector == nil {
+				return nil, fmt.Errorf("no embedding returned for input %d of %d", i, len(vectors))
+			}
+		}
+		return vectors, nil
+	})
+}
@@ -0,0 +1,354 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/aqua777/ai-flow/llm/models"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+type ClientTestSuite struct {
+	suite.Suite
+	server   *httptest.Server
+	handler  http.HandlerFunc
+	lastBody map[string]any
+	client   *Client
+}
+
+func TestClientTestSuite(t *testing.T) {
+	suite.Run(t, new(ClientTestSuite))
+}
+
+func (s *ClientTestSuite) SetupTest() {
+	s.lastBody = nil
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if len(body) > 0 {
+			s.lastBody = map[string]any{}
+			s.Require().NoError(json.Unmarshal(body, &s.lastBody))
+		}
+		s.handler(w, r)
+	}))
+	client, err := NewClient(&models.LLMConfig{Url: s.server.URL + "/v1", ApiKey: "test"})
+	s.Require().NoError(err)
+	s.client = client
+}
+
+func (s *ClientTestSuite) TearDownTest() {
+	s.server.Close()
+}
+
+func writeSSE(w http.ResponseWriter, events ...string) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	for _, event := range events {
+		fmt.Fprintf(w, "data: %s\n\n", event)
+	}
+	fmt.Fprint(w, "data: [DONE]\n\n")
+}
+
+func (s *ClientTestSuite) TestChat_ToolCalls() {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"1","model":"gpt-4o","choices":[{"index":0,"finish_reason":"tool_calls","message":{"role":"assistant","content":"","tool_calls":[{"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Paris\"}"}}]}}],"usage":{"prompt_tokens":10,"completion_tokens":5,"total_tokens":15}}`)
+	}
+	call := &models.ToolCall{ID: "call_0", Name: "get_time", Arguments: `{}`}
+	resp, err := s.client.Chat(context.Background(), &models.ChatRequest{
+		Model: "gpt-4o",
+		Messages: []*models.Message{
+			{Role: models.UserRole, Content: "Weather in Paris?"},
+			{Role: models.AssistantRole, ToolCalls: []*models.ToolCall{call}},
+			models.NewToolResultMessage(call, "12:00"),
+		},
+		Tools: []*models.Tool{{
+			Name:        "get_weather",
+			Description: "Get the weather",
+			Parameters:  map[string]any{"type": "object", "properties": map[string]any{"city": map[string]any{"type": "string"}}},
+		}},
+	})
+	s.Require().NoError(err)
+	s.Require().Len(resp.ToolCalls, 1)
+	s.Equal("call_1", resp.ToolCalls[0].ID)
+	s.Equal("get_weather", resp.ToolCalls[0].Name)
+	var args struct{ City string }
+	s.NoError(resp.ToolCalls[0].DecodeArguments(&args))
+	s.Equal("Paris", args.City)
+
+	tools := s.lastBody["tools"].([]any)
+	s.Equal("get_weather", tools[0].(map[string]any)["function"].(map[string]any)["name"])
+	messages := s.lastBody["messages"].([]any)
+	s.Equal("get_time", messages[1].(map[string]any)["tool_calls"].([]any)[0].(map[string]any)["function"].(map[string]any)["name"])
+	s.Equal("tool", messages[2].(map[string]any)["role"])
+	s.Equal("call_0", messages[2].(map[string]any)["tool_call_id"])
+}
+
+func (s *ClientTestSuite) TestChat_StreamedToolCallDeltas() {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		writeSSE(w,
+			`{"choices":[{"index":0,"delta":{"role":"assistant","content":"Checking"}}]}`,
+			`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_a","type":"function","function":{"name":"get_weather","arguments":""}}]}}]}`,
+			`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":1,"id":"call_b","type":"function","function":{"name":"get_time","arguments":"{}"}}]}}]}`,
+			`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"city\":"}}]}}]}`,
+			`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"Paris\"}"}}]}}]}`,
+			`{"choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`,
+			`{"choices":[],"usage":{"prompt_tokens":9,"completion_tokens":3,"total_tokens":12}}`,
+		)
+	}
+	var chunks []string
+	resp, err := s.client.Chat(context.Background(), &models.ChatRequest{
+		Model:    "gpt-4o",
+		Messages: []*models.Message{{Role: models.UserRole, Content: "hi"}},
+	}, func(chunk []byte) error {
+		chunks = append(chunks, string(chunk))
+		return nil
+	})
+	s.Require().NoError(err)
+	s.Equal([]string{"Checking"}, chunks)
+	s.Require().Len(resp.ToolCalls, 2)
+	s.Equal(&models.ToolCall{ID: "call_a", Name: "get_weather", Arguments: `{"city":"Paris"}`}, resp.ToolCalls[0])
+	s.Equal(&models.ToolCall{ID: "call_b", Name: "get_time", Arguments: `{}`}, resp.ToolCalls[1])
+	s.Equal(models.FinishReasonToolCalls, resp.FinishReason)
+	s.Equal(&models.ChatResponseMetadata{PromptTokens: 9, CompletionTokens: 3, TotalTokens: 12}, resp.Metadata)
+	s.Equal(map[string]any{"include_usage": true}, s.lastBody["stream_options"])
+}
+
+func (s *ClientTestSuite) TestChat_StreamReasoning() {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		writeSSE(w,
+			`{"choices":[{"index":0,"delta":{"reasoning_content":"Native. "}}]}`,
+			`{"choices":[{"index":0,"delta":{"content":"<think>Inline.</thi"}}]}`,
+			`{"choices":[{"index":0,"delta":{"content":"nk>Answer"}}]}`,
+			`{"choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`,
+		)
+	}
+	var chunks []string
+	resp, err := s.client.Chat(context.Background(), &models.ChatRequest{Model: "deepseek"}, func(chunk []byte) error {
+		chunks = append(chunks, string(chunk))
+		return nil
+	})
+	s.Require().NoError(err)
+	s.Equal([]string{"Answer"}, chunks)
+	s.Equal("Answer", resp.Content)
+	s.Equal("Native. Inline.", resp.Reasoning)
+	s.Equal(models.FinishReasonStop, resp.FinishReason)
+}
+
+func (s *ClientTestSuite) TestGenerate_Truncated() {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		writeSSE(w,
+			`{"choices":[{"index":0,"delta":{"content":"The sky is"}}]}`,
+			`{"choices":[{"index":0,"delta":{},"finish_reason":"length"}]}`,
+			`{"choices":[],"usage":{"prompt_tokens":4,"completion_tokens":3,"total_tokens":7}}`,
+		)
+	}
+	resp, err := s.client.Generate(context.Background(), &models.GenerateRequest{Model: "gpt-4o", Prompt: "Why?"}, func(chunk []byte) error { return nil })
+	s.Require().NoError(err)
+	s.Equal("The sky is", resp.Text)
+	s.Equal(models.FinishReasonLength, resp.FinishReason)
+	s.True(resp.FinishReason.Truncated())
+	s.Equal(7, resp.TotalTokens)
+}
+
+func (s *ClientTestSuite) TestChat_ResponseFormat() {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"choices":[{"index":0,"message":{"role":"assistant","content":"{}"}}]}`)
+	}
+	schema := map[string]any{"type": "object"}
+	format := models.JSONSchemaFormat("result", schema)
+	s.False(format.Strict, "strict mode is opt-in")
+	format.Strict = true
+	_, err := s.client.Chat(context.Background(), &models.ChatRequest{
+		Model:          "gpt-4o",
+		ResponseFormat: format,
+	})
+	s.Require().NoError(err)
+	sent := s.lastBody["response_format"].(map[string]any)
+	s.Equal("json_schema", sent["type"])
+	jsonSchema := sent["json_schema"].(map[string]any)
+	s.Equal("result", jsonSchema["name"])
+	s.Equal(true, jsonSchema["strict"])
+	s.Equal(schema, jsonSchema["schema"])
+}
+
+func (s *ClientTestSuite) TestChat_Images() {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"choices":[{"index":0,"message":{"role":"assistant","content":"A cat."}}]}`)
+	}
+	req := &models.ChatRequest{
+		Model: "gpt-4o",
+		Messages: []*models.Message{{
+			Role:    models.UserRole,
+			Content: "What is this?",
+			Images:  []*models.Image{models.NewImage([]byte("png-bytes"), "image/png"), models.NewImageURL("https://example.com/cat.png")},
+		}},
+	}
+	resp, err := s.client.Chat(context.Background(), req)
+	s.Require().NoError(err)
+	s.Equal("A cat.", resp.Content)
+	content := s.lastBody["messages"].([]any)[0].(map[string]any)["content"].([]any)
+	s.Require().Len(content, 3)
+	s.Equal(map[string]any{"type": "text", "text": "What is this?"}, content[0])
+	s.Equal("data:image/png;base64,cG5nLWJ5dGVz", content[1].(map[string]any)["image_url"].(map[string]any)["url"])
+	s.Equal("https://example.com/cat.png", content[2].(map[string]any)["image_url"].(map[string]any)["url"])
+
+	s.lastBody = nil
+	_, err = s.client.Chat(context.Background(), &models.ChatRequest{
+		Model:    "gpt-4o",
+		Messages: []*models.Message{{Role: models.UserRole, Content: "What is this?", Images: []*models.Image{{}}}},
+	})
+	s.ErrorIs(err, models.ErrInvalidImage)
+	s.Nil(s.lastBody, "invalid image must not be sent")
+
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"error":{"message":"Invalid content type. image_url is only supported by certain models.","type":"invalid_request_error"}}`)
+	}
+	_, err = s.client.Chat(context.Background(), req)
+	s.ErrorIs(err, models.ErrImagesNotSupported)
+}
+
+func (s *ClientTestSuite) TestChat_Options() {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"1","model":"gpt-4o","choices":[{"index":0,"message":{"role":"assistant","content":"ok"}}]}`)
+	}
+	seed := 7
+	options := models.RequestOptions{
+		Temperature:      0.3,
+		TopP:             0.9,
+		TopK:             40,
+		MaxTokens:        100,
+		FrequencyPenalty: 0.1,
+		PresencePenalty:  0.2,
+		Stop:             []string{"END"},
+		Seed:             &seed,
+		LogitBias:        map[string]int{"50256": -100},
+	}
+	options.WithExtra(models.OPENAI, "reasoning_effort", "low").WithExtra(models.OLLAMA, "num_ctx", 8192)
+	_, err := s.client.Chat(context.Background(), &models.ChatRequest{
+		Model:    "gpt-4o",
+		Messages: []*models.Message{{Role: models.UserRole, Content: "hi"}},
+		Options:  options,
+	})
+	s.Require().NoError(err)
+	s.InDelta(0.3, s.lastBody["temperature"], 1e-6)
+	s.InDelta(0.9, s.lastBody["top_p"], 1e-6)
+	s.InDelta(0.1, s.lastBody["frequency_penalty"], 1e-6)
+	s.InDelta(0.2, s.lastBody["presence_penalty"], 1e-6)
+	s.Equal(float64(100), s.lastBody["max_completion_tokens"])
+	s.NotContains(s.lastBody, "max_tokens")
+	s.Equal([]any{"END"}, s.lastBody["stop"])
+	s.Equal(float64(7), s.lastBody["seed"])
+	s.Equal(map[string]any{"50256": float64(-100)}, s.lastBody["logit_bias"])
+	s.Equal("low", s.lastBody["reasoning_effort"])
+	s.NotContains(s.lastBody, "top_k")
+	s.NotContains(s.lastBody, "num_ctx")
+}
+
+func (s *ClientTestSuite) TestGenerate_UnknownExtra() {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"1","model":"gpt-4o","choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"hello"}}]}`)
+	}
+	options := models.RequestOptions{MaxTokens: 10}
+	options.WithExtra(models.OPENAI, "top_k", 20).
+		WithExtra(models.OPENAI, "web_search_options", map[string]any{"search_context_size": "low"}).
+		WithExtra(models.OPENAI, "max_tokens", 10)
+	resp, err := s.client.Generate(context.Background(), &models.GenerateRequest{Model: "gpt-4o", Prompt: "hi", Options: options})
+	s.Require().NoError(err)
+	s.Equal("hello", resp.Text)
+	s.Equal(float64(20), s.lastBody["top_k"], "keys go-openai does not know are passed through")
+	s.Equal(map[string]any{"search_context_size": "low"}, s.lastBody["web_search_options"])
+	s.Equal(float64(10), s.lastBody["max_tokens"], "servers that only read max_tokens can still get it")
+	s.Equal("gpt-4o", s.lastBody["model"])
+
+	s.lastBody = nil
+	wrapped := NewClientWithOpenAIClient(openai.NewClientWithConfig(openai.DefaultConfig("test")))
+	_, err = wrapped.Generate(context.Background(), &models.GenerateRequest{Model: "gpt-4o", Prompt: "hi", Options: options})
+	s.ErrorContains(err, "top_k", "a foreign HTTP client cannot send unknown keys")
+	s.Nil(s.lastBody)
+}
+
+func (s *ClientTestSuite) TestChat_Errors() {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusTooManyRequests)
+		fmt.Fprint(w, `{"error":{"message":"Rate limit reached","type":"requests","code":"rate_limit_exceeded"}}`)
+	}
+	req := &models.ChatRequest{Model: "gpt-4o", Messages: []*models.Message{{Role: models.UserRole, Content: "hi"}}}
+	_, err := s.client.Chat(context.Background(), req)
+	s.ErrorIs(err, models.ErrRateLimited)
+	var providerErr *models.ProviderError
+	s.Require().ErrorAs(err, &providerErr)
+	s.Equal("rate_limit_exceeded", providerErr.Code)
+	s.True(providerErr.Retryable)
+	var apiErr *openai.APIError
+	s.ErrorAs(err, &apiErr, "the go-openai error stays reachable")
+
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"error":{"message":"This model's maximum context length is 128000 tokens.","type":"invalid_request_error","code":"context_length_exceeded"}}`)
+	}
+	_, err = s.client.Chat(context.Background(), req, func(chunk []byte) error { return nil })
+	s.ErrorIs(err, models.ErrContextLengthExceeded)
+}
+
+func (s *ClientTestSuite) TestBatchEmbeddings() {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"object":"list","data":[{"object":"embedding","index":1,"embedding":[0.2]},{"object":"embedding","index":0,"embedding":[0.1]}]}`)
+	}
+	req := &models.BatchEmbeddingsRequest{Inputs: []string{"a", "b"}}
+	resp, err := s.client.BatchEmbeddings(context.Background(), req)
+	s.Require().NoError(err)
+	s.Equal([][]float32{{0.1}, {0.2}}, resp.Embeddings)
+
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"object":"list","data":[{"object":"embedding","index":0,"embedding":[0.1]},{"object":"embedding","index":0,"embedding":[0.1]}]}`)
+	}
+	_, err = s.client.BatchEmbeddings(context.Background(), req)
+	s.ErrorContains(err, "no embedding returned for input 1")
+}
+
+func (s *ClientTestSuite) TestListModels() {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/v1/models":
+			fmt.Fprint(w, `{"object":"list","data":[{"id":"gpt-4o-2024-08-06"},{"id":"text-embedding-3-large"},{"id":"whisper-1"}]}`)
+		case "/v1/models/ft:gpt-4o-mini:acme::abc123":
+			fmt.Fprint(w, `{"id":"ft:gpt-4o-mini:acme::abc123"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error":{"message":"The model does not exist","type":"invalid_request_error","code":"model_not_found"}}`)
+		}
+	}
+	result, err := s.client.ListModels(context.Background())
+	s.Require().NoError(err)
+	s.Require().Len(result, 3)
+	s.Equal("gpt-4o", result[0].Family)
+	s.Equal(128000, result[0].ContextSize)
+	s.True(result[0].HasCapability(models.CapabilityVision))
+	s.Equal(3072, result[1].EmbeddingLength)
+	s.Equal([]models.Capability{models.CapabilityEmbedding}, result[1].Capabilities)
+	s.Nil(result[2].Capabilities, "models missing from the table have unknown capabilities")
+
+	m, err := s.client.GetModel(context.Background(), "ft:gpt-4o-mini:acme::abc123")
+	s.Require().NoError(err)
+	s.Equal("gpt-4o", m.Family)
+
+	_, err = s.client.GetModel(context.Background(), "gpt-missing")
+	s.ErrorIs(err, models.ErrModelNotFound)
+}
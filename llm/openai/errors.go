@@ -0,0 +1,35 @@
+package openai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aqua777/ai-flow/llm/models"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// toProviderError maps go-openai API and request errors onto
+// models.ProviderError, with the Retry-After delay recorded in ctx by
+// withRetryAfter. The original error stays reachable with errors.As.
+// Other errors, such as context cancellation, are returned unchanged.
+func toProviderError(ctx context.Context, err error) error {
+	var providerErr *models.ProviderError
+	var apiErr *openai.APIError
+	var requestErr *openai.RequestError
+	switch {
+	case errors.As(err, &apiErr):
+		code := apiErr.Type
+		if apiErr.Code != nil {
+			code = fmt.Sprint(apiErr.Code)
+		}
+		providerErr = models.NewProviderError(models.OPENAI, apiErr.HTTPStatusCode, code, apiErr.Message, err)
+	case errors.As(err, &requestErr):
+		providerErr = models.NewProviderError(models.OPENAI, requestErr.HTTPStatusCode, "", string(requestErr.Body), err)
+		providerErr.Body = requestErr.Body
+	default:
+		return err
+	}
+	providerErr.RetryAfter = retryAfterFrom(ctx)
+	return providerErr
+}
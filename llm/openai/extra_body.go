@@ -0,0 +1,54 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/aqua777/ai-flow/llm/models"
+)
+
+type extraBodyKey struct{}
+
+// withExtraBody returns a context in which the transport merges extra into
+// the JSON request body. go-openai only sends the fields it models, so this
+// is how extras it does not know reach the API.
+func withExtraBody(ctx context.Context, extra map[string]any) context.Context {
+	if len(extra) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, extraBodyKey{}, extra)
+}
+
+// extraBodyTransport merges the extras set up by withExtraBody into the
+// outgoing request body.
+type extraBodyTransport struct {
+	base http.RoundTripper
+}
+
+func (t *extraBodyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	extra, ok := req.Context().Value(extraBodyKey{}).(map[string]any)
+	if !ok || req.Body == nil {
+		return t.base.RoundTrip(req)
+	}
+	data, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	body, err := models.MergeExtra(json.RawMessage(data), extra)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s extra options: %w", models.OPENAI, err)
+	}
+	if data, err = json.Marshal(body); err != nil {
+		return nil, err
+	}
+	req = req.Clone(req.Context())
+	req.Body = io.NopCloser(bytes.NewReader(data))
+	req.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(data)), nil }
+	req.ContentLength = int64(len(data))
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,54 @@
+package openai
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/aqua777/ai-flow/llm/models"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// applyOptions copies the request options onto a chat completion request.
+// OpenAI has no top_k, so it is ignored, and MaxTokens is sent as
+// max_completion_tokens since reasoning models reject max_tokens. Extras
+// under models.OPENAI that go-openai knows are set on req; the extras are
+// also returned so the transport can send them as given, including keys
+// go-openai does not model. Without that transport (passthrough false)
+// unknown keys are an error rather than being dropped silently.
+func applyOptions(req *openai.ChatCompletionRequest, o *models.RequestOptions, passthrough bool) (map[string]any, error) {
+	req.Temperature = float32(o.Temperature)
+	req.TopP = float32(o.TopP)
+	req.MaxCompletionTokens = o.MaxTokens
+	req.FrequencyPenalty = float32(o.FrequencyPenalty)
+	req.PresencePenalty = float32(o.PresencePenalty)
+	req.Stop = o.Stop
+	req.Seed = o.Seed
+	req.LogitBias = o.LogitBias
+
+	extra := o.ExtraFor(models.OPENAI)
+	if len(extra) == 0 {
+		return nil, nil
+	}
+	body, err := models.MergeExtra(req, extra)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	var merged openai.ChatCompletionRequest
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if !passthrough {
+		decoder.DisallowUnknownFields()
+	}
+	if err := decoder.Decode(&merged); err != nil {
+		return nil, fmt.Errorf("invalid %s extra options: %w", models.OPENAI, err)
+	}
+	*req = merged
+	if !passthrough {
+		return nil, nil
+	}
+	return extra, nil
+}
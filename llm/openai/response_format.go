@@ -0,0 +1,49 @@
+package openai
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/aqua777/ai-flow/llm/models"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+const defaultSchemaName = "response"
+
+func toOpenAIResponseFormat(rf *models.ResponseFormat) (*openai.ChatCompletionResponseFormat, error) {
+	if rf == nil || rf.Type == "" {
+		return nil, nil
+	}
+	switch rf.Type {
+	case models.ResponseFormatText:
+		return &openai.ChatCompletionResponseFormat{Type: openai.ChatCompletionResponseFormatTypeText}, nil
+	case models.ResponseFormatJSON:
+		return &openai.ChatCompletionResponseFormat{Type: openai.ChatCompletionResponseFormatTypeJSONObject}, nil
+	case models.ResponseFormatJSONSchema:
+		if rf.Schema == nil {
+			return nil, fmt.Errorf("response format %s requires a schema", rf.Type)
+		}
+		schema, ok := rf.Schema.(json.Marshaler)
+		if !ok {
+			data, err := json.Marshal(rf.Schema)
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal response schema: %w", err)
+			}
+			schema = json.RawMessage(data)
+		}
+		name := rf.Name
+		if name == "" {
+			name = defaultSchemaName
+		}
+		return &openai.ChatCompletionResponseFormat{
+			Type: openai.ChatCompletionResponseFormatTypeJSONSchema,
+			JSONSchema: &openai.ChatCompletionResponseFormatJSONSchema{
+				Name:   name,
+				Schema: schema,
+				Strict: rf.Strict,
+			},
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported response format type: %s", rf.Type)
+	}
+}
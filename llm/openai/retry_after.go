@@ -0,0 +1,65 @@
+package openai
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	aihttp "github.com/aqua777/ai-flow/http"
+)
+
+// retryAfterMsHeader is OpenAI's millisecond-precision companion to
+// Retry-After.
+const retryAfterMsHeader = "retry-after-ms"
+
+type retryAfterKey struct{}
+
+// withRetryAfter returns a context in which the transport can record the
+// delay a 429 or 503 response asked for. go-openai drops response headers
+// from its errors, so toProviderError reads the delay back from here.
+func withRetryAfter(ctx context.Context) context.Context {
+	return context.WithValue(ctx, retryAfterKey{}, new(atomic.Int64))
+}
+
+func retryAfterFrom(ctx context.Context) time.Duration {
+	if d, ok := ctx.Value(retryAfterKey{}).(*atomic.Int64); ok {
+		return time.Duration(d.Load())
+	}
+	return 0
+}
+
+// retryAfterTransport records Retry-After on throttled responses into the
+// request context set up by withRetryAfter.
+type retryAfterTransport struct {
+	base http.RoundTripper
+}
+
+func (t *retryAfterTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || (resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable) {
+		return resp, err
+	}
+	if d, ok := req.Context().Value(retryAfterKey{}).(*atomic.Int64); ok {
+		d.Store(int64(parseRetryAfter(resp.Header, time.Now())))
+	}
+	return resp, nil
+}
+
+// parseRetryAfter prefers retry-after-ms and falls back to Retry-After.
+func parseRetryAfter(header http.Header, now time.Time) time.Duration {
+	if value := strings.TrimSpace(header.Get(retryAfterMsHeader)); value != "" {
+		if ms, err := strconv.ParseFloat(value, 64); err == nil && ms > 0 {
+			return time.Duration(ms * float64(time.Millisecond))
+		}
+	}
+	return aihttp.ParseRetryAfter(header.Get(aihttp.RetryAfterHeader), now)
+}
+
+// newHTTPClient returns an HTTP client whose transport captures Retry-After
+// and sends extra body fields.
+func newHTTPClient() *http.Client {
+	return &http.Client{Transport: &retryAfterTransport{base: &extraBodyTransport{base: http.DefaultTransport}}}
+}
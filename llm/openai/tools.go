@@ -0,0 +1,144 @@
+package openai
+
+import (
+	"encoding/json"
+
+	"github.com/aqua777/ai-flow/llm/models"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+func toOpenAIMessages(messages []*models.Message) []openai.ChatCompletionMessage {
+	result := make([]openai.ChatCompletionMessage, len(messages))
+	for i, msg := range messages {
+		result[i] = openai.ChatCompletionMessage{
+			Role:       string(msg.Role),
+			Content:    msg.Content,
+			ToolCalls:  toOpenAIToolCalls(msg.ToolCalls),
+			ToolCallID: msg.ToolCallID,
+		}
+		if len(msg.Images) > 0 {
+			result[i].Content = ""
+			result[i].MultiContent = toOpenAIMessageParts(msg)
+		}
+	}
+	return result
+}
+
+// toOpenAIMessageParts splits a message with images into a text part followed
+// by one image_url part per image. Inline images are sent as data URLs.
+func toOpenAIMessageParts(msg *models.Message) []openai.ChatMessagePart {
+	parts := make([]openai.ChatMessagePart, 0, len(msg.Images)+1)
+	if msg.Content != "" {
+		parts = append(parts, openai.ChatMessagePart{
+			Type: openai.ChatMessagePartTypeText,
+			Text: msg.Content,
+		})
+	}
+	for _, image := range msg.Images {
+		parts = append(parts, openai.ChatMessagePart{
+			Type:     openai.ChatMessagePartTypeImageURL,
+			ImageURL: &openai.ChatMessageImageURL{URL: image.DataURL()},
+		})
+	}
+	return parts
+}
+
+func toOpenAITools(tools []*models.Tool) []openai.Tool {
+	if len(tools) == 0 {
+		return nil
+	}
+	result := make([]openai.Tool, len(tools))
+	for i, tool := range tools {
+		params := tool.Parameters
+		if params == nil {
+			params = json.RawMessage(`{"type":"object","properties":{}}`)
+		}
+		result[i] = openai.Tool{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  params,
+			},
+		}
+	}
+	return result
+}
+
+func toOpenAIToolCalls(calls []*models.ToolCall) []openai.ToolCall {
+	if len(calls) == 0 {
+		return nil
+	}
+	result := make([]openai.ToolCall, len(calls))
+	for i, call := range calls {
+		result[i] = openai.ToolCall{
+			ID:   call.ID,
+			Type: openai.ToolTypeFunction,
+			Function: openai.FunctionCall{
+				Name:      call.Name,
+				Arguments: call.Arguments,
+			},
+		}
+	}
+	return result
+}
+
+func fromOpenAIToolCalls(calls []openai.ToolCall) []*models.ToolCall {
+	if len(calls) == 0 {
+		return nil
+	}
+	result := make([]*models.ToolCall, len(calls))
+	for i, call := range calls {
+		result[i] = &models.ToolCall{
+			ID:        call.ID,
+			Name:      call.Function.Name,
+			Arguments: call.Function.Arguments,
+		}
+	}
+	return result
+}
+
+// toolCallAccumulator reassembles streamed tool-call deltas. The first delta
+// for a call carries its index, id and name; later deltas with the same index
+// only carry argument fragments.
+type toolCallAccumulator struct {
+	order []int
+	byIdx map[int]*models.ToolCall
+}
+
+func newToolCallAccumulator() *toolCallAccumulator {
+	return &toolCallAccumulator{byIdx: make(map[int]*models.ToolCall)}
+}
+
+func (a *toolCallAccumulator) add(deltas []openai.ToolCall) {
+	for _, delta := range deltas {
+		idx := len(a.order)
+		if delta.Index != nil {
+			idx = *delta.Index
+		}
+		call, ok := a.byIdx[idx]
+		if !ok {
+			call = &models.ToolCall{}
+			a.byIdx[idx] = call
+			a.order = append(a.order, idx)
+		}
+		if delta.ID != "" {
+			call.ID = delta.ID
+		}
+		if delta.Function.Name != "" {
+			call.Name += delta.Function.Name
+		}
+		call.Arguments += delta.Function.Arguments
+	}
+}
+
+func (a *toolCallAccumulator) calls() []*models.ToolCall {
+	if len(a.order) == 0 {
+		return nil
+	}
+	result := make([]*models.ToolCall, len(a.order))
+	for i, idx := range a.order {
+		result[i] = a.byIdx[idx]
+	}
+	return result
+}
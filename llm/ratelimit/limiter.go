@@ -0,0 +1,262 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/aqua777/ai-flow/textsplitter"
+)
+
+// ErrWaitExceedsDeadline is returned, together with
+// context.DeadlineExceeded, when a call would have to wait past its
+// context's deadline. The call fails at once instead of waiting in vain.
+var ErrWaitExceedsDeadline = errors.New("rate limit wait exceeds the context deadline")
+
+// ErrExceedsTokenLimit is returned for a call estimated at more tokens than
+// its model's TokensPerMinute. Such a call could never fit in the bucket, and
+// waiting for it would stall every later call to the model.
+var ErrExceedsTokenLimit = errors.New("request exceeds the tokens per minute limit")
+
+// Limits bound the calls to one model. Zero fields are unlimited.
+type Limits struct {
+	RequestsPerMinute int
+	// TokensPerMinute bounds the estimated prompt tokens plus the requested
+	// MaxTokens. Once a call reports its usage the estimate is corrected. A
+	// single call estimated above it fails with ErrExceedsTokenLimit.
+	TokensPerMinute int
+	// MaxConcurrent caps the calls in flight, including streams.
+	MaxConcurrent int
+	// RequestBurst is how many requests may start at once after a quiet
+	// spell. Defaults to RequestsPerMinute, matching a quota that resets
+	// every minute.
+	RequestBurst int
+}
+
+// Config configures a Limiter. Zero values take the defaults.
+type Config struct {
+	// Default applies to models without an entry in Models.
+	Default Limits
+	Models  map[string]Limits
+	// Tokenizer estimates request tokens. Defaults to
+	// textsplitter.SimpleTokenizer; use a model-specific tokenizer such as
+	// TikTokenTokenizer for accuracy.
+	Tokenizer textsplitter.Tokenizer
+	// OnWait, if set, is called before every call that has to wait.
+	OnWait func(Wait)
+}
+
+// Wait describes a call held back by a limit.
+type Wait struct {
+	Model     string
+	Operation string
+	// Delay is the wait for the rate limits; waits for a concurrency slot
+	// are not known in advance and are reported with Delay zero.
+	Delay time.Duration
+}
+
+// Limiter holds the buckets and concurrency slots of each model. Share one
+// Limiter between every client that draws on the same quota; it is safe for
+// concurrent use. Buckets are keyed by model name alone, so clients of
+// different providers that share a Limiter and a model name also share that
+// model's quota; give each provider its own Limiter to keep them apart.
+type Limiter struct {
+	config Config
+
+	mu     sync.Mutex
+	models map[string]*modelLimiter
+}
+
+func NewLimiter(config *Config) *Limiter {
+	l := &Limiter{models: make(map[string]*modelLimiter)}
+	if config != nil {
+		l.config = *config
+	}
+	if l.config.Tokenizer == nil {
+		l.config.Tokenizer = textsplitter.NewSimpleTokenizer()
+	}
+	return l
+}
+
+// Limits returns the limits that apply to model.
+func (l *Limiter) Limits(model string) Limits {
+	if limits, ok := l.config.Models[model]; ok {
+		return limits
+	}
+	return l.config.Default
+}
+
+func (l *Limiter) forModel(model string) *modelLimiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	m, ok := l.models[model]
+	if !ok {
+		m = newModelLimiter(l.Limits(model))
+		l.models[model] = m
+	}
+	return m
+}
+
+type modelLimiter struct {
+	requests *bucket
+	tokens   *bucket
+	slots    chan struct{}
+}
+
+func newModelLimiter(limits Limits) *modelLimiter {
+	m := &modelLimiter{}
+	if limits.RequestsPerMinute > 0 {
+		burst := limits.RequestBurst
+		if burst <= 0 {
+			burst = limits.RequestsPerMinute
+		}
+		m.requests = newBucket(limits.RequestsPerMinute, burst)
+	}
+	if limits.TokensPerMinute > 0 {
+		m.tokens = newBucket(limits.TokensPerMinute, limits.TokensPerMinute)
+	}
+	if limits.MaxConcurrent > 0 {
+		m.slots = make(chan struct{}, limits.MaxConcurrent)
+	}
+	return m
+}
+
+// Permit is a granted call. Release it when the call is done.
+type Permit struct {
+	model  *modelLimiter
+	tokens int
+	once   sync.Once
+}
+
+// Acquire waits until a call to model costing tokens may start and returns
+// its permit. It fails without waiting when the call exceeds the model's
+// TokensPerMinute or the wait would outlast ctx's deadline, and gives back
+// what it took when ctx ends while waiting.
+func (l *Limiter) Acquire(ctx context.Context, model, operation string, tokens int) (*Permit, error) {
+	if limit := l.Limits(model).TokensPerMinute; limit > 0 && tokens > limit {
+		return nil, fmt.Errorf("%w: %s %s needs ~%d tokens, limit is %d", ErrExceedsTokenLimit, model, operation, tokens, limit)
+	}
+	m := l.forModel(model)
+	if m.slots != nil {
+		select {
+		case m.slots <- struct{}{}:
+		default:
+			if l.config.OnWait != nil {
+				l.config.OnWait(Wait{Model: model, Operation: operation})
+			}
+			select {
+			case m.slots <- struct{}{}:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+	}
+	permit := &Permit{model: m, tokens: tokens}
+
+	now := time.Now()
+	delay := max(m.requests.reserve(now, 1), m.tokens.reserve(now, float64(tokens)))
+	if delay > 0 {
+		err := l.wait(ctx, model, operation, delay)
+		if err != nil {
+			m.requests.cancel(1)
+			m.tokens.cancel(float64(tokens))
+			permit.release()
+			return nil, err
+		}
+	}
+	return permit, nil
+}
+
+func (l *Limiter) wait(ctx context.Context, model, operation string, delay time.Duration) error {
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+		return fmt.Errorf("%w: %s needs %v: %w", ErrWaitExceedsDeadline, model, delay, context.DeadlineExceeded)
+	}
+	if l.config.OnWait != nil {
+		l.config.OnWait(Wait{Model: model, Operation: operation, Delay: delay})
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Used corrects the permit's token estimate with the tokens the call
+// actually used, as reported by the provider.
+func (p *Permit) Used(tokens int) {
+	if p == nil || tokens <= 0 {
+		return
+	}
+	p.model.tokens.cancel(float64(p.tokens - tokens))
+	p.tokens = tokens
+}
+
+// Release frees the permit's concurrency slot. Only the first call has an
+// effect.
+func (p *Permit) Release() {
+	if p != nil {
+		p.release()
+	}
+}
+
+func (p *Permit) release() {
+	p.once.Do(func() {
+		if p.model.slots != nil {
+			<-p.model.slots
+		}
+	})
+}
+
+// bucket is a token bucket refilled continuously at perMinute up to
+// capacity. Reservations may drive it negative; later callers then wait
+// until the debt is repaid. A nil bucket is unlimited.
+type bucket struct {
+	mu       sync.Mutex
+	capacity float64
+	rate     float64 // per nanosecond
+	level    float64
+	last     time.Time
+}
+
+func newBucket(perMinute, capacity int) *bucket {
+	return &bucket{
+		capacity: float64(capacity),
+		rate:     float64(perMinute) / float64(time.Minute),
+		level:    float64(capacity),
+		last:     time.Now(),
+	}
+}
+
+// reserve takes n and returns how long until the bucket would have held it.
+func (b *bucket) reserve(now time.Time, n float64) time.Duration {
+	if b == nil || n <= 0 {
+		return 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if now.After(b.last) {
+		b.level = math.Min(b.capacity, b.level+float64(now.Sub(b.last))*b.rate)
+		b.last = now
+	}
+	b.level -= n
+	if b.level >= 0 {
+		return 0
+	}
+	return time.Duration(math.Ceil(-b.level / b.rate))
+}
+
+// cancel gives back n, or takes -n when n is negative.
+func (b *bucket) cancel(n float64) {
+	if b == nil || n == 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.level = math.Min(b.capacity, b.level+n)
+}
@@ -0,0 +1,113 @@
+// Package ratelimit provides an iface.LLM decorator that keeps calls within
+// per-model requests-per-minute, tokens-per-minute and concurrency limits,
+// waiting on the client side instead of running into provider quotas.
+package ratelimit
+
+import (
+	"context"
+
+	"github.com/aqua777/ai-flow/llm/history"
+	"github.com/aqua777/ai-flow/llm/iface"
+	"github.com/aqua777/ai-flow/llm/models"
+)
+
+// LLM applies a Limiter to Generate, Chat, Embeddings and BatchEmbeddings
+// calls of the wrapped LLM. ListModels and GetModel are not limited.
+type LLM struct {
+	llm     iface.LLM
+	limiter *Limiter
+	counter *history.Counter
+}
+
+// Ensure LLM implements iface.LLM
+var (
+	_ iface.LLM         = (*LLM)(nil)
+	_ iface.ModelGetter = (*LLM)(nil)
+)
+
+// New wraps llm with limiter; a nil limiter uses a new one with no limits.
+func New(llm iface.LLM, limiter *Limiter) *LLM {
+	if limiter == nil {
+		limiter = NewLimiter(nil)
+	}
+	return &LLM{
+		llm:     llm,
+		limiter: limiter,
+		counter: &history.Counter{Tokenizer: limiter.config.Tokenizer, MessageOverhead: history.DefaultMessageOverhead},
+	}
+}
+
+func (l *LLM) Limiter() *Limiter {
+	return l.limiter
+}
+
+func (l *LLM) ListModels(ctx context.Context) ([]*models.Model, error) {
+	return l.llm.ListModels(ctx)
+}
+
+func (l *LLM) GetModel(ctx context.Context, name string) (*models.Model, error) {
+	return iface.GetModel(ctx, l.llm, name)
+}
+
+func (l *LLM) Generate(ctx context.Context, r *models.GenerateRequest, stream ...func(chunk []byte) error) (*models.GenerateResponse, error) {
+	tokens := l.tokens(r.Prompt) + r.Options.MaxTokens
+	permit, err := l.limiter.Acquire(ctx, r.Model, "Generate", tokens)
+	if err != nil {
+		return nil, err
+	}
+	defer permit.Release()
+	resp, err := l.llm.Generate(ctx, r, stream...)
+	if err != nil {
+		return nil, err
+	}
+	permit.Used(resp.PromptTokens + resp.CompletionTokens)
+	return resp, nil
+}
+
+func (l *LLM) Chat(ctx context.Context, r *models.ChatRequest, stream ...func(chunk []byte) error) (*models.ChatResponse, error) {
+	tokens := l.counter.CountAll(r.Messages) + r.Options.MaxTokens
+	permit, err := l.limiter.Acquire(ctx, r.Model, "Chat", tokens)
+	if err != nil {
+		return nil, err
+	}
+	defer permit.Release()
+	resp, err := l.llm.Chat(ctx, r, stream...)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Metadata != nil {
+		permit.Used(resp.Metadata.PromptTokens + resp.Metadata.CompletionTokens)
+	}
+	return resp, nil
+}
+
+func (l *LLM) Embeddings(ctx context.Context, r *models.EmbeddingsRequest) (*models.EmbeddingsResponse, error) {
+	permit, err := l.limiter.Acquire(ctx, r.Model, "Embeddings", l.tokens(r.Content))
+	if err != nil {
+		return nil, err
+	}
+	defer permit.Release()
+	return l.llm.Embeddings(ctx, r)
+}
+
+// BatchEmbeddings counts as one request to the limiter, although providers
+// may split it into several.
+func (l *LLM) BatchEmbeddings(ctx context.Context, r *models.BatchEmbeddingsRequest) (*models.BatchEmbeddingsResponse, error) {
+	tokens := 0
+	for _, input := range r.Inputs {
+		tokens += l.tokens(input)
+	}
+	permit, err := l.limiter.Acquire(ctx, r.Model, "BatchEmbeddings", tokens)
+	if err != nil {
+		return nil, err
+	}
+	defer permit.Release()
+	return l.llm.BatchEmbeddings(ctx, r)
+}
+
+func (l *LLM) tokens(text string) int {
+	if text == "" {
+		return 0
+	}
+	return len(l.limiter.config.Tokenizer.Encode(text))
+}
@@ -0,0 +1,140 @@
+package ratelimit
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/aqua777/ai-flow/llm/models"
+	mocks "github.com/aqua777/ai-flow/mocks/llm"
+)
+
+type RateLimitTestSuite struct {
+	suite.Suite
+}
+
+func TestRateLimitTestSuite(t *testing.T) {
+	suite.Run(t, new(RateLimitTestSuite))
+}
+
+func chat(model string) *models.ChatRequest {
+	return &models.ChatRequest{Model: model, Messages: []*models.Message{{Role: models.UserRole, Content: "hi there"}}}
+}
+
+func (s *RateLimitTestSuite) deadline(d time.Duration) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	s.T().Cleanup(cancel)
+	return ctx
+}
+
+func (s *RateLimitTestSuite) TestRequestsPerMinute() {
+	var waits []Wait
+	limiter := NewLimiter(&Config{
+		Default: Limits{RequestsPerMinute: 6000, RequestBurst: 1},
+		Models:  map[string]Limits{"slow": {RequestsPerMinute: 1}},
+		OnWait:  func(w Wait) { waits = append(waits, w) },
+	})
+	llm := New(&mocks.MockLLM{}, limiter)
+
+	// 6000 a minute with no burst spaces calls 10ms apart.
+	start := time.Now()
+	for range 4 {
+		_, err := llm.Chat(context.Background(), chat("fast"))
+		s.Require().NoError(err)
+	}
+	s.GreaterOrEqual(time.Since(start), 25*time.Millisecond)
+	s.Len(waits, 3)
+	s.Equal("fast", waits[0].Model)
+	s.Equal("Chat", waits[0].Operation)
+
+	// The second call to "slow" would wait a minute, past the deadline, so
+	// it fails at once. The limiter is shared by every client using it.
+	_, err := llm.Chat(context.Background(), chat("slow"))
+	s.Require().NoError(err)
+	start = time.Now()
+	_, err = New(&mocks.MockLLM{}, limiter).Chat(s.deadline(time.Second), chat("slow"))
+	s.ErrorIs(err, ErrWaitExceedsDeadline)
+	s.ErrorIs(err, context.DeadlineExceeded)
+	s.Less(time.Since(start), 500*time.Millisecond)
+}
+
+func (s *RateLimitTestSuite) TestTokensPerMinute() {
+	inner := &mocks.MockLLM{}
+	inner.Queue(
+		mocks.Response{Metadata: &models.ChatResponseMetadata{PromptTokens: 1}},
+		mocks.Response{Metadata: &models.ChatResponseMetadata{PromptTokens: 1}},
+	)
+	llm := New(inner, NewLimiter(&Config{Default: Limits{TokensPerMinute: 10}}))
+
+	// Each request is estimated at 2 words plus 4 overhead, but reports a
+	// single token, so the bucket keeps room for the next one.
+	for range 2 {
+		_, err := llm.Chat(s.deadline(time.Second), chat("m"))
+		s.Require().NoError(err)
+	}
+	// Without reported usage the estimates stand and the bucket runs dry.
+	_, err := llm.Chat(s.deadline(time.Second), chat("m"))
+	s.Require().NoError(err)
+	_, err = llm.Chat(s.deadline(time.Second), chat("m"))
+	s.ErrorIs(err, ErrWaitExceedsDeadline)
+
+	_, err = llm.Embeddings(s.deadline(time.Second), &models.EmbeddingsRequest{Model: "other", Content: "unlimited"})
+	s.NoError(err)
+}
+
+func (s *RateLimitTestSuite) TestRequestLargerThanTokenLimit() {
+	llm := New(&mocks.MockLLM{}, NewLimiter(&Config{Default: Limits{TokensPerMinute: 10}}))
+	start := time.Now()
+	_, err := llm.Embeddings(context.Background(), &models.EmbeddingsRequest{Model: "m", Content: strings.Repeat("word ", 50)})
+	s.ErrorIs(err, ErrExceedsTokenLimit)
+	s.Less(time.Since(start), 500*time.Millisecond)
+
+	// The rejected call took nothing from the bucket.
+	_, err = llm.Embeddings(s.deadline(time.Second), &models.EmbeddingsRequest{Model: "m", Content: strings.Repeat("word ", 10)})
+	s.NoError(err)
+}
+
+func (s *RateLimitTestSuite) TestMaxConcurrent() {
+	release := make(chan struct{})
+	var inFlight, peak atomic.Int32
+	inner := &mocks.MockLLM{ChatFunc: func(ctx context.Context, r *models.ChatRequest) (mocks.Response, error) {
+		n := inFlight.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		<-release
+		inFlight.Add(-1)
+		return mocks.Response{}, nil
+	}}
+	llm := New(inner, NewLimiter(&Config{Default: Limits{MaxConcurrent: 2}}))
+
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := llm.Chat(context.Background(), chat("m"))
+			s.NoError(err)
+		}()
+	}
+	s.Eventually(func() bool { return inFlight.Load() == 2 }, time.Second, time.Millisecond)
+
+	// A caller whose context ends while waiting for a slot gives up.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := llm.Chat(ctx, chat("m"))
+	s.ErrorIs(err, context.Canceled)
+
+	close(release)
+	wg.Wait()
+	s.Equal(int32(2), peak.Load())
+	s.Len(inner.ChatRequests(), 5)
+}
@@ -0,0 +1,109 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aqua777/ai-flow/llm/anthropic"
+	"github.com/aqua777/ai-flow/llm/gemini"
+	"github.com/aqua777/ai-flow/llm/models"
+	"github.com/aqua777/ai-flow/llm/ollama"
+	"github.com/aqua777/ai-flow/llm/openai"
+)
+
+// Factory builds an LLM from a validated config.
+type Factory func(ctx context.Context, config *LLMConfig) (LLM, error)
+
+var ErrUnknownProvider = errors.New("unknown llm provider")
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Factory)
+)
+
+func init() {
+	Register(models.OLLAMA, func(ctx context.Context, config *LLMConfig) (LLM, error) {
+		return ollama.NewClient(config)
+	})
+	Register(models.OPENAI, func(ctx context.Context, config *LLMConfig) (LLM, error) {
+		return openai.NewClient(config)
+	})
+	Register(models.ANTHROPIC, func(ctx context.Context, config *LLMConfig) (LLM, error) {
+		return anthropic.NewClient(config)
+	})
+	Register(models.GEMINI, func(ctx context.Context, config *LLMConfig) (LLM, error) {
+		return gemini.NewClient(config)
+	})
+}
+
+// Register makes a provider available to New under the given name.
+// Names are case-insensitive. It panics if the name is empty, the factory
+// is nil or the name is already registered, mirroring database/sql.Register.
+func Register(provider string, factory Factory) {
+	name := normalizeProvider(provider)
+	if name == "" {
+		panic("llm: Register provider name is empty")
+	}
+	if factory == nil {
+		panic("llm: Register factory is nil for provider " + name)
+	}
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, exists := registry[name]; exists {
+		panic("llm: Register called twice for provider " + name)
+	}
+	registry[name] = factory
+}
+
+// Providers returns the sorted names of all registered providers.
+func Providers() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func lookupProvider(provider string) (Factory, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	factory, ok := registry[provider]
+	return factory, ok
+}
+
+// unregister removes a provider; it exists so tests can clean up after Register.
+func unregister(provider string) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	delete(registry, normalizeProvider(provider))
+}
+
+func normalizeProvider(provider string) string {
+	return strings.ToLower(strings.TrimSpace(provider))
+}
+
+// New validates the config and builds the LLM registered for config.Provider.
+func New(ctx context.Context, config *LLMConfig) (LLM, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	name := normalizeProvider(config.Provider)
+	factory, ok := lookupProvider(name)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q (registered: %s)", ErrUnknownProvider, config.Provider, strings.Join(Providers(), ", "))
+	}
+	cfg := *config
+	cfg.Provider = name
+	client, err := factory(ctx, &cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s client: %w", name, err)
+	}
+	return client, nil
+}
@@ -0,0 +1,293 @@
+// Package retry provides an iface.LLM decorator that retries transient
+// provider failures with exponential backoff and jitter.
+package retry
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/aqua777/ai-flow/http"
+	"github.com/aqua777/ai-flow/llm/iface"
+	istream "github.com/aqua777/ai-flow/llm/internal/stream"
+	"github.com/aqua777/ai-flow/llm/models"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+const (
+	DefaultMaxAttempts  = 3
+	DefaultInitialDelay = 500 * time.Millisecond
+	DefaultMaxDelay     = 30 * time.Second
+	DefaultMultiplier   = 2.0
+	DefaultJitter       = 0.2
+)
+
+// Attempt describes the outcome of one call to the wrapped LLM.
+type Attempt struct {
+	// Operation is the iface.LLM method, e.g. "Chat" or "Embeddings".
+	Operation string
+	Model     string
+	// Number counts attempts from 1.
+	Number int
+	// Err is the attempt's error, nil when it succeeded.
+	Err error
+	// Retrying is true when another attempt follows after Delay.
+	Retrying bool
+	Delay    time.Duration
+}
+
+// Config controls the retry policy. Zero values take the defaults above.
+type Config struct {
+	// MaxAttempts is the total number of attempts, including the first.
+	MaxAttempts int
+	// InitialDelay is the backoff before the second attempt; each later
+	// backoff is Multiplier times the previous one.
+	InitialDelay time.Duration
+	Multiplier   float64
+	// MaxDelay caps every wait, including one requested by Retry-After.
+	MaxDelay time.Duration
+	// Jitter randomises each backoff by up to this fraction in either
+	// direction so concurrent clients do not retry in lockstep.
+	Jitter        float64
+	DisableJitter bool
+	// Retryable decides whether an error is worth retrying. Defaults to
+	// IsRetryable.
+	Retryable func(err error) bool
+	// OnAttempt, if set, is called after every attempt.
+	OnAttempt func(Attempt)
+}
+
+func (c *Config) withDefaults() Config {
+	config := Config{}
+	if c != nil {
+		config = *c
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = DefaultMaxAttempts
+	}
+	if config.InitialDelay <= 0 {
+		config.InitialDelay = DefaultInitialDelay
+	}
+	if config.MaxDelay <= 0 {
+		config.MaxDelay = DefaultMaxDelay
+	}
+	if config.Multiplier < 1 {
+		config.Multiplier = DefaultMultiplier
+	}
+	if config.Jitter <= 0 {
+		config.Jitter = DefaultJitter
+	}
+	if config.DisableJitter {
+		config.Jitter = 0
+	}
+	if config.Retryable == nil {
+		config.Retryable = IsRetryable
+	}
+	return config
+}
+
+// LLM wraps an iface.LLM and retries calls that fail with a transient error.
+// Streamed calls are only retried until the first chunk reaches the caller's
+// callback; after that a failure is returned as is, since the caller has
+// already consumed part of the answer.
+type LLM struct {
+	llm    iface.LLM
+	config Config
+	sleep  func(ctx context.Context, d time.Duration) error
+}
+
+// Ensure LLM implements iface.LLM
+var (
+	_ iface.LLM         = (*LLM)(nil)
+	_ iface.ModelGetter = (*LLM)(nil)
+)
+
+// New wraps llm with the retry policy in config; a nil config uses the defaults.
+func New(llm iface.LLM, config *Config) *LLM {
+	return &LLM{
+		llm:    llm,
+		config: config.withDefaults(),
+		sleep:  sleep,
+	}
+}
+
+func (l *LLM) ListModels(ctx context.Context) ([]*models.Model, error) {
+	var result []*models.Model
+	err := l.do(ctx, "ListModels", "", func(ctx context.Context) (err error) {
+		result, err = l.llm.ListModels(ctx)
+		return err
+	})
+	return result, err
+}
+
+func (l *LLM) GetModel(ctx context.Context, name string) (*models.Model, error) {
+	var result *models.Model
+	err := l.do(ctx, "GetModel", name, func(ctx context.Context) (err error) {
+		result, err = iface.GetModel(ctx, l.llm, name)
+		return err
+	})
+	return result, err
+}
+
+func (l *LLM) Generate(ctx context.Context, r *models.GenerateRequest, stream ...func(chunk []byte) error) (*models.GenerateResponse, error) {
+	var result *models.GenerateResponse
+	callback, started := istream.Track(stream)
+	err := l.doStream(ctx, "Generate", r.Model, started, func(ctx context.Context) (err error) {
+		result, err = l.llm.Generate(ctx, r, callback...)
+		return err
+	})
+	return result, err
+}
+
+func (l *LLM) Chat(ctx context.Context, r *models.ChatRequest, stream ...func(chunk []byte) error) (*models.ChatResponse, error) {
+	var result *models.ChatResponse
+	callback, started := istream.Track(stream)
+	err := l.doStream(ctx, "Chat", r.Model, started, func(ctx context.Context) (err error) {
+		result, err = l.llm.Chat(ctx, r, callback...)
+		return err
+	})
+	return result, err
+}
+
+func (l *LLM) Embeddings(ctx context.Context, r *models.EmbeddingsRequest) (*models.EmbeddingsResponse, error) {
+	var result *models.EmbeddingsResponse
+	err := l.do(ctx, "Embeddings", r.Model, func(ctx context.Context) (err error) {
+		result, err = l.llm.Embeddings(ctx, r)
+		return err
+	})
+	return result, err
+}
+
+func (l *LLM) BatchEmbeddings(ctx context.Context, r *models.BatchEmbeddingsRequest) (*models.BatchEmbeddingsResponse, error) {
+	var result *models.BatchEmbeddingsResponse
+	err := l.do(ctx, "BatchEmbeddings", r.Model, func(ctx context.Context) (err error) {
+		result, err = l.llm.BatchEmbeddings(ctx, r)
+		return err
+	})
+	return result, err
+}
+
+func (l *LLM) do(ctx context.Context, operation, model string, call func(ctx context.Context) error) error {
+	return l.doStream(ctx, operation, model, istream.NeverStarted, call)
+}
+
+func (l *LLM) doStream(ctx context.Context, operation, model string, started func() bool, call func(ctx context.Context) error) error {
+	backoff := l.config.InitialDelay
+	for number := 1; ; number++ {
+		err := call(ctx)
+		attempt := Attempt{Operation: operation, Model: model, Number: number, Err: err}
+		if err != nil && number < l.config.MaxAttempts && ctx.Err() == nil && !started() && l.config.Retryable(err) {
+			attempt.Retrying = true
+			attempt.Delay = l.delay(err, backoff)
+		}
+		if l.config.OnAttempt != nil {
+			l.config.OnAttempt(attempt)
+		}
+		if !attempt.Retrying {
+			return err
+		}
+		if err := l.sleep(ctx, attempt.Delay); err != nil {
+			return err
+		}
+		backoff = time.Duration(math.Min(float64(backoff)*l.config.Multiplier, float64(l.config.MaxDelay)))
+	}
+}
+
+// delay returns the wait before the next attempt: the server's Retry-After
+// when it sent one, otherwise the jittered backoff.
+func (l *LLM) delay(err error, backoff time.Duration) time.Duration {
+	d := RetryAfter(err)
+	if d <= 0 {
+		d = backoff
+		if l.config.Jitter > 0 {
+			d = time.Duration(float64(d) * (1 + l.config.Jitter*(2*rand.Float64()-1)))
+		}
+	}
+	return min(d, l.config.MaxDelay)
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// retryableStatus lists the HTTP statuses that signal a transient condition:
+// timeouts, rate limits, overload and gateway failures. Anything else (bad
+// requests, auth, not found) fails the same way on every attempt.
+var retryableStatus = map[int]bool{
+	408: true,
+	425: true,
+	429: true,
+	500: true,
+	502: true,
+	503: true,
+	504: true,
+	529: true,
+}
+
+// IsRetryable reports whether err is a transient failure that is safe to
+// retry: a provider error classified as retryable, a retryable HTTP status,
+// a timeout, or a dropped or refused connection. Context cancellation is
+// never retried.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var providerErr *models.ProviderError
+	if errors.As(err, &providerErr) {
+		return providerErr.Retryable
+	}
+	if status := StatusCode(err); status != 0 {
+		return retryableStatus[status]
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// StatusCode returns the HTTP status carried by a provider error, or zero.
+func StatusCode(err error) int {
+	var providerErr *models.ProviderError
+	if errors.As(err, &providerErr) && providerErr.StatusCode != 0 {
+		return providerErr.StatusCode
+	}
+	var statusErr *http.StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode
+	}
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.HTTPStatusCode
+	}
+	var requestErr *openai.RequestError
+	if errors.As(err, &requestErr) {
+		return requestErr.HTTPStatusCode
+	}
+	return 0
+}
+
+// RetryAfter returns the delay a provider asked for with Retry-After, or zero.
+func RetryAfter(err error) time.Duration {
+	var providerErr *models.ProviderError
+	if errors.As(err, &providerErr) && providerErr.RetryAfter > 0 {
+		return providerErr.RetryAfter
+	}
+	var statusErr *http.StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.RetryAfter
+	}
+	return 0
+}
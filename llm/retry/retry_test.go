@@ -0,0 +1,223 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	aihttp "github.com/aqua777/ai-flow/http"
+	"github.com/aqua777/ai-flow/llm/models"
+	"github.com/aqua777/ai-flow/llm/ollama"
+	"github.com/aqua777/ai-flow/llm/openai"
+	mocks "github.com/aqua777/ai-flow/mocks/llm"
+	fakeopenai "github.com/aqua777/ai-flow/mocks/openai"
+)
+
+// flakyLLM fails Chat with the queued errors before succeeding, optionally
+// streaming a chunk before failing.
+type flakyLLM struct {
+	mocks.MockLLM
+	errs        []error
+	chunkBefore bool
+	calls       int
+}
+
+func (f *flakyLLM) Chat(ctx context.Context, r *models.ChatRequest, stream ...func(chunk []byte) error) (*models.ChatResponse, error) {
+	f.calls++
+	if len(stream) > 0 && f.chunkBefore {
+		if err := stream[0]([]byte("partial")); err != nil {
+			return nil, err
+		}
+	}
+	if len(f.errs) > 0 {
+		err := f.errs[0]
+		f.errs = f.errs[1:]
+		return nil, err
+	}
+	return &models.ChatResponse{Content: "ok"}, nil
+}
+
+type RetryTestSuite struct {
+	suite.Suite
+	slept    []time.Duration
+	attempts []Attempt
+}
+
+func TestRetryTestSuite(t *testing.T) {
+	suite.Run(t, new(RetryTestSuite))
+}
+
+func (s *RetryTestSuite) SetupTest() {
+	s.slept = nil
+	s.attempts = nil
+}
+
+func (s *RetryTestSuite) wrap(llm *flakyLLM, config Config) *LLM {
+	config.OnAttempt = func(a Attempt) { s.attempts = append(s.attempts, a) }
+	l := New(llm, &config)
+	l.sleep = func(ctx context.Context, d time.Duration) error {
+		s.slept = append(s.slept, d)
+		return ctx.Err()
+	}
+	return l
+}
+
+func (s *RetryTestSuite) TestRetriesTransientErrors() {
+	inner := &flakyLLM{errs: []error{
+		&aihttp.StatusError{StatusCode: 503},
+		&aihttp.StatusError{StatusCode: 429, RetryAfter: 2 * time.Second},
+	}}
+	l := s.wrap(inner, Config{InitialDelay: 100 * time.Millisecond, DisableJitter: true})
+	resp, err := l.Chat(context.Background(), &models.ChatRequest{Model: "m"})
+	s.Require().NoError(err)
+	s.Equal("ok", resp.Content)
+	s.Equal(3, inner.calls)
+	s.Equal([]time.Duration{100 * time.Millisecond, 2 * time.Second}, s.slept, "second wait honours Retry-After")
+	s.Require().Len(s.attempts, 3)
+	s.Equal(Attempt{Operation: "Chat", Model: "m", Number: 1, Err: &aihttp.StatusError{StatusCode: 503}, Retrying: true, Delay: 100 * time.Millisecond}, s.attempts[0])
+	s.NoError(s.attempts[2].Err)
+	s.False(s.attempts[2].Retrying)
+}
+
+func (s *RetryTestSuite) TestBackoffGrowsAndIsCapped() {
+	inner := &flakyLLM{errs: []error{
+		&aihttp.StatusError{StatusCode: 500},
+		&aihttp.StatusError{StatusCode: 500},
+		&aihttp.StatusError{StatusCode: 500},
+		&aihttp.StatusError{StatusCode: 500, RetryAfter: time.Hour},
+	}}
+	l := s.wrap(inner, Config{MaxAttempts: 5, InitialDelay: time.Second, MaxDelay: 3 * time.Second, DisableJitter: true})
+	_, err := l.Chat(context.Background(), &models.ChatRequest{})
+	s.Require().NoError(err)
+	s.Equal([]time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 3 * time.Second}, s.slept)
+}
+
+func (s *RetryTestSuite) TestJitter() {
+	l := New(&flakyLLM{}, &Config{InitialDelay: time.Second, Jitter: 0.5})
+	for i := 0; i < 20; i++ {
+		d := l.delay(errors.New("x"), time.Second)
+		s.GreaterOrEqual(d, 500*time.Millisecond)
+		s.LessOrEqual(d, 1500*time.Millisecond)
+	}
+}
+
+func (s *RetryTestSuite) TestDoesNotRetryPermanentErrors() {
+	for _, err := range []error{
+		&aihttp.StatusError{StatusCode: 400},
+		&aihttp.StatusError{StatusCode: 401},
+		errors.New("invalid request"),
+		context.Canceled,
+	} {
+		inner := &flakyLLM{errs: []error{err}}
+		_, got := s.wrap(inner, Config{}).Chat(context.Background(), &models.ChatRequest{})
+		s.ErrorIs(got, err)
+		s.Equal(1, inner.calls, "%v", err)
+	}
+}
+
+func (s *RetryTestSuite) TestGivesUpAfterMaxAttempts() {
+	unavailable := &aihttp.StatusError{StatusCode: 503}
+	inner := &flakyLLM{errs: []error{unavailable, unavailable, unavailable, unavailable}}
+	_, err := s.wrap(inner, Config{MaxAttempts: 3}).Chat(context.Background(), &models.ChatRequest{})
+	s.ErrorIs(err, unavailable)
+	s.Equal(3, inner.calls)
+	s.Len(s.slept, 2)
+}
+
+func (s *RetryTestSuite) TestStreamRetriedOnlyBeforeFirstChunk() {
+	callback := func(chunk []byte) error { return nil }
+
+	inner := &flakyLLM{errs: []error{&aihttp.StatusError{StatusCode: 503}}}
+	resp, err := s.wrap(inner, Config{}).Chat(context.Background(), &models.ChatRequest{}, callback)
+	s.Require().NoError(err)
+	s.Equal("ok", resp.Content)
+	s.Equal(2, inner.calls)
+
+	inner = &flakyLLM{errs: []error{&aihttp.StatusError{StatusCode: 503}}, chunkBefore: true}
+	_, err = s.wrap(inner, Config{}).Chat(context.Background(), &models.ChatRequest{}, callback)
+	s.Error(err)
+	s.Equal(1, inner.calls)
+}
+
+func (s *RetryTestSuite) TestCancelledDuringBackoff() {
+	inner := &flakyLLM{errs: []error{&aihttp.StatusError{StatusCode: 503}, &aihttp.StatusError{StatusCode: 503}}}
+	l := New(inner, &Config{InitialDelay: time.Hour})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := l.Chat(ctx, &models.ChatRequest{})
+	s.ErrorIs(err, context.DeadlineExceeded, "the context error wins over the failed attempt's")
+	s.Equal(1, inner.calls)
+
+	inner = &flakyLLM{errs: []error{&aihttp.StatusError{StatusCode: 503}, &aihttp.StatusError{StatusCode: 503}}}
+	ctx, cancel = context.WithCancel(context.Background())
+	l = New(inner, &Config{InitialDelay: time.Hour, OnAttempt: func(Attempt) { cancel() }})
+	_, err = l.Chat(ctx, &models.ChatRequest{}, func(chunk []byte) error { return nil })
+	s.ErrorIs(err, context.Canceled)
+	s.Equal(1, inner.calls)
+}
+
+func (s *RetryTestSuite) TestWithProvider() {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			fmt.Fprint(w, `{"error":"slow down"}`)
+			return
+		}
+		fmt.Fprint(w, `{"embeddings":[[0.5]]}`)
+	}))
+	defer server.Close()
+	client, err := ollama.NewClient(&models.LLMConfig{Url: server.URL})
+	s.Require().NoError(err)
+
+	l := New(client, &Config{OnAttempt: func(a Attempt) { s.attempts = append(s.attempts, a) }})
+	l.sleep = func(ctx context.Context, d time.Duration) error {
+		s.slept = append(s.slept, d)
+		return nil
+	}
+	resp, err := l.Embeddings(context.Background(), &models.EmbeddingsRequest{Model: "nomic", Content: "x"})
+	s.Require().NoError(err)
+	s.Equal([]float32{0.5}, resp.Embeddings)
+	s.Equal([]time.Duration{time.Second}, s.slept)
+	s.Equal(429, StatusCode(s.attempts[0].Err))
+	s.EqualError(s.attempts[0].Err, "ollama: status code: 429, error: slow down")
+	s.ErrorIs(s.attempts[0].Err, models.ErrRateLimited)
+}
+
+func (s *RetryTestSuite) TestWithOpenAI() {
+	server := fakeopenai.NewServer()
+	defer server.Close()
+	server.FailWith("/v1/chat/completions", fakeopenai.Failure{
+		Status:  http.StatusTooManyRequests,
+		Message: "Rate limit reached",
+		Header:  http.Header{"Retry-After": {"2"}},
+	})
+	server.FailWith("/v1/chat/completions", fakeopenai.Failure{
+		Status:  http.StatusTooManyRequests,
+		Message: "Rate limit reached",
+		Header:  http.Header{"Retry-After": {"2"}, "Retry-After-Ms": {"250"}},
+	})
+	server.Queue(mocks.Response{Content: "ok"})
+	client, err := openai.NewClient(&models.LLMConfig{Url: server.URL + "/v1", ApiKey: "key"})
+	s.Require().NoError(err)
+
+	l := New(client, &Config{OnAttempt: func(a Attempt) { s.attempts = append(s.attempts, a) }})
+	l.sleep = func(ctx context.Context, d time.Duration) error {
+		s.slept = append(s.slept, d)
+		return nil
+	}
+	resp, err := l.Chat(context.Background(), &models.ChatRequest{Model: "gpt-4o"}, func(chunk []byte) error { return nil })
+	s.Require().NoError(err)
+	s.Equal("ok", resp.Content)
+	s.Equal([]time.Duration{2 * time.Second, 250 * time.Millisecond}, s.slept, "retry-after-ms takes precedence")
+	s.ErrorIs(s.attempts[0].Err, models.ErrRateLimited)
+	s.Equal(2*time.Second, RetryAfter(s.attempts[0].Err))
+}
@@ -0,0 +1,481 @@
+// Package router provides an iface.LLM that fronts several providers and
+// models, picking a route per request and failing over when one errors.
+package router
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aqua777/ai-flow/llm/iface"
+	istream "github.com/aqua777/ai-flow/llm/internal/stream"
+	"github.com/aqua777/ai-flow/llm/models"
+)
+
+const (
+	DefaultFailureThreshold = 3
+	DefaultCooldown         = 30 * time.Second
+	DefaultMaxCooldown      = 5 * time.Minute
+)
+
+var (
+	ErrNoRoutes        = errors.New("router needs at least one route")
+	ErrNoMatchingRoute = errors.New("no route matches the request")
+)
+
+// Route is one provider/model pair the router can send requests to. Routes
+// are tried in the order they are configured.
+type Route struct {
+	// Name identifies the route in decisions and health reports. Defaults
+	// to "route-<index>".
+	Name string
+	LLM  iface.LLM
+	// Model replaces the request's model on this route. When empty the
+	// request's model is sent unchanged.
+	Model string
+	// ModelPrefixes restricts the route to requests whose model starts with
+	// one of the prefixes, e.g. "gpt-" or "claude-". Empty matches any model.
+	ModelPrefixes []string
+	// Capabilities lists what the route's model supports. Requests needing a
+	// capability missing from the list skip the route; a nil list is taken
+	// to support everything.
+	Capabilities []models.Capability
+	// ContextSize is the model's context window in tokens. When zero it is
+	// looked up once with iface.GetModel (Model.ContextSize); if that is unknown
+	// too, prompts of any length are accepted. A lookup that fails for another
+	// reason than an unknown model is not repeated for the route's Cooldown.
+	ContextSize int
+	// Timeout bounds each call on this route, including a whole stream. A
+	// route that times out fails over like any other error.
+	Timeout time.Duration
+}
+
+// Skip records a route passed over for a request and why.
+type Skip struct {
+	Route  string
+	Reason string
+}
+
+// Decision describes one attempt the router made. Attempts after the first
+// are failovers from the previous route.
+type Decision struct {
+	// Operation is the iface.LLM method, e.g. "Chat" or "Embeddings".
+	Operation      string
+	RequestedModel string
+	// Route and Model are the route tried and the model sent to it.
+	Route   string
+	Model   string
+	Attempt int
+	// Skipped lists the routes that were not eligible for the request.
+	Skipped []Skip
+	Err     error
+	Latency time.Duration
+}
+
+// Config configures a Router.
+type Config struct {
+	Routes []*Route
+	// FailureThreshold is the number of consecutive failures after which a
+	// route is considered unhealthy and skipped for a cooldown period.
+	FailureThreshold int
+	// Cooldown is the first backoff for an unhealthy route. It doubles each
+	// time the route fails again after recovering, up to MaxCooldown.
+	Cooldown    time.Duration
+	MaxCooldown time.Duration
+	// CountTokens estimates the prompt size checked against ContextSize.
+	// Defaults to EstimateTokens.
+	CountTokens func(text string) int
+	// OnDecision, if set, is called after every attempt.
+	OnDecision func(Decision)
+}
+
+// RouteHealth is a snapshot of a route's failure state.
+type RouteHealth struct {
+	Route               string
+	Healthy             bool
+	ConsecutiveFailures int
+	// RetryAt is when an unhealthy route becomes eligible again.
+	RetryAt time.Time
+}
+
+type routeState struct {
+	*Route
+	mu           sync.Mutex
+	failures     int
+	trips        int
+	retryAt      time.Time
+	contextSizes map[string]int
+	// lookupRetryAt holds, per model, when a failed context size lookup
+	// may be tried again.
+	lookupRetryAt map[string]time.Time
+}
+
+// Router implements iface.LLM on top of a list of routes. For every request
+// it filters the routes by model prefix, required capability and prompt
+// length, orders healthy routes before unhealthy ones and tries them in turn
+// until one succeeds. Streams fail over only until the first chunk has been
+// delivered to the caller.
+type Router struct {
+	routes []*routeState
+	config Config
+	now    func() time.Time
+}
+
+// Ensure Router implements iface.LLM
+var (
+	_ iface.LLM         = (*Router)(nil)
+	_ iface.ModelGetter = (*Router)(nil)
+)
+
+func New(config *Config) (*Router, error) {
+	if config == nil || len(config.Routes) == 0 {
+		return nil, ErrNoRoutes
+	}
+	r := &Router{config: *config, now: time.Now}
+	if r.config.FailureThreshold <= 0 {
+		r.config.FailureThreshold = DefaultFailureThreshold
+	}
+	if r.config.Cooldown <= 0 {
+		r.config.Cooldown = DefaultCooldown
+	}
+	if r.config.MaxCooldown <= 0 {
+		r.config.MaxCooldown = DefaultMaxCooldown
+	}
+	if r.config.CountTokens == nil {
+		r.config.CountTokens = EstimateTokens
+	}
+	for i, route := range config.Routes {
+		if route == nil || route.LLM == nil {
+			return nil, fmt.Errorf("route %d has no LLM", i)
+		}
+		copied := *route
+		if copied.Name == "" {
+			copied.Name = fmt.Sprintf("route-%d", i)
+		}
+		r.routes = append(r.routes, &routeState{Route: &copied, contextSizes: map[string]int{}, lookupRetryAt: map[string]time.Time{}})
+	}
+	return r, nil
+}
+
+// EstimateTokens approximates a token count at four characters per token.
+func EstimateTokens(text string) int {
+	return (len(text) + 3) / 4
+}
+
+// Health reports the failure state of every route.
+func (r *Router) Health() []RouteHealth {
+	now := r.now()
+	result := make([]RouteHealth, len(r.routes))
+	for i, route := range r.routes {
+		route.mu.Lock()
+		result[i] = RouteHealth{
+			Route:               route.Name,
+			Healthy:             !route.retryAt.After(now),
+			ConsecutiveFailures: route.failures,
+			RetryAt:             route.retryAt,
+		}
+		route.mu.Unlock()
+	}
+	return result
+}
+
+// request captures the traits routing looks at.
+type request struct {
+	operation    string
+	model        string
+	capabilities []models.Capability
+	prompt       string
+}
+
+func (r *Router) ListModels(ctx context.Context) ([]*models.Model, error) {
+	var result []*models.Model
+	seen := map[string]bool{}
+	var errs []error
+	for _, route := range r.routes {
+		list, err := route.LLM.ListModels(ctx)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", route.Name, err))
+			continue
+		}
+		for _, m := range list {
+			if !seen[m.ID] {
+				seen[m.ID] = true
+				result = append(result, m)
+			}
+		}
+	}
+	if len(result) == 0 && len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return result, nil
+}
+
+// GetModel asks each route in turn and returns the first that knows name.
+func (r *Router) GetModel(ctx context.Context, name string) (*models.Model, error) {
+	var errs []error
+	for _, route := range r.routes {
+		m, err := iface.GetModel(ctx, route.LLM, name)
+		if err == nil {
+			return m, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", route.Name, err))
+	}
+	return nil, errors.Join(errs...)
+}
+
+func (r *Router) Generate(ctx context.Context, req *models.GenerateRequest, stream ...func(chunk []byte) error) (*models.GenerateResponse, error) {
+	var result *models.GenerateResponse
+	callback, started := istream.Track(stream)
+	err := r.do(ctx, &request{
+		operation:    "Generate",
+		model:        req.Model,
+		capabilities: []models.Capability{models.CapabilityCompletion},
+		prompt:       req.Prompt,
+	}, started, func(ctx context.Context, route *routeState, model string) (err error) {
+		routed := *req
+		routed.Model = model
+		result, err = route.LLM.Generate(ctx, &routed, callback...)
+		return err
+	})
+	return result, err
+}
+
+func (r *Router) Chat(ctx context.Context, req *models.ChatRequest, stream ...func(chunk []byte) error) (*models.ChatResponse, error) {
+	capabilities := []models.Capability{models.CapabilityCompletion}
+	if len(req.Tools) > 0 {
+		capabilities = append(capabilities, models.CapabilityTools)
+	}
+	if models.HasImages(req.Messages) {
+		capabilities = append(capabilities, models.CapabilityVision)
+	}
+	var prompt strings.Builder
+	for _, msg := range req.Messages {
+		prompt.WriteString(msg.Content)
+		prompt.WriteString("\n")
+	}
+
+	var result *models.ChatResponse
+	callback, started := istream.Track(stream)
+	err := r.do(ctx, &request{
+		operation:    "Chat",
+		model:        req.Model,
+		capabilities: capabilities,
+		prompt:       prompt.String(),
+	}, started, func(ctx context.Context, route *routeState, model string) (err error) {
+		routed := *req
+		routed.Model = model
+		result, err = route.LLM.Chat(ctx, &routed, callback...)
+		return err
+	})
+	return result, err
+}
+
+func (r *Router) Embeddings(ctx context.Context, req *models.EmbeddingsRequest) (*models.EmbeddingsResponse, error) {
+	var result *models.EmbeddingsResponse
+	err := r.do(ctx, &request{
+		operation:    "Embeddings",
+		model:        req.Model,
+		capabilities: []models.Capability{models.CapabilityEmbedding},
+		prompt:       req.Content,
+	}, istream.NeverStarted, func(ctx context.Context, route *routeState, model string) (err error) {
+		routed := *req
+		routed.Model = model
+		result, err = route.LLM.Embeddings(ctx, &routed)
+		return err
+	})
+	return result, err
+}
+
+func (r *Router) BatchEmbeddings(ctx context.Context, req *models.BatchEmbeddingsRequest) (*models.BatchEmbeddingsResponse, error) {
+	// Inputs are embedded one by one against the context window, so the
+	// longest input is what has to fit.
+	longest := ""
+	for _, input := range req.Inputs {
+		if len(input) > len(longest) {
+			longest = input
+		}
+	}
+	var result *models.BatchEmbeddingsResponse
+	err := r.do(ctx, &request{
+		operation:    "BatchEmbeddings",
+		model:        req.Model,
+		capabilities: []models.Capability{models.CapabilityEmbedding},
+		prompt:       longest,
+	}, istream.NeverStarted, func(ctx context.Context, route *routeState, model string) (err error) {
+		routed := *req
+		routed.Model = model
+		result, err = route.LLM.BatchEmbeddings(ctx, &routed)
+		return err
+	})
+	return result, err
+}
+
+func (r *Router) do(ctx context.Context, req *request, started func() bool, call func(ctx context.Context, route *routeState, model string) error) error {
+	candidates, skipped := r.selectRoutes(ctx, req)
+	if len(candidates) == 0 {
+		err := fmt.Errorf("%w: %s %q", ErrNoMatchingRoute, req.operation, req.model)
+		r.decide(Decision{Operation: req.operation, RequestedModel: req.model, Skipped: skipped, Err: err})
+		return err
+	}
+
+	var errs []error
+	for i, route := range candidates {
+		model := route.modelFor(req.model)
+		start := r.now()
+		err := r.callRoute(ctx, route, model, call)
+		r.decide(Decision{
+			Operation:      req.operation,
+			RequestedModel: req.model,
+			Route:          route.Name,
+			Model:          model,
+			Attempt:        i + 1,
+			Skipped:        skipped,
+			Err:            err,
+			Latency:        r.now().Sub(start),
+		})
+		if err == nil {
+			r.recordSuccess(route)
+			return nil
+		}
+		if ctx.Err() != nil {
+			// The caller gave up; that says nothing about the route.
+			return err
+		}
+		r.recordFailure(route)
+		errs = append(errs, fmt.Errorf("%s: %w", route.Name, err))
+		if started() {
+			return err
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (r *Router) callRoute(ctx context.Context, route *routeState, model string, call func(ctx context.Context, route *routeState, model string) error) error {
+	if route.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, route.Timeout)
+		defer cancel()
+	}
+	return call(ctx, route, model)
+}
+
+func (r *Router) decide(decision Decision) {
+	if r.config.OnDecision != nil {
+		r.config.OnDecision(decision)
+	}
+}
+
+// selectRoutes returns the routes eligible for req, healthy ones first, and
+// the reasons the others were skipped. Unhealthy routes are kept as a last
+// resort rather than failing the request outright.
+func (r *Router) selectRoutes(ctx context.Context, req *request) ([]*routeState, []Skip) {
+	var healthy, unhealthy []*routeState
+	var skipped []Skip
+	now := r.now()
+	for _, route := range r.routes {
+		if reason := r.ineligible(ctx, route, req); reason != "" {
+			skipped = append(skipped, Skip{Route: route.Name, Reason: reason})
+			continue
+		}
+		route.mu.Lock()
+		cooling := route.retryAt.After(now)
+		route.mu.Unlock()
+		if cooling {
+			unhealthy = append(unhealthy, route)
+			continue
+		}
+		healthy = append(healthy, route)
+	}
+	return append(healthy, unhealthy...), skipped
+}
+
+func (r *Router) ineligible(ctx context.Context, route *routeState, req *request) string {
+	if len(route.ModelPrefixes) > 0 && !slices.ContainsFunc(route.ModelPrefixes, func(prefix string) bool {
+		return req.model != "" && strings.HasPrefix(req.model, prefix)
+	}) {
+		return fmt.Sprintf("model %q does not match prefixes %v", req.model, route.ModelPrefixes)
+	}
+	if route.Capabilities != nil {
+		for _, capability := range req.capabilities {
+			if !slices.Contains(route.Capabilities, capability) {
+				return fmt.Sprintf("missing capability %q", capability)
+			}
+		}
+	}
+	if req.prompt != "" {
+		if size := r.contextSize(ctx, route, route.modelFor(req.model)); size > 0 {
+			if tokens := r.config.CountTokens(req.prompt); tokens > size {
+				return fmt.Sprintf("prompt of ~%d tokens exceeds context size %d", tokens, size)
+			}
+		}
+	}
+	return ""
+}
+
+// contextSize returns the route's context window for model, looking it up
+// with iface.GetModel once per model when the route does not configure one.
+// A failed lookup leaves the size unknown until Cooldown has passed, so a
+// provider that is down does not cost every request an extra round trip
+// before failover.
+func (r *Router) contextSize(ctx context.Context, route *routeState, model string) int {
+	if route.ContextSize > 0 {
+		return route.ContextSize
+	}
+	route.mu.Lock()
+	size, ok := route.contextSizes[model]
+	retryAt := route.lookupRetryAt[model]
+	route.mu.Unlock()
+	if ok || r.now().Before(retryAt) {
+		return size
+	}
+	m, err := iface.GetModel(ctx, route.LLM, model)
+	route.mu.Lock()
+	defer route.mu.Unlock()
+	switch {
+	case err == nil:
+		size = m.ContextSize
+	case !errors.Is(err, models.ErrModelNotFound):
+		route.lookupRetryAt[model] = r.now().Add(r.config.Cooldown)
+		return 0
+	}
+	delete(route.lookupRetryAt, model)
+	route.contextSizes[model] = size
+	return size
+}
+
+func (route *routeState) modelFor(requested string) string {
+	if route.Model != "" {
+		return route.Model
+	}
+	return requested
+}
+
+func (r *Router) recordSuccess(route *routeState) {
+	route.mu.Lock()
+	defer route.mu.Unlock()
+	route.failures = 0
+	route.trips = 0
+	route.retryAt = time.Time{}
+}
+
+// recordFailure counts a failure and, once the threshold is reached, puts the
+// route into a cooldown that doubles with every further trip.
+func (r *Router) recordFailure(route *routeState) {
+	route.mu.Lock()
+	defer route.mu.Unlock()
+	route.failures++
+	if route.failures < r.config.FailureThreshold {
+		return
+	}
+	cooldown := r.config.Cooldown
+	for i := 0; i < route.trips && cooldown < r.config.MaxCooldown; i++ {
+		cooldown *= 2
+	}
+	cooldown = min(cooldown, r.config.MaxCooldown)
+	route.trips++
+	route.retryAt = r.now().Add(cooldown)
+}
@@ -0,0 +1,285 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/aqua777/ai-flow/llm/models"
+	mocks "github.com/aqua777/ai-flow/mocks/llm"
+)
+
+// fakeLLM answers with its name, failing while err is set.
+type fakeLLM struct {
+	mocks.MockLLM
+	name   string
+	err    error
+	delay  time.Duration
+	models []*models.Model
+	chunk  bool
+	calls  []string
+	// listErr fails ListModels, and so the model lookup, while set.
+	listErr error
+	lookups int
+}
+
+func (f *fakeLLM) ListModels(ctx context.Context) ([]*models.Model, error) {
+	f.lookups++
+	return f.models, f.listErr
+}
+
+func (f *fakeLLM) Chat(ctx context.Context, r *models.ChatRequest, stream ...func(chunk []byte) error) (*models.ChatResponse, error) {
+	f.calls = append(f.calls, r.Model)
+	if f.chunk && len(stream) > 0 {
+		if err := stream[0]([]byte(f.name)); err != nil {
+			return nil, err
+		}
+	}
+	if f.delay > 0 {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(f.delay):
+		}
+	}
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &models.ChatResponse{Content: f.name}, nil
+}
+
+func (f *fakeLLM) Embeddings(ctx context.Context, r *models.EmbeddingsRequest) (*models.EmbeddingsResponse, error) {
+	f.calls = append(f.calls, r.Model)
+	return &models.EmbeddingsResponse{Embeddings: []float32{1}}, f.err
+}
+
+type RouterTestSuite struct {
+	suite.Suite
+	decisions []Decision
+	now       time.Time
+}
+
+func TestRouterTestSuite(t *testing.T) {
+	suite.Run(t, new(RouterTestSuite))
+}
+
+func (s *RouterTestSuite) SetupTest() {
+	s.decisions = nil
+	s.now = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+}
+
+func (s *RouterTestSuite) newRouter(config Config) *Router {
+	config.OnDecision = func(d Decision) { s.decisions = append(s.decisions, d) }
+	r, err := New(&config)
+	s.Require().NoError(err)
+	r.now = func() time.Time { return s.now }
+	return r
+}
+
+func chat(model, content string) *models.ChatRequest {
+	return &models.ChatRequest{Model: model, Messages: []*models.Message{{Role: models.UserRole, Content: content}}}
+}
+
+func (s *RouterTestSuite) TestNew() {
+	_, err := New(nil)
+	s.ErrorIs(err, ErrNoRoutes)
+	_, err = New(&Config{Routes: []*Route{{Name: "x"}}})
+	s.ErrorContains(err, "no LLM")
+}
+
+func (s *RouterTestSuite) TestFailover() {
+	openai := &fakeLLM{name: "openai", err: errors.New("status code: 503")}
+	ollama := &fakeLLM{name: "ollama"}
+	r := s.newRouter(Config{Routes: []*Route{
+		{Name: "openai", LLM: openai},
+		{Name: "ollama", LLM: ollama, Model: "llama3"},
+	}})
+	resp, err := r.Chat(context.Background(), chat("gpt-4o", "hi"))
+	s.Require().NoError(err)
+	s.Equal("ollama", resp.Content)
+	s.Equal([]string{"llama3"}, ollama.calls)
+	s.Require().Len(s.decisions, 2)
+	s.Equal("openai", s.decisions[0].Route)
+	s.Equal("gpt-4o", s.decisions[0].Model)
+	s.Error(s.decisions[0].Err)
+	s.Equal("ollama", s.decisions[1].Route)
+	s.Equal(2, s.decisions[1].Attempt)
+	s.NoError(s.decisions[1].Err)
+}
+
+func (s *RouterTestSuite) TestAllRoutesFail() {
+	r := s.newRouter(Config{Routes: []*Route{
+		{Name: "a", LLM: &fakeLLM{err: errors.New("boom a")}},
+		{Name: "b", LLM: &fakeLLM{err: errors.New("boom b")}},
+	}})
+	_, err := r.Chat(context.Background(), chat("m", "hi"))
+	s.ErrorContains(err, "a: boom a")
+	s.ErrorContains(err, "b: boom b")
+}
+
+func (s *RouterTestSuite) TestTimeoutFailsOver() {
+	r := s.newRouter(Config{Routes: []*Route{
+		{Name: "slow", LLM: &fakeLLM{name: "slow", delay: time.Second}, Timeout: 10 * time.Millisecond},
+		{Name: "fast", LLM: &fakeLLM{name: "fast"}},
+	}})
+	resp, err := r.Chat(context.Background(), chat("m", "hi"))
+	s.Require().NoError(err)
+	s.Equal("fast", resp.Content)
+	s.ErrorIs(s.decisions[0].Err, context.DeadlineExceeded)
+}
+
+func (s *RouterTestSuite) TestRoutesByModelPrefix() {
+	openai := &fakeLLM{name: "openai"}
+	anthropic := &fakeLLM{name: "anthropic"}
+	r := s.newRouter(Config{Routes: []*Route{
+		{Name: "openai", LLM: openai, ModelPrefixes: []string{"gpt-", "o1"}},
+		{Name: "anthropic", LLM: anthropic, ModelPrefixes: []string{"claude-"}},
+	}})
+	resp, err := r.Chat(context.Background(), chat("claude-sonnet", "hi"))
+	s.Require().NoError(err)
+	s.Equal("anthropic", resp.Content)
+	s.Equal([]Skip{{Route: "openai", Reason: `model "claude-sonnet" does not match prefixes [gpt- o1]`}}, s.decisions[0].Skipped)
+
+	_, err = r.Chat(context.Background(), chat("llama3", "hi"))
+	s.ErrorIs(err, ErrNoMatchingRoute)
+}
+
+func (s *RouterTestSuite) TestRoutesByCapability() {
+	text := &fakeLLM{name: "text"}
+	vision := &fakeLLM{name: "vision"}
+	embed := &fakeLLM{name: "embed"}
+	r := s.newRouter(Config{Routes: []*Route{
+		{Name: "text", LLM: text, Capabilities: []models.Capability{models.CapabilityCompletion, models.CapabilityTools}},
+		{Name: "vision", LLM: vision, Capabilities: []models.Capability{models.CapabilityCompletion, models.CapabilityVision}},
+		{Name: "embed", LLM: embed, Model: "nomic", Capabilities: []models.Capability{models.CapabilityEmbedding}},
+	}})
+
+	req := chat("m", "what is this?")
+	req.Messages[0].Images = []*models.Image{models.NewImage([]byte("png"), "image/png")}
+	resp, err := r.Chat(context.Background(), req)
+	s.Require().NoError(err)
+	s.Equal("vision", resp.Content)
+
+	req = chat("m", "call a tool")
+	req.Tools = []*models.Tool{{Name: "t"}}
+	resp, err = r.Chat(context.Background(), req)
+	s.Require().NoError(err)
+	s.Equal("text", resp.Content)
+
+	_, err = r.Embeddings(context.Background(), &models.EmbeddingsRequest{Content: "x"})
+	s.Require().NoError(err)
+	s.Equal([]string{"nomic"}, embed.calls)
+}
+
+func (s *RouterTestSuite) TestRoutesByContextSize() {
+	small := &fakeLLM{name: "small", models: []*models.Model{{ID: "tiny", ContextSize: 10}}}
+	large := &fakeLLM{name: "large"}
+	r := s.newRouter(Config{Routes: []*Route{
+		{Name: "small", LLM: small, Model: "tiny"},
+		{Name: "large", LLM: large, ContextSize: 1000},
+	}})
+	resp, err := r.Chat(context.Background(), chat("m", "short"))
+	s.Require().NoError(err)
+	s.Equal("small", resp.Content)
+
+	resp, err = r.Chat(context.Background(), chat("m", strings.Repeat("long ", 20)))
+	s.Require().NoError(err)
+	s.Equal("large", resp.Content)
+	s.Equal("small", s.decisions[1].Skipped[0].Route)
+	s.Contains(s.decisions[1].Skipped[0].Reason, "exceeds context size 10")
+}
+
+func (s *RouterTestSuite) TestFailedContextSizeLookupIsCached() {
+	down := errors.New("connection refused")
+	primary := &fakeLLM{name: "primary", err: down, listErr: down}
+	backup := &fakeLLM{name: "backup"}
+	r := s.newRouter(Config{Routes: []*Route{
+		{Name: "primary", LLM: primary},
+		{Name: "backup", LLM: backup, ContextSize: 1000},
+	}})
+	for range 3 {
+		resp, err := r.Chat(context.Background(), chat("m", "hi"))
+		s.Require().NoError(err)
+		s.Equal("backup", resp.Content)
+	}
+	s.Equal(1, primary.lookups, "a failed lookup is not repeated on every request")
+
+	s.now = s.now.Add(DefaultMaxCooldown)
+	primary.err, primary.listErr = nil, nil
+	primary.models = []*models.Model{{ID: "m", ContextSize: 10}}
+	resp, err := r.Chat(context.Background(), chat("m", strings.Repeat("long ", 20)))
+	s.Require().NoError(err)
+	s.Equal("backup", resp.Content)
+	s.Equal(2, primary.lookups, "the lookup is retried after the cooldown")
+	s.Contains(s.decisions[len(s.decisions)-1].Skipped[0].Reason, "exceeds context size 10")
+}
+
+func (s *RouterTestSuite) TestStreamFailsOverOnlyBeforeFirstChunk() {
+	broken := &fakeLLM{name: "broken", err: errors.New("reset")}
+	ok := &fakeLLM{name: "ok"}
+	r := s.newRouter(Config{Routes: []*Route{{Name: "broken", LLM: broken}, {Name: "ok", LLM: ok}}})
+	callback := func(chunk []byte) error { return nil }
+
+	_, err := r.Chat(context.Background(), chat("m", "hi"), callback)
+	s.Require().NoError(err)
+
+	broken.chunk = true
+	_, err = r.Chat(context.Background(), chat("m", "hi"), callback)
+	s.ErrorContains(err, "reset")
+	s.Len(ok.calls, 1, "no failover once the caller has seen output")
+}
+
+func (s *RouterTestSuite) TestHealthBackoff() {
+	primary := &fakeLLM{name: "primary", err: errors.New("down")}
+	backup := &fakeLLM{name: "backup"}
+	r := s.newRouter(Config{
+		Routes:           []*Route{{Name: "primary", LLM: primary}, {Name: "backup", LLM: backup}},
+		FailureThreshold: 2,
+		Cooldown:         time.Minute,
+		MaxCooldown:      3 * time.Minute,
+	})
+	for i := 0; i < 2; i++ {
+		_, err := r.Chat(context.Background(), chat("m", "hi"))
+		s.Require().NoError(err)
+	}
+	s.Len(primary.calls, 2)
+	health := r.Health()
+	s.False(health[0].Healthy)
+	s.Equal(s.now.Add(time.Minute), health[0].RetryAt)
+	s.True(health[1].Healthy)
+
+	// While cooling down the primary is tried only after the backup.
+	_, err := r.Chat(context.Background(), chat("m", "hi"))
+	s.Require().NoError(err)
+	s.Len(primary.calls, 2)
+
+	// After the cooldown a failed probe trips it again for twice as long.
+	s.now = s.now.Add(2 * time.Minute)
+	_, err = r.Chat(context.Background(), chat("m", "hi"))
+	s.Require().NoError(err)
+	s.Len(primary.calls, 3)
+	s.Equal(s.now.Add(2*time.Minute), r.Health()[0].RetryAt)
+
+	// Recovery resets the state.
+	s.now = s.now.Add(3 * time.Minute)
+	primary.err = nil
+	resp, err := r.Chat(context.Background(), chat("m", "hi"))
+	s.Require().NoError(err)
+	s.Equal("primary", resp.Content)
+	s.Equal(RouteHealth{Route: "primary", Healthy: true}, r.Health()[0])
+}
+
+func (s *RouterTestSuite) TestUnhealthyRoutesAreLastResort() {
+	only := &fakeLLM{name: "only", err: errors.New("down")}
+	r := s.newRouter(Config{Routes: []*Route{{Name: "only", LLM: only}}, FailureThreshold: 1})
+	_, err := r.Chat(context.Background(), chat("m", "hi"))
+	s.Error(err)
+	only.err = nil
+	resp, err := r.Chat(context.Background(), chat("m", "hi"))
+	s.Require().NoError(err)
+	s.Equal("only", resp.Content)
+}
@@ -0,0 +1,186 @@
+// Package session keeps multi-turn conversations with an iface.LLM,
+// appending each exchange to a persistent history.
+package session
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/google/uuid"
+
+	"github.com/aqua777/ai-flow/llm/history"
+	"github.com/aqua777/ai-flow/llm/iface"
+	"github.com/aqua777/ai-flow/llm/models"
+)
+
+var ErrNothingToUndo = errors.New("session has no turn to undo")
+
+// Config describes a session. Zero values take the defaults.
+type Config struct {
+	// ID identifies the session in the store. Defaults to a random UUID; an
+	// existing ID resumes that session's history.
+	ID           string
+	Model        string
+	SystemPrompt string
+	Tools        []*models.Tool
+	Options      models.RequestOptions
+	// Store persists the history. Defaults to a new MemoryStore.
+	Store Store
+	// Trimmer, if set, fits each request to the model's context window. The
+	// stored history itself is never trimmed.
+	Trimmer *history.Trimmer
+	// ContextSize is the model's context window for Trimmer. When zero it is
+	// looked up once with iface.GetModel.
+	ContextSize int
+}
+
+// Session is a conversation with an LLM. The system prompt is sent with
+// every request but is not part of the stored history. A Session is safe for
+// concurrent use; turns are sent one at a time.
+type Session struct {
+	llm      iface.LLM
+	config   Config
+	mu       sync.Mutex
+	messages []*models.Message
+}
+
+// New starts a session, loading its history from the store when config.ID
+// names an existing one. A nil config uses the defaults.
+func New(ctx context.Context, llm iface.LLM, config *Config) (*Session, error) {
+	c := Config{}
+	if config != nil {
+		c = *config
+	}
+	if c.ID == "" {
+		c.ID = uuid.New().String()
+	}
+	if c.Store == nil {
+		c.Store = NewMemoryStore()
+	}
+	messages, err := c.Store.Load(ctx, c.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &Session{llm: llm, config: c, messages: messages}, nil
+}
+
+func (s *Session) ID() string {
+	return s.config.ID
+}
+
+// Messages returns a copy of the history, without the system prompt.
+func (s *Session) Messages() []*models.Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return clone(s.messages)
+}
+
+// Send sends content as the next user message and appends the reply. A
+// stream callback receives the reply as it is generated; the history is
+// only updated once the reply is complete, so a failed turn leaves no trace.
+func (s *Session) Send(ctx context.Context, content string, stream ...func(chunk []byte) error) (*models.ChatResponse, error) {
+	return s.SendMessages(ctx, []*models.Message{{Role: models.UserRole, Content: content}}, stream...)
+}
+
+// SendMessages sends messages, such as a user message with images or the
+// results of the tool calls in the last reply, and appends the reply.
+func (s *Session) SendMessages(ctx context.Context, messages []*models.Message, stream ...func(chunk []byte) error) (*models.ChatResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	conversation := append(clone(s.messages), messages...)
+	req := &models.ChatRequest{
+		Model:    s.config.Model,
+		Messages: conversation,
+		Tools:    s.config.Tools,
+		Options:  s.config.Options,
+	}
+	if s.config.SystemPrompt != "" {
+		req.Messages = append([]*models.Message{{Role: models.SystemRole, Content: s.config.SystemPrompt}}, conversation...)
+	}
+	if err := s.trim(ctx, req); err != nil {
+		return nil, err
+	}
+	resp, err := s.llm.Chat(ctx, req, stream...)
+	if err != nil {
+		return nil, err
+	}
+
+	turn := append(clone(messages), resp.Message())
+	if err := s.config.Store.Append(ctx, s.config.ID, turn...); err != nil {
+		return nil, err
+	}
+	s.messages = append(s.messages, turn...)
+	return resp, nil
+}
+
+func (s *Session) trim(ctx context.Context, req *models.ChatRequest) error {
+	if s.config.Trimmer == nil {
+		return nil
+	}
+	if s.config.ContextSize == 0 {
+		model, err := iface.GetModel(ctx, s.llm, s.config.Model)
+		if err != nil {
+			// Send untrimmed and look the model up again next turn.
+			return ctx.Err()
+		}
+		s.config.ContextSize = model.ContextSize
+		if s.config.ContextSize == 0 {
+			// Unknown; a negative size means no budget and no further lookups.
+			s.config.ContextSize = -1
+		}
+	}
+	_, err := s.config.Trimmer.FitRequest(ctx, req, &models.Model{ContextSize: s.config.ContextSize})
+	return err
+}
+
+// Undo removes the last turn: the last user message and everything after it.
+// It returns the removed messages.
+func (s *Session) Undo(ctx context.Context) ([]*models.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	last := -1
+	for i, m := range s.messages {
+		if m.Role == models.UserRole {
+			last = i
+		}
+	}
+	if last < 0 {
+		return nil, ErrNothingToUndo
+	}
+	if err := s.config.Store.Save(ctx, s.config.ID, s.messages[:last]); err != nil {
+		return nil, err
+	}
+	removed := s.messages[last:]
+	s.messages = s.messages[:last:last]
+	return removed, nil
+}
+
+// Fork copies the session into a new one with its own ID, so the
+// conversation can branch; id defaults to a random UUID. Undo on the fork
+// first to branch from an earlier turn.
+func (s *Session) Fork(ctx context.Context, id string) (*Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	config := s.config
+	config.ID = id
+	if config.ID == "" {
+		config.ID = uuid.New().String()
+	}
+	if err := config.Store.Save(ctx, config.ID, s.messages); err != nil {
+		return nil, err
+	}
+	return &Session{llm: s.llm, config: config, messages: clone(s.messages)}, nil
+}
+
+// Clear empties the history and removes it from the store.
+func (s *Session) Clear(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.config.Store.Delete(ctx, s.config.ID); err != nil {
+		return err
+	}
+	s.messages = nil
+	return nil
+}
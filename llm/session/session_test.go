@@ -0,0 +1,202 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/aqua777/ai-flow/llm/history"
+	"github.com/aqua777/ai-flow/llm/models"
+	mocks "github.com/aqua777/ai-flow/mocks/llm"
+)
+
+// echoLLM replies "re: <last message>" and records every request.
+type echoLLM struct {
+	mocks.MockLLM
+	err      error
+	models   []*models.Model
+	requests []*models.ChatRequest
+}
+
+func (e *echoLLM) ListModels(ctx context.Context) ([]*models.Model, error) {
+	return e.models, nil
+}
+
+func (e *echoLLM) Chat(ctx context.Context, r *models.ChatRequest, stream ...func(chunk []byte) error) (*models.ChatResponse, error) {
+	e.requests = append(e.requests, r)
+	if e.err != nil {
+		return nil, e.err
+	}
+	reply := "re: " + r.Messages[len(r.Messages)-1].Content
+	if len(stream) > 0 {
+		if err := stream[0]([]byte(reply)); err != nil {
+			return nil, err
+		}
+	}
+	return &models.ChatResponse{Content: reply}, nil
+}
+
+type SessionTestSuite struct {
+	suite.Suite
+	llm *echoLLM
+}
+
+func TestSessionTestSuite(t *testing.T) {
+	suite.Run(t, new(SessionTestSuite))
+}
+
+func (s *SessionTestSuite) SetupTest() {
+	s.llm = &echoLLM{}
+}
+
+func user(content string) *models.Message {
+	return &models.Message{Role: models.UserRole, Content: content}
+}
+
+func assistant(content string) *models.Message {
+	return &models.Message{Role: models.AssistantRole, Content: content}
+}
+
+func (s *SessionTestSuite) TestSend() {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	session, err := New(ctx, s.llm, &Config{ID: "s1", Model: "m", SystemPrompt: "Be brief.", Store: store})
+	s.Require().NoError(err)
+	s.Equal("s1", session.ID())
+
+	_, err = session.Send(ctx, "hi")
+	s.Require().NoError(err)
+	var chunks []string
+	resp, err := session.Send(ctx, "again", func(chunk []byte) error {
+		chunks = append(chunks, string(chunk))
+		return nil
+	})
+	s.Require().NoError(err)
+	s.Equal("re: again", resp.Content)
+	s.Equal([]string{"re: again"}, chunks)
+
+	want := []*models.Message{user("hi"), assistant("re: hi"), user("again"), assistant("re: again")}
+	s.Equal(want, session.Messages())
+	s.Equal("m", s.llm.requests[1].Model)
+	s.Equal(append([]*models.Message{{Role: models.SystemRole, Content: "Be brief."}}, want[:3]...), s.llm.requests[1].Messages)
+
+	stored, err := store.Load(ctx, "s1")
+	s.Require().NoError(err)
+	s.Equal(want, stored, "the system prompt is not stored")
+
+	resumed, err := New(ctx, s.llm, &Config{ID: "s1", Store: store})
+	s.Require().NoError(err)
+	s.Equal(want, resumed.Messages())
+}
+
+func (s *SessionTestSuite) TestFailedTurnLeavesNoTrace() {
+	ctx := context.Background()
+	session, err := New(ctx, s.llm, nil)
+	s.Require().NoError(err)
+	s.NotEmpty(session.ID())
+	s.llm.err = errors.New("boom")
+	_, err = session.Send(ctx, "hi")
+	s.EqualError(err, "boom")
+	s.Empty(session.Messages())
+}
+
+func (s *SessionTestSuite) TestUndoAndFork() {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	session, err := New(ctx, s.llm, &Config{ID: "main", Store: store})
+	s.Require().NoError(err)
+	for _, content := range []string{"one", "two"} {
+		_, err := session.Send(ctx, content)
+		s.Require().NoError(err)
+	}
+
+	fork, err := session.Fork(ctx, "branch")
+	s.Require().NoError(err)
+	removed, err := fork.Undo(ctx)
+	s.Require().NoError(err)
+	s.Equal([]*models.Message{user("two"), assistant("re: two")}, removed)
+	_, err = fork.Send(ctx, "three")
+	s.Require().NoError(err)
+
+	s.Equal([]*models.Message{user("one"), assistant("re: one"), user("three"), assistant("re: three")}, fork.Messages())
+	s.Len(session.Messages(), 4, "the original is untouched")
+	stored, err := store.Load(ctx, "branch")
+	s.Require().NoError(err)
+	s.Equal(fork.Messages(), stored)
+
+	_, err = fork.Undo(ctx)
+	s.Require().NoError(err)
+	_, err = fork.Undo(ctx)
+	s.Require().NoError(err)
+	_, err = fork.Undo(ctx)
+	s.ErrorIs(err, ErrNothingToUndo)
+
+	s.Require().NoError(session.Clear(ctx))
+	s.Empty(session.Messages())
+	stored, err = store.Load(ctx, "main")
+	s.Require().NoError(err)
+	s.Empty(stored)
+}
+
+func (s *SessionTestSuite) TestTrimming() {
+	ctx := context.Background()
+	s.llm.models = []*models.Model{{ID: "m", ContextSize: 11}}
+	var reports []*history.Report
+	trimmer := history.New(&history.Config{MessageOverhead: 1, OnTrim: func(r *history.Report) { reports = append(reports, r) }})
+	session, err := New(ctx, s.llm, &Config{Model: "m", Trimmer: trimmer})
+	s.Require().NoError(err)
+	for _, content := range []string{"one", "two", "three"} {
+		_, err := session.Send(ctx, content)
+		s.Require().NoError(err)
+	}
+	// Each turn costs 2 + 3 tokens, so the third request drops the first turn.
+	s.Equal([]*models.Message{user("two"), assistant("re: two"), user("three")}, s.llm.requests[2].Messages)
+	s.Require().Len(reports, 1)
+	s.Len(session.Messages(), 6, "the stored history is never trimmed")
+}
+
+func (s *SessionTestSuite) TestFileStore() {
+	ctx := context.Background()
+	dir := s.T().TempDir()
+	store, err := NewFileStore(dir)
+	s.Require().NoError(err)
+
+	messages := []*models.Message{user("one"), assistant("re: one")}
+	s.Require().NoError(store.Append(ctx, "s1", messages...))
+	s.Require().NoError(store.Append(ctx, "s1", user("two")))
+	loaded, err := store.Load(ctx, "s1")
+	s.Require().NoError(err)
+	s.Equal(append(messages, user("two")), loaded)
+
+	// An interrupted append leaves a torn line that is ignored and replaced.
+	path := filepath.Join(dir, "s1.jsonl")
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	s.Require().NoError(err)
+	fmt.Fprint(f, `{"role":"assistant","cont`)
+	s.Require().NoError(f.Close())
+	loaded, err = store.Load(ctx, "s1")
+	s.Require().NoError(err)
+	s.Len(loaded, 3)
+	s.Require().NoError(store.Append(ctx, "s1", assistant("re: two")))
+	loaded, err = store.Load(ctx, "s1")
+	s.Require().NoError(err)
+	s.Equal(assistant("re: two"), loaded[3])
+
+	s.Require().NoError(store.Save(ctx, "s1", messages[:1]))
+	loaded, err = store.Load(ctx, "s1")
+	s.Require().NoError(err)
+	s.Equal(messages[:1], loaded)
+
+	s.Require().NoError(store.Delete(ctx, "s1"))
+	loaded, err = store.Load(ctx, "s1")
+	s.Require().NoError(err)
+	s.Nil(loaded)
+
+	_, err = store.Load(ctx, "../escape")
+	s.ErrorIs(err, ErrInvalidID)
+}
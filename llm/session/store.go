@@ -0,0 +1,240 @@
+package session
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/aqua777/ai-flow/llm/models"
+)
+
+var ErrInvalidID = errors.New("invalid session id")
+
+// Store persists the message history of sessions by session ID. Loading an
+// unknown ID returns no messages and no error. Implementations must be safe
+// for concurrent use.
+type Store interface {
+	Load(ctx context.Context, id string) ([]*models.Message, error)
+	// Append adds messages to the end of the history.
+	Append(ctx context.Context, id string, messages ...*models.Message) error
+	// Save replaces the whole history.
+	Save(ctx context.Context, id string, messages []*models.Message) error
+	Delete(ctx context.Context, id string) error
+}
+
+// MemoryStore keeps histories in memory.
+type MemoryStore struct {
+	mu       sync.Mutex
+	sessions map[string][]*models.Message
+}
+
+// Ensure MemoryStore implements Store
+var _ Store = (*MemoryStore)(nil)
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{sessions: make(map[string][]*models.Message)}
+}
+
+func (s *MemoryStore) Load(ctx context.Context, id string) ([]*models.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return clone(s.sessions[id]), nil
+}
+
+func (s *MemoryStore) Append(ctx context.Context, id string, messages ...*models.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[id] = append(s.sessions[id], clone(messages)...)
+	return nil
+}
+
+func (s *MemoryStore) Save(ctx context.Context, id string, messages []*models.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[id] = clone(messages)
+	return nil
+}
+
+func (s *MemoryStore) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, id)
+	return nil
+}
+
+// clone copies the messages so callers and the store never share them.
+func clone(messages []*models.Message) []*models.Message {
+	if messages == nil {
+		return nil
+	}
+	result := make([]*models.Message, len(messages))
+	for i, m := range messages {
+		copied := *m
+		result[i] = &copied
+	}
+	return result
+}
+
+// FileStore keeps one JSONL file per session under a directory, one message
+// per line, so appending a turn does not rewrite the file.
+type FileStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// Ensure FileStore implements Store
+var _ Store = (*FileStore)(nil)
+
+// NewFileStore returns a store rooted at dir, creating it if needed.
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+func (s *FileStore) path(id string) (string, error) {
+	if id == "" || id == "." || id == ".." || strings.ContainsAny(id, `/\`) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidID, id)
+	}
+	return filepath.Join(s.dir, id+".jsonl"), nil
+}
+
+func (s *FileStore) Load(ctx context.Context, id string) ([]*models.Message, error) {
+	path, err := s.path(id)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var messages []*models.Message
+	lines := bytes.Split(data, []byte("\n"))
+	for i, line := range lines {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var m models.Message
+		if err := json.Unmarshal(line, &m); err != nil {
+			if i == len(lines)-1 {
+				// A torn final line from an interrupted append.
+				break
+			}
+			return nil, fmt.Errorf("session %s line %d: %w", id, i+1, err)
+		}
+		messages = append(messages, &m)
+	}
+	return messages, nil
+}
+
+func (s *FileStore) Append(ctx context.Context, id string, messages ...*models.Message) error {
+	path, err := s.path(id)
+	if err != nil {
+		return err
+	}
+	data, err := encodeLines(messages)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return err
+	}
+	end, err := dropTornLine(f)
+	if err == nil {
+		_, err = f.WriteAt(data, end)
+	}
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// dropTornLine truncates an unterminated final line left by an interrupted
+// append, which Load ignores, and returns the new end of the file.
+func dropTornLine(f *os.File) (int64, error) {
+	info, err := f.Stat()
+	if err != nil || info.Size() == 0 {
+		return 0, err
+	}
+	last := make([]byte, 1)
+	if _, err := f.ReadAt(last, info.Size()-1); err != nil {
+		return 0, err
+	}
+	if last[0] == '\n' {
+		return info.Size(), nil
+	}
+	data := make([]byte, info.Size())
+	if _, err := f.ReadAt(data, 0); err != nil {
+		return 0, err
+	}
+	end := int64(bytes.LastIndexByte(data, '\n') + 1)
+	return end, f.Truncate(end)
+}
+
+func (s *FileStore) Save(ctx context.Context, id string, messages []*models.Message) error {
+	path, err := s.path(id)
+	if err != nil {
+		return err
+	}
+	data, err := encodeLines(messages)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Write to a temporary file and rename so readers never see a partial history.
+	tmp, err := os.CreateTemp(s.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s *FileStore) Delete(ctx context.Context, id string) error {
+	path, err := s.path(id)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err = os.Remove(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func encodeLines(messages []*models.Message) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, m := range messages {
+		if err := encoder.Encode(m); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,186 @@
+// Package structured asks a model for JSON matching a Go type and decodes
+// the reply into that type.
+package structured
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/aqua777/ai-flow/llm/iface"
+	"github.com/aqua777/ai-flow/llm/models"
+	"github.com/sashabaranov/go-openai/jsonschema"
+)
+
+var ErrValidation = errors.New("reply does not match the schema")
+
+// ValidationError reports a reply that failed to validate, including the
+// raw content so callers can log or inspect it.
+type ValidationError struct {
+	Content string
+	Err     error
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s: %v", ErrValidation, e.Err)
+}
+
+func (e *ValidationError) Unwrap() []error {
+	return []error{ErrValidation, e.Err}
+}
+
+type config struct {
+	name       string
+	maxRetries int
+	strict     bool
+}
+
+type Option func(*config)
+
+// WithName sets the schema name sent to providers that require one.
+func WithName(name string) Option {
+	return func(c *config) {
+		c.name = name
+	}
+}
+
+// WithRetries re-asks the model up to n more times when its reply does not
+// validate, feeding the validation error back to it.
+func WithRetries(n int) Option {
+	return func(c *config) {
+		c.maxRetries = n
+	}
+}
+
+// WithStrict turns on strict schema adherence where the provider supports it.
+// It is off by default because OpenAI's strict mode requires every property
+// to be listed as required, and SchemaFor leaves omitempty fields optional;
+// only enable it for types without omitempty fields.
+func WithStrict(strict bool) Option {
+	return func(c *config) {
+		c.strict = strict
+	}
+}
+
+func newConfig[T any](opts []Option) *config {
+	c := &config{}
+	if t := reflect.TypeOf((*T)(nil)).Elem(); t.Name() != "" {
+		c.name = t.Name()
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// SchemaFor derives a JSON schema from the Go type T.
+func SchemaFor[T any]() (*jsonschema.Definition, error) {
+	var zero T
+	schema, err := jsonschema.GenerateSchemaForType(zero)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive schema: %w", err)
+	}
+	return schema, nil
+}
+
+// Decode validates content against schema and unmarshals it into a T.
+// Markdown code fences around the JSON are tolerated.
+func Decode[T any](schema *jsonschema.Definition, content string) (T, error) {
+	var result T
+	content = stripCodeFence(content)
+	if err := jsonschema.VerifySchemaAndUnmarshal(*schema, []byte(content), &result); err != nil {
+		return result, &ValidationError{Content: content, Err: err}
+	}
+	return result, nil
+}
+
+// Chat sends the request with a JSON-schema response format derived from T
+// and decodes the reply. The request is not modified.
+func Chat[T any](ctx context.Context, llm iface.LLM, r *models.ChatRequest, opts ...Option) (T, *models.ChatResponse, error) {
+	var zero T
+	cfg := newConfig[T](opts)
+	schema, err := SchemaFor[T]()
+	if err != nil {
+		return zero, nil, err
+	}
+
+	req := *r
+	req.ResponseFormat = &models.ResponseFormat{
+		Type:   models.ResponseFormatJSONSchema,
+		Name:   cfg.name,
+		Schema: schema,
+		Strict: cfg.strict,
+	}
+	req.Messages = append([]*models.Message(nil), r.Messages...)
+
+	for attempt := 0; ; attempt++ {
+		resp, err := llm.Chat(ctx, &req)
+		if err != nil {
+			return zero, nil, err
+		}
+		result, err := Decode[T](schema, resp.Content)
+		if err == nil {
+			return result, resp, nil
+		}
+		if attempt >= cfg.maxRetries {
+			return zero, resp, err
+		}
+		req.Messages = append(req.Messages,
+			&models.Message{Role: models.AssistantRole, Content: resp.Content},
+			&models.Message{Role: models.UserRole, Content: reaskPrompt(err)},
+		)
+	}
+}
+
+// Generate is the single-prompt counterpart of Chat. On a re-ask the
+// validation error is appended to the original prompt.
+func Generate[T any](ctx context.Context, llm iface.LLM, r *models.GenerateRequest, opts ...Option) (T, *models.GenerateResponse, error) {
+	var zero T
+	cfg := newConfig[T](opts)
+	schema, err := SchemaFor[T]()
+	if err != nil {
+		return zero, nil, err
+	}
+
+	req := *r
+	req.ResponseFormat = &models.ResponseFormat{
+		Type:   models.ResponseFormatJSONSchema,
+		Name:   cfg.name,
+		Schema: schema,
+		Strict: cfg.strict,
+	}
+
+	for attempt := 0; ; attempt++ {
+		resp, err := llm.Generate(ctx, &req)
+		if err != nil {
+			return zero, nil, err
+		}
+		result, err := Decode[T](schema, resp.Text)
+		if err == nil {
+			return result, resp, nil
+		}
+		if attempt >= cfg.maxRetries {
+			return zero, resp, err
+		}
+		req.Prompt = fmt.Sprintf("%s\n\nYour previous reply was:\n%s\n\n%s", r.Prompt, resp.Text, reaskPrompt(err))
+	}
+}
+
+func reaskPrompt(err error) string {
+	return fmt.Sprintf("Your previous reply could not be used: %v. Reply again with only a JSON value that matches the requested schema.", err)
+}
+
+func stripCodeFence(content string) string {
+	content = strings.TrimSpace(content)
+	if !strings.HasPrefix(content, "```") {
+		return content
+	}
+	content = strings.TrimPrefix(content, "```")
+	if idx := strings.IndexByte(content, '\n'); idx >= 0 {
+		content = content[idx+1:]
+	}
+	content = strings.TrimSuffix(strings.TrimSpace(content), "```")
+	return strings.TrimSpace(content)
+}
@@ -0,0 +1,138 @@
+package structured
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/aqua777/ai-flow/llm/iface"
+	"github.com/aqua777/ai-flow/llm/models"
+)
+
+type City struct {
+	Name       string `json:"name"`
+	Country    string `json:"country"`
+	Population int    `json:"population"`
+}
+
+// scriptedLLM replies with the queued contents in order and records requests.
+type scriptedLLM struct {
+	iface.LLM
+	replies      []string
+	chatRequests []*models.ChatRequest
+	genRequests  []*models.GenerateRequest
+}
+
+func (m *scriptedLLM) next() string {
+	reply := m.replies[0]
+	m.replies = m.replies[1:]
+	return reply
+}
+
+func (m *scriptedLLM) Chat(ctx context.Context, r *models.ChatRequest, stream ...func(chunk []byte) error) (*models.ChatResponse, error) {
+	m.chatRequests = append(m.chatRequests, r)
+	return &models.ChatResponse{Content: m.next()}, nil
+}
+
+func (m *scriptedLLM) Generate(ctx context.Context, r *models.GenerateRequest, stream ...func(chunk []byte) error) (*models.GenerateResponse, error) {
+	req := *r
+	m.genRequests = append(m.genRequests, &req)
+	return &models.GenerateResponse{Text: m.next()}, nil
+}
+
+type StructuredTestSuite struct {
+	suite.Suite
+}
+
+func TestStructuredTestSuite(t *testing.T) {
+	suite.Run(t, new(StructuredTestSuite))
+}
+
+func (s *StructuredTestSuite) TestSchemaFor() {
+	schema, err := SchemaFor[City]()
+	s.Require().NoError(err)
+	data, err := json.Marshal(schema)
+	s.Require().NoError(err)
+	s.JSONEq(`{"type":"object","properties":{"name":{"type":"string"},"country":{"type":"string"},"population":{"type":"integer"}},"required":["name","country","population"],"additionalProperties":false}`, string(data))
+}
+
+func (s *StructuredTestSuite) TestChat() {
+	llm := &scriptedLLM{replies: []string{"```json\n{\"name\":\"Paris\",\"country\":\"France\",\"population\":2100000}\n```"}}
+	req := &models.ChatRequest{Model: "m", Messages: []*models.Message{{Role: models.UserRole, Content: "Largest city in France?"}}}
+
+	city, resp, err := Chat[City](context.Background(), llm, req)
+	s.Require().NoError(err)
+	s.Equal(City{Name: "Paris", Country: "France", Population: 2100000}, city)
+	s.NotNil(resp)
+
+	s.Nil(req.ResponseFormat, "caller request must not be modified")
+	sent := llm.chatRequests[0]
+	s.Equal(models.ResponseFormatJSONSchema, sent.ResponseFormat.Type)
+	s.Equal("City", sent.ResponseFormat.Name)
+	s.False(sent.ResponseFormat.Strict)
+
+	_, _, err = Chat[City](context.Background(), &scriptedLLM{replies: []string{`{"name":"Paris","country":"France","population":1}`}}, req, WithStrict(true))
+	s.NoError(err)
+}
+
+type Landmark struct {
+	Name   string `json:"name"`
+	Height int    `json:"height,omitempty"`
+}
+
+func (s *StructuredTestSuite) TestChat_OptionalField() {
+	schema, err := SchemaFor[Landmark]()
+	s.Require().NoError(err)
+	s.Equal([]string{"name"}, schema.Required)
+
+	llm := &scriptedLLM{replies: []string{`{"name":"Louvre"}`}}
+	req := &models.ChatRequest{Model: "m", Messages: []*models.Message{{Role: models.UserRole, Content: "A landmark in Paris?"}}}
+	landmark, _, err := Chat[Landmark](context.Background(), llm, req)
+	s.Require().NoError(err)
+	s.Equal(Landmark{Name: "Louvre"}, landmark)
+	s.False(llm.chatRequests[0].ResponseFormat.Strict, "strict mode would reject a schema with optional properties")
+}
+
+func (s *StructuredTestSuite) TestChat_ReaskOnValidationFailure() {
+	llm := &scriptedLLM{replies: []string{
+		`{"name":"Paris"}`,
+		`{"name":"Paris","country":"France","population":2100000}`,
+	}}
+	req := &models.ChatRequest{Model: "m", Messages: []*models.Message{{Role: models.UserRole, Content: "Largest city in France?"}}}
+
+	city, _, err := Chat[City](context.Background(), llm, req, WithRetries(1))
+	s.Require().NoError(err)
+	s.Equal("France", city.Country)
+	s.Len(llm.chatRequests, 2)
+	s.Len(req.Messages, 1)
+	reask := llm.chatRequests[1].Messages
+	s.Len(reask, 3)
+	s.Equal(models.AssistantRole, reask[1].Role)
+	s.Equal(`{"name":"Paris"}`, reask[1].Content)
+	s.Contains(reask[2].Content, "schema")
+}
+
+func (s *StructuredTestSuite) TestChat_ValidationError() {
+	llm := &scriptedLLM{replies: []string{`not json`}}
+	_, resp, err := Chat[City](context.Background(), llm, &models.ChatRequest{Model: "m"})
+	s.ErrorIs(err, ErrValidation)
+	var verr *ValidationError
+	s.Require().ErrorAs(err, &verr)
+	s.Equal("not json", verr.Content)
+	s.Equal("not json", resp.Content)
+}
+
+func (s *StructuredTestSuite) TestGenerate_Reask() {
+	llm := &scriptedLLM{replies: []string{
+		`{"name":1}`,
+		`{"name":"Lyon","country":"France","population":520000}`,
+	}}
+	city, _, err := Generate[City](context.Background(), llm, &models.GenerateRequest{Model: "m", Prompt: "A French city"}, WithRetries(2), WithName("city"))
+	s.Require().NoError(err)
+	s.Equal("Lyon", city.Name)
+	s.Equal("city", llm.genRequests[0].ResponseFormat.Name)
+	s.Contains(llm.genRequests[1].Prompt, "A French city")
+	s.Contains(llm.genRequests[1].Prompt, `{"name":1}`)
+}
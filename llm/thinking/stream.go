@@ -0,0 +1,278 @@
+package thinking
+
+import (
+	"strings"
+	"unicode"
+)
+
+const thinkingTagStart = "<think>"
+
+// DefaultHoldLimit is how many bytes of untagged text a StreamParser holds
+// back while waiting for a closing </think> without an opening tag. It is
+// zero so untagged text streams immediately; see StreamParser.HoldLimit.
+const DefaultHoldLimit = 0
+
+// Kind tells reasoning text apart from answer text in a parsed stream.
+type Kind int
+
+const (
+	// Answer is text meant for the user.
+	Answer Kind = iota
+	// Reasoning is the model's thinking.
+	Reasoning
+)
+
+func (k Kind) String() string {
+	if k == Reasoning {
+		return "reasoning"
+	}
+	return "answer"
+}
+
+// Event is a run of text of a single kind emitted by StreamParser.
+type Event struct {
+	Kind Kind
+	Text string
+}
+
+type parserState int
+
+const (
+	// stateStart buffers leading whitespace until the stream shows whether
+	// it opens with a <think> tag.
+	stateStart parserState = iota
+	// stateUndecided holds untagged text back until a tag shows whether it
+	// was reasoning closed by a bare </think> or answer.
+	stateUndecided
+	stateAnswer
+	stateReasoning
+)
+
+// StreamParser splits a streamed completion into reasoning and answer events
+// as chunks arrive. It is the incremental counterpart of ProcessContent: text
+// inside <think>...</think> is reasoning, everything else is answer, and tags
+// split across chunk boundaries are held back until they can be recognised.
+//
+// Models whose chat template opens the thinking block in the prompt emit only
+// the closing tag. Recognising that form is opt-in: ImplicitOpen treats the
+// stream as starting inside a thinking block, and HoldLimit holds untagged
+// text back until <think> or </think> arrives, the stream ends or the limit
+// is reached; past the limit the text is released as answer. Reasoning in a
+// dedicated field (WriteReasoning) shows the model does not use inline tags
+// and releases held text at once.
+//
+// A StreamParser is not safe for concurrent use.
+type StreamParser struct {
+	state     parserState
+	pending   string
+	trimLeft  bool
+	holdLimit int
+
+	content   strings.Builder
+	reasoning strings.Builder
+}
+
+// NewStreamParser returns a parser for a stream that may open with <think>.
+func NewStreamParser() *StreamParser {
+	return &StreamParser{state: stateStart, holdLimit: DefaultHoldLimit}
+}
+
+// HoldLimit sets how many bytes of untagged text are held back waiting for a
+// bare </think>, for streams that may or may not open inside a thinking
+// block. Held text reaches the caller late, so the default of zero streams
+// untagged text as answer immediately.
+func (p *StreamParser) HoldLimit(n int) *StreamParser {
+	p.holdLimit = n
+	return p
+}
+
+// ImplicitOpen makes the parser treat the stream as starting inside a
+// thinking block, for models that emit "reasoning</think>answer". A leading
+// <think> tag is still accepted. If the closing tag never arrives the whole
+// stream is reported as reasoning. It must be called before the first Write.
+func (p *StreamParser) ImplicitOpen() *StreamParser {
+	if p.state == stateStart && p.pending == "" {
+		p.enter(stateReasoning)
+	}
+	return p
+}
+
+// Write feeds the next chunk of content and returns the events it completes.
+func (p *StreamParser) Write(chunk string) []Event {
+	var events []Event
+	text := p.pending + chunk
+	p.pending = ""
+	for text != "" {
+		switch p.state {
+		case stateStart:
+			trimmed := strings.TrimLeftFunc(text, unicode.IsSpace)
+			switch {
+			case strings.HasPrefix(trimmed, thinkingTagStart):
+				text = trimmed[len(thinkingTagStart):]
+				p.enter(stateReasoning)
+			case trimmed == "" || strings.HasPrefix(thinkingTagStart, trimmed):
+				// Whitespace or a partial tag so far; wait for more.
+				p.pending = text
+				return events
+			case p.holdLimit > 0:
+				p.state = stateUndecided
+			default:
+				p.state = stateAnswer
+			}
+		case stateUndecided:
+			open := strings.Index(text, thinkingTagStart)
+			end := strings.Index(text, thinkingTagEnd)
+			switch {
+			case open >= 0 && (end < 0 || open < end):
+				// A regular block; the text before it is answer.
+				p.state = stateAnswer
+			case end >= 0:
+				p.trimLeft = true
+				p.emit(Reasoning, text[:end], &events)
+				p.enter(stateAnswer)
+				text = text[end+len(thinkingTagEnd):]
+			case len(text) > p.holdLimit:
+				p.state = stateAnswer
+			default:
+				p.pending = text
+				return events
+			}
+		case stateAnswer:
+			text = p.scan(text, thinkingTagStart, Answer, stateReasoning, &events)
+		case stateReasoning:
+			if p.trimLeft {
+				// Nothing emitted yet: swallow a redundant opening tag.
+				trimmed := strings.TrimLeftFunc(text, unicode.IsSpace)
+				if strings.HasPrefix(trimmed, thinkingTagStart) {
+					text = trimmed[len(thinkingTagStart):]
+					continue
+				}
+				if trimmed == "" || strings.HasPrefix(thinkingTagStart, trimmed) {
+					p.pending = text
+					return events
+				}
+			}
+			text = p.scan(text, thinkingTagEnd, Reasoning, stateAnswer, &events)
+		}
+	}
+	return events
+}
+
+// WriteReasoning feeds reasoning delivered in a dedicated field, such as
+// Ollama's message.thinking or the OpenAI-compatible reasoning_content, and
+// returns it as a reasoning event.
+func (p *StreamParser) WriteReasoning(chunk string) []Event {
+	if chunk == "" {
+		return nil
+	}
+	p.reasoning.WriteString(chunk)
+	events := []Event{{Kind: Reasoning, Text: chunk}}
+	switch p.state {
+	case stateStart:
+		// A leading <think> may still follow, but a bare </think> will not.
+		p.holdLimit = 0
+	case stateUndecided:
+		text := p.pending
+		p.pending = ""
+		p.state = stateAnswer
+		events = append(events, p.Write(text)...)
+	}
+	return events
+}
+
+// Flush returns any text still held back, such as a partial tag that never
+// completed. Call it once the stream has ended.
+func (p *StreamParser) Flush() []Event {
+	text := p.pending
+	p.pending = ""
+	if text == "" {
+		return nil
+	}
+	kind := Answer
+	if p.state == stateReasoning {
+		kind = Reasoning
+	} else if p.state == stateStart || p.state == stateUndecided {
+		text = strings.TrimLeftFunc(text, unicode.IsSpace)
+	}
+	var events []Event
+	p.emit(kind, text, &events)
+	return events
+}
+
+// Content returns the answer text seen so far.
+func (p *StreamParser) Content() string {
+	return strings.TrimSpace(p.content.String())
+}
+
+// Reasoning returns the reasoning text seen so far.
+func (p *StreamParser) Reasoning() string {
+	return strings.TrimSpace(p.reasoning.String())
+}
+
+// scan emits text of the given kind up to tag, switching to next when the tag
+// is found, and returns the unconsumed remainder. A trailing partial tag is
+// kept pending.
+func (p *StreamParser) scan(text, tag string, kind Kind, next parserState, events *[]Event) string {
+	if i := strings.Index(text, tag); i >= 0 {
+		p.emit(kind, text[:i], events)
+		p.enter(next)
+		return text[i+len(tag):]
+	}
+	keep := partialSuffix(text, tag)
+	p.emit(kind, text[:len(text)-keep], events)
+	p.pending = text[len(text)-keep:]
+	return ""
+}
+
+func (p *StreamParser) enter(state parserState) {
+	p.state = state
+	p.trimLeft = true
+}
+
+func (p *StreamParser) emit(kind Kind, text string, events *[]Event) {
+	if p.trimLeft {
+		text = strings.TrimLeftFunc(text, unicode.IsSpace)
+		if text == "" {
+			return
+		}
+		p.trimLeft = false
+	}
+	if text == "" {
+		return
+	}
+	if kind == Reasoning {
+		p.reasoning.WriteString(text)
+	} else {
+		p.content.WriteString(text)
+	}
+	if n := len(*events); n > 0 && (*events)[n-1].Kind == kind {
+		(*events)[n-1].Text += text
+		return
+	}
+	*events = append(*events, Event{Kind: kind, Text: text})
+}
+
+// partialSuffix returns the length of the longest suffix of text that is a
+// proper prefix of tag.
+func partialSuffix(text, tag string) int {
+	for n := min(len(tag)-1, len(text)); n > 0; n-- {
+		if strings.HasSuffix(text, tag[:n]) {
+			return n
+		}
+	}
+	return 0
+}
+
+// ForwardAnswer passes the answer text of events to callback, dropping
+// reasoning, for stream callbacks that expect only user-facing text.
+func ForwardAnswer(events []Event, callback func(chunk []byte) error) error {
+	for _, event := range events {
+		if event.Kind != Answer {
+			continue
+		}
+		if err := callback([]byte(event.Text)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package thinking
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type StreamParserTestSuite struct {
+	suite.Suite
+}
+
+func TestStreamParserTestSuite(t *testing.T) {
+	suite.Run(t, new(StreamParserTestSuite))
+}
+
+// feed writes chunks one by one and concatenates the events by kind.
+func feed(p *StreamParser, chunks ...string) (answer, reasoning string, events []Event) {
+	for _, chunk := range chunks {
+		events = append(events, p.Write(chunk)...)
+	}
+	events = append(events, p.Flush()...)
+	var a, r strings.Builder
+	for _, e := range events {
+		if e.Kind == Reasoning {
+			r.WriteString(e.Text)
+		} else {
+			a.WriteString(e.Text)
+		}
+	}
+	return a.String(), r.String(), events
+}
+
+func (s *StreamParserTestSuite) TestChunkBoundaries() {
+	tests := []struct {
+		name      string
+		chunks    []string
+		answer    string
+		reasoning string
+	}{
+		{"no tags", []string{"Hello", " world"}, "Hello world", ""},
+		{"whole tags", []string{"<think>Hmm.</think>", "Answer"}, "Answer", "Hmm."},
+		{"split open tag", []string{"<th", "ink>Hmm.</think>Answer"}, "Answer", "Hmm."},
+		{"split close tag", []string{"<think>Hmm.</", "thi", "nk>\n\nAnswer"}, "Answer", "Hmm."},
+		{"one byte at a time", strings.Split("\n<think>\nA b.\n</think>\n\nOK", ""), "OK", "A b.\n"},
+		{"mid-stream block", []string{"Start <think>aside</think> end"}, "Start end", "aside"},
+		{"lone angle bracket", []string{"a < b", " and c"}, "a < b and c", ""},
+		{"unfinished tag at end", []string{"x </thi"}, "x </thi", ""},
+		{"unclosed thinking", []string{"<think>still going"}, "", "still going"},
+	}
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			answer, reasoning, _ := feed(NewStreamParser(), tt.chunks...)
+			s.Equal(tt.answer, answer)
+			s.Equal(tt.reasoning, reasoning)
+		})
+	}
+}
+
+func (s *StreamParserTestSuite) TestImplicitOpen() {
+	p := NewStreamParser().ImplicitOpen()
+	answer, reasoning, events := feed(p, "Let me ", "think.</th", "ink>\n\nDone.")
+	s.Equal("Done.", answer)
+	s.Equal("Let me think.", reasoning)
+	s.Equal([]Event{
+		{Kind: Reasoning, Text: "Let me "},
+		{Kind: Reasoning, Text: "think."},
+		{Kind: Answer, Text: "Done."},
+	}, events)
+	s.Equal("Done.", p.Content())
+	s.Equal("Let me think.", p.Reasoning())
+
+	answer, reasoning, _ = feed(NewStreamParser().ImplicitOpen(), "<think>", "Hmm</think>Yes")
+	s.Equal("Yes", answer)
+	s.Equal("Hmm", reasoning)
+}
+
+func (s *StreamParserTestSuite) TestWriteReasoning() {
+	p := NewStreamParser()
+	s.Equal([]Event{{Kind: Reasoning, Text: "Native."}}, p.WriteReasoning("Native."))
+	s.Nil(p.WriteReasoning(""))
+	s.Equal([]Event{{Kind: Answer, Text: "Hi"}}, p.Write("Hi"))
+	s.Equal("Native.", p.Reasoning())
+	s.Equal("Hi", p.Content())
+}
+
+func (s *StreamParserTestSuite) TestMatchesProcessContent() {
+	for _, content := range []string{
+		"<think>plan</think>\n\nanswer",
+		"plain answer",
+		"<think>a</think>x<think>b</think>y",
+	} {
+		p := NewStreamParser()
+		var streamed strings.Builder
+		for _, r := range content {
+			s.NoError(ForwardAnswer(p.Write(string(r)), func(chunk []byte) error {
+				streamed.Write(chunk)
+				return nil
+			}))
+		}
+		s.NoError(ForwardAnswer(p.Flush(), func(chunk []byte) error {
+			streamed.Write(chunk)
+			return nil
+		}))
+		response, _ := ProcessContent(content)
+		s.Equal(response, p.Content(), content)
+		s.Equal(response, strings.TrimSpace(streamed.String()), content)
+	}
+}
+
+func (s *StreamParserTestSuite) TestNoOpeningTag() {
+	p := NewStreamParser().HoldLimit(64)
+	s.Empty(p.Write("Let me "))
+	s.Empty(p.Write("think.</th"))
+	s.Equal([]Event{{Kind: Reasoning, Text: "Let me think."}, {Kind: Answer, Text: "Done"}}, p.Write("ink>\n\nDone"))
+	s.Equal([]Event{{Kind: Answer, Text: "."}}, p.Write("."))
+	response, reasoning := ProcessContent("Let me think.</think>\n\nDone.")
+	s.Equal(response, p.Content())
+	s.Equal(reasoning, p.Reasoning())
+
+	answer, reasoning, _ := feed(NewStreamParser().HoldLimit(64), "Intro ", "<think>aside</think> end")
+	s.Equal("Intro end", answer, "text before an opening tag is answer")
+	s.Equal("aside", reasoning)
+}
+
+func (s *StreamParserTestSuite) TestHoldLimit() {
+	p := NewStreamParser().HoldLimit(8)
+	s.Empty(p.Write("Hello"))
+	s.Equal([]Event{{Kind: Answer, Text: "Hello world"}}, p.Write(" world"), "text past the limit is released as answer")
+
+	p = NewStreamParser()
+	s.Equal([]Event{{Kind: Answer, Text: "Hello"}}, p.Write("Hello"), "untagged text streams immediately by default")
+
+	p = NewStreamParser().HoldLimit(64)
+	s.Empty(p.Write("Hello"))
+	s.Equal([]Event{{Kind: Reasoning, Text: "Native."}, {Kind: Answer, Text: "Hello"}}, p.WriteReasoning("Native."),
+		"reasoning in a dedicated field releases held text")
+	s.Equal([]Event{{Kind: Answer, Text: " world"}}, p.Write(" world"))
+}
@@ -0,0 +1,299 @@
+package usage
+
+import (
+	"encoding/json"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aqua777/ai-flow/textsplitter"
+)
+
+// Record is the usage of one successful call.
+type Record struct {
+	Time time.Time `json:"time"`
+	// Operation is the iface.LLM method, e.g. "Chat" or "Embeddings".
+	Operation        string `json:"operation"`
+	Model            string `json:"model"`
+	PromptTokens     int    `json:"prompt_tokens"`
+	CompletionTokens int    `json:"completion_tokens"`
+	TotalTokens      int    `json:"total_tokens"`
+	// Estimated is true when the provider did not report usage and the
+	// tokens were counted with the tracker's tokenizer.
+	Estimated bool `json:"estimated,omitempty"`
+	// Cost is in the unit of the price table; zero when the model has no price.
+	Cost   float64           `json:"cost"`
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// Price is the cost of a model per million tokens.
+type Price struct {
+	Prompt     float64 `json:"prompt"`
+	Completion float64 `json:"completion"`
+}
+
+// PriceTable maps model names to prices. A model without an exact entry
+// takes the price of the longest key that prefixes its name, so "gpt-4o"
+// covers "gpt-4o-2024-08-06".
+type PriceTable map[string]Price
+
+// Lookup returns the price of model.
+func (t PriceTable) Lookup(model string) (Price, bool) {
+	if price, ok := t[model]; ok {
+		return price, true
+	}
+	best := ""
+	for key := range t {
+		if strings.HasPrefix(model, key) && len(key) > len(best) {
+			best = key
+		}
+	}
+	if best == "" {
+		return Price{}, false
+	}
+	return t[best], true
+}
+
+// Cost returns the cost of a call to model, or zero when it has no price.
+func (t PriceTable) Cost(model string, promptTokens, completionTokens int) float64 {
+	price, ok := t.Lookup(model)
+	if !ok {
+		return 0
+	}
+	return (float64(promptTokens)*price.Prompt + float64(completionTokens)*price.Completion) / 1e6
+}
+
+// Config controls a Tracker. Zero values take the defaults.
+type Config struct {
+	Prices PriceTable
+	// Tokenizer estimates tokens when the provider does not report them.
+	// Defaults to a TikTokenTokenizer for the call's model, falling back to
+	// the gpt-3.5-turbo encoding and then to SimpleTokenizer when tiktoken
+	// does not know the model or cannot load its encoding. tiktoken may
+	// download the encoding, so it loads in the background and
+	// SimpleTokenizer estimates until it is ready.
+	Tokenizer textsplitter.Tokenizer
+	// MaxRecords caps the records kept in memory, dropping the oldest. Zero
+	// keeps them all.
+	MaxRecords int
+	// OnRecord, if set, is called with every record as it is added.
+	OnRecord func(Record)
+	// Now defaults to time.Now.
+	Now func() time.Time
+}
+
+// Tracker collects usage records. One Tracker can be shared by several
+// decorated LLMs; it is safe for concurrent use.
+type Tracker struct {
+	config Config
+
+	mu         sync.Mutex
+	records    []Record
+	tokenizers map[string]textsplitter.Tokenizer
+	loading    map[string]bool
+	// newTokenizer builds the default tokenizer for a model; tests stub it.
+	newTokenizer func(model string) textsplitter.Tokenizer
+	fallback     textsplitter.Tokenizer
+}
+
+func NewTracker(config *Config) *Tracker {
+	t := &Tracker{
+		tokenizers:   make(map[string]textsplitter.Tokenizer),
+		loading:      make(map[string]bool),
+		newTokenizer: newTikToken,
+		fallback:     textsplitter.NewSimpleTokenizer(),
+	}
+	if config != nil {
+		t.config = *config
+	}
+	if t.config.Now == nil {
+		t.config.Now = time.Now
+	}
+	return t
+}
+
+// Add stamps r with the current time if it has none, prices it and stores it.
+func (t *Tracker) Add(r Record) {
+	if r.Time.IsZero() {
+		r.Time = t.config.Now()
+	}
+	if r.TotalTokens == 0 {
+		r.TotalTokens = r.PromptTokens + r.CompletionTokens
+	}
+	r.Cost = t.config.Prices.Cost(r.Model, r.PromptTokens, r.CompletionTokens)
+	t.mu.Lock()
+	t.records = append(t.records, r)
+	if t.config.MaxRecords > 0 && len(t.records) > t.config.MaxRecords {
+		t.records = slices.Delete(t.records, 0, len(t.records)-t.config.MaxRecords)
+	}
+	t.mu.Unlock()
+	if t.config.OnRecord != nil {
+		t.config.OnRecord(r)
+	}
+}
+
+// Reset discards all records.
+func (t *Tracker) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.records = nil
+}
+
+// tokenizer returns the tokenizer used to estimate tokens for model. The
+// first call for a model starts loading its tokenizer and returns the
+// fallback, so a slow or missing network never delays a call.
+func (t *Tracker) tokenizer(model string) textsplitter.Tokenizer {
+	if t.config.Tokenizer != nil {
+		return t.config.Tokenizer
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if tokenizer, ok := t.tokenizers[model]; ok {
+		return tokenizer
+	}
+	if !t.loading[model] {
+		t.loading[model] = true
+		go t.loadTokenizer(model)
+	}
+	return t.fallback
+}
+
+func (t *Tracker) loadTokenizer(model string) {
+	tokenizer := t.newTokenizer(model)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.tokenizers[model] = tokenizer
+	delete(t.loading, model)
+}
+
+// newTikToken returns a tiktoken tokenizer for model, or for gpt-3.5-turbo
+// when tiktoken does not know it, or SimpleTokenizer when no encoding loads.
+func newTikToken(model string) textsplitter.Tokenizer {
+	if tiktoken, err := textsplitter.NewTikTokenTokenizer(model); err == nil {
+		return tiktoken
+	}
+	if tiktoken, err := textsplitter.NewTikTokenTokenizer(""); err == nil {
+		return tiktoken
+	}
+	return textsplitter.NewSimpleTokenizer()
+}
+
+// Filter selects records. Zero fields match everything; Labels must all be
+// present with equal values.
+type Filter struct {
+	Operation string
+	Model     string
+	Labels    map[string]string
+	// Since is inclusive and Until exclusive.
+	Since time.Time
+	Until time.Time
+}
+
+func (f *Filter) match(r *Record) bool {
+	if f.Operation != "" && r.Operation != f.Operation {
+		return false
+	}
+	if f.Model != "" && r.Model != f.Model {
+		return false
+	}
+	if !f.Since.IsZero() && r.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !r.Time.Before(f.Until) {
+		return false
+	}
+	for key, value := range f.Labels {
+		if v, ok := r.Labels[key]; !ok || v != value {
+			return false
+		}
+	}
+	return true
+}
+
+// Records returns the records matching f, oldest first.
+func (t *Tracker) Records(f Filter) []Record {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var result []Record
+	for i := range t.records {
+		if f.match(&t.records[i]) {
+			result = append(result, t.records[i])
+		}
+	}
+	return result
+}
+
+// Total sums the usage of a group of records.
+type Total struct {
+	// Group holds the values of the keys passed to GroupBy.
+	Group            map[string]string `json:"group,omitempty"`
+	Calls            int               `json:"calls"`
+	PromptTokens     int               `json:"prompt_tokens"`
+	CompletionTokens int               `json:"completion_tokens"`
+	TotalTokens      int               `json:"total_tokens"`
+	Cost             float64           `json:"cost"`
+}
+
+func (t *Total) add(r *Record) {
+	t.Calls++
+	t.PromptTokens += r.PromptTokens
+	t.CompletionTokens += r.CompletionTokens
+	t.TotalTokens += r.TotalTokens
+	t.Cost += r.Cost
+}
+
+// Total sums the records matching f.
+func (t *Tracker) Total(f Filter) Total {
+	var total Total
+	for _, r := range t.Records(f) {
+		total.add(&r)
+	}
+	return total
+}
+
+// GroupBy sums the records matching f per distinct combination of keys.
+// The keys "model" and "operation" group by those fields; any other key is
+// a label name, with records lacking the label grouped under "". Totals are
+// sorted by their group values in key order.
+func (t *Tracker) GroupBy(f Filter, keys ...string) []Total {
+	groups := map[string]*Total{}
+	for _, r := range t.Records(f) {
+		group := make(map[string]string, len(keys))
+		values := make([]string, len(keys))
+		for i, key := range keys {
+			switch key {
+			case "model":
+				values[i] = r.Model
+			case "operation":
+				values[i] = r.Operation
+			default:
+				values[i] = r.Labels[key]
+			}
+			group[key] = values[i]
+		}
+		id := strings.Join(values, "\x00")
+		if groups[id] == nil {
+			groups[id] = &Total{Group: group}
+		}
+		groups[id].add(&r)
+	}
+	result := make([]Total, 0, len(groups))
+	for _, id := range slices.Sorted(maps.Keys(groups)) {
+		result = append(result, *groups[id])
+	}
+	return result
+}
+
+// WriteJSONL writes the records matching f to w, one JSON object per line.
+func (t *Tracker) WriteJSONL(w io.Writer, f Filter) error {
+	encoder := json.NewEncoder(w)
+	for _, r := range t.Records(f) {
+		if err := encoder.Encode(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
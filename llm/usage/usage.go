@@ -0,0 +1,149 @@
+// Package usage provides an iface.LLM decorator that records the tokens and
+// cost of every call, labelled by the caller, for per-service and per-tenant
+// accounting.
+package usage
+
+import (
+	"context"
+	"maps"
+
+	"github.com/aqua777/ai-flow/llm/history"
+	"github.com/aqua777/ai-flow/llm/iface"
+	"github.com/aqua777/ai-flow/llm/models"
+)
+
+type contextKey int
+
+const labelsKey contextKey = iota
+
+// WithLabels returns a context whose calls are recorded with labels, such as
+// {"service": "search", "tenant": "acme"}. They are merged over labels
+// already in ctx.
+func WithLabels(ctx context.Context, labels map[string]string) context.Context {
+	merged := maps.Clone(Labels(ctx))
+	if merged == nil {
+		merged = make(map[string]string, len(labels))
+	}
+	maps.Copy(merged, labels)
+	return context.WithValue(ctx, labelsKey, merged)
+}
+
+// Labels returns the labels set on ctx with WithLabels.
+func Labels(ctx context.Context) map[string]string {
+	labels, _ := ctx.Value(labelsKey).(map[string]string)
+	return labels
+}
+
+// LLM records the usage of successful Generate, Chat, Embeddings and
+// BatchEmbeddings calls of the wrapped LLM in a Tracker. Tokens reported by
+// the provider are used as is; otherwise, as with most streamed calls and
+// all embeddings, they are estimated with the tracker's tokenizer.
+type LLM struct {
+	llm     iface.LLM
+	tracker *Tracker
+}
+
+// Ensure LLM implements iface.LLM
+var (
+	_ iface.LLM         = (*LLM)(nil)
+	_ iface.ModelGetter = (*LLM)(nil)
+)
+
+// New wraps llm, recording into tracker; a nil tracker uses a new one with
+// the defaults.
+func New(llm iface.LLM, tracker *Tracker) *LLM {
+	if tracker == nil {
+		tracker = NewTracker(nil)
+	}
+	return &LLM{llm: llm, tracker: tracker}
+}
+
+func (u *LLM) Tracker() *Tracker {
+	return u.tracker
+}
+
+func (u *LLM) ListModels(ctx context.Context) ([]*models.Model, error) {
+	return u.llm.ListModels(ctx)
+}
+
+func (u *LLM) GetModel(ctx context.Context, name string) (*models.Model, error) {
+	return iface.GetModel(ctx, u.llm, name)
+}
+
+func (u *LLM) Generate(ctx context.Context, r *models.GenerateRequest, stream ...func(chunk []byte) error) (*models.GenerateResponse, error) {
+	resp, err := u.llm.Generate(ctx, r, stream...)
+	if err != nil {
+		return nil, err
+	}
+	record := u.record(ctx, "Generate", r.Model)
+	if resp.PromptTokens > 0 || resp.CompletionTokens > 0 {
+		record.PromptTokens = resp.PromptTokens
+		record.CompletionTokens = resp.CompletionTokens
+		record.TotalTokens = resp.TotalTokens
+	} else {
+		record.Estimated = true
+		record.PromptTokens = u.tokens(r.Model, r.Prompt)
+		record.CompletionTokens = u.tokens(r.Model, resp.Text)
+	}
+	u.tracker.Add(record)
+	return resp, nil
+}
+
+func (u *LLM) Chat(ctx context.Context, r *models.ChatRequest, stream ...func(chunk []byte) error) (*models.ChatResponse, error) {
+	resp, err := u.llm.Chat(ctx, r, stream...)
+	if err != nil {
+		return nil, err
+	}
+	record := u.record(ctx, "Chat", r.Model)
+	if m := resp.Metadata; m != nil && (m.PromptTokens > 0 || m.CompletionTokens > 0) {
+		record.PromptTokens = m.PromptTokens
+		record.CompletionTokens = m.CompletionTokens
+		record.TotalTokens = m.TotalTokens
+	} else {
+		record.Estimated = true
+		tokenizer := u.tracker.tokenizer(r.Model)
+		prompt := &history.Counter{Tokenizer: tokenizer, MessageOverhead: history.DefaultMessageOverhead}
+		completion := &history.Counter{Tokenizer: tokenizer}
+		record.PromptTokens = prompt.CountAll(r.Messages)
+		record.CompletionTokens = completion.Count(resp.Message()) + u.tokens(r.Model, resp.Reasoning)
+	}
+	u.tracker.Add(record)
+	return resp, nil
+}
+
+func (u *LLM) Embeddings(ctx context.Context, r *models.EmbeddingsRequest) (*models.EmbeddingsResponse, error) {
+	resp, err := u.llm.Embeddings(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	record := u.record(ctx, "Embeddings", r.Model)
+	record.Estimated = true
+	record.PromptTokens = u.tokens(r.Model, r.Content)
+	u.tracker.Add(record)
+	return resp, nil
+}
+
+func (u *LLM) BatchEmbeddings(ctx context.Context, r *models.BatchEmbeddingsRequest) (*models.BatchEmbeddingsResponse, error) {
+	resp, err := u.llm.BatchEmbeddings(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	record := u.record(ctx, "BatchEmbeddings", r.Model)
+	record.Estimated = true
+	for _, input := range r.Inputs {
+		record.PromptTokens += u.tokens(r.Model, input)
+	}
+	u.tracker.Add(record)
+	return resp, nil
+}
+
+func (u *LLM) record(ctx context.Context, operation, model string) Record {
+	return Record{Operation: operation, Model: model, Labels: maps.Clone(Labels(ctx))}
+}
+
+func (u *LLM) tokens(model, text string) int {
+	if text == "" {
+		return 0
+	}
+	return len(u.tracker.tokenizer(model).Encode(text))
+}
@@ -0,0 +1,187 @@
+package usage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/aqua777/ai-flow/llm/models"
+	mocks "github.com/aqua777/ai-flow/mocks/llm"
+	"github.com/aqua777/ai-flow/textsplitter"
+)
+
+// fakeLLM reports usage for non-streamed chats only, like many providers.
+type fakeLLM struct {
+	mocks.MockLLM
+	err error
+}
+
+func (f *fakeLLM) Chat(ctx context.Context, r *models.ChatRequest, stream ...func(chunk []byte) error) (*models.ChatResponse, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	resp := &models.ChatResponse{Content: "four word long answer"}
+	if len(stream) > 0 {
+		return resp, stream[0]([]byte(resp.Content))
+	}
+	resp.Metadata = &models.ChatResponseMetadata{PromptTokens: 100, CompletionTokens: 20, TotalTokens: 120}
+	return resp, nil
+}
+
+func (f *fakeLLM) Generate(ctx context.Context, r *models.GenerateRequest, stream ...func(chunk []byte) error) (*models.GenerateResponse, error) {
+	return &models.GenerateResponse{Text: "ok", PromptTokens: 7, CompletionTokens: 1, TotalTokens: 8}, nil
+}
+
+type UsageTestSuite struct {
+	suite.Suite
+	now     time.Time
+	tracker *Tracker
+	llm     *LLM
+	fake    *fakeLLM
+}
+
+func TestUsageTestSuite(t *testing.T) {
+	suite.Run(t, new(UsageTestSuite))
+}
+
+func (s *UsageTestSuite) SetupTest() {
+	s.now = time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	s.tracker = NewTracker(&Config{
+		Prices: PriceTable{
+			"gpt-4o":      {Prompt: 2.5, Completion: 10},
+			"gpt-4o-mini": {Prompt: 0.15, Completion: 0.6},
+		},
+		Tokenizer: textsplitter.NewSimpleTokenizer(),
+		Now:       func() time.Time { return s.now },
+	})
+	s.fake = &fakeLLM{}
+	s.llm = New(s.fake, s.tracker)
+}
+
+func (s *UsageTestSuite) TestRecords() {
+	ctx := WithLabels(context.Background(), map[string]string{"service": "search", "tenant": "acme"})
+	req := &models.ChatRequest{Model: "gpt-4o-2024-08-06", Messages: []*models.Message{{Role: models.UserRole, Content: "hello there"}}}
+
+	_, err := s.llm.Chat(ctx, req)
+	s.Require().NoError(err)
+	_, err = s.llm.Chat(WithLabels(ctx, map[string]string{"tenant": "globex"}), req, func([]byte) error { return nil })
+	s.Require().NoError(err)
+	_, err = s.llm.Generate(context.Background(), &models.GenerateRequest{Model: "llama3", Prompt: "hi"})
+	s.Require().NoError(err)
+	_, err = s.llm.BatchEmbeddings(ctx, &models.BatchEmbeddingsRequest{Model: "gpt-4o-mini", Inputs: []string{"a b", "c"}})
+	s.Require().NoError(err)
+	s.fake.err = errors.New("boom")
+	_, err = s.llm.Chat(ctx, req)
+	s.Error(err)
+
+	records := s.tracker.Records(Filter{})
+	s.Require().Len(records, 4, "failed calls are not recorded")
+	s.Equal(Record{
+		Time:             s.now,
+		Operation:        "Chat",
+		Model:            "gpt-4o-2024-08-06",
+		PromptTokens:     100,
+		CompletionTokens: 20,
+		TotalTokens:      120,
+		Cost:             0.00045,
+		Labels:           map[string]string{"service": "search", "tenant": "acme"},
+	}, records[0])
+
+	// The streamed chat is estimated: 2 words plus 4 overhead, and 4 words.
+	s.True(records[1].Estimated)
+	s.Equal(6, records[1].PromptTokens)
+	s.Equal(4, records[1].CompletionTokens)
+	s.Equal(map[string]string{"service": "search", "tenant": "globex"}, records[1].Labels)
+
+	s.Equal(8, records[2].TotalTokens)
+	s.Zero(records[2].Cost, "no price for llama3")
+	s.Nil(records[2].Labels)
+
+	s.True(records[3].Estimated)
+	s.Equal(3, records[3].PromptTokens)
+	s.InDelta(0.00000045, records[3].Cost, 1e-12)
+}
+
+func (s *UsageTestSuite) TestAggregation() {
+	add := func(model, tenant string, prompt int, at time.Time) {
+		s.tracker.Add(Record{Time: at, Operation: "Chat", Model: model, PromptTokens: prompt, CompletionTokens: prompt, Labels: map[string]string{"tenant": tenant}})
+	}
+	add("gpt-4o", "acme", 1000, s.now)
+	add("gpt-4o", "globex", 2000, s.now.Add(time.Hour))
+	add("gpt-4o-mini", "acme", 1000, s.now.Add(2*time.Hour))
+
+	total := s.tracker.Total(Filter{Labels: map[string]string{"tenant": "acme"}})
+	s.Equal(2, total.Calls)
+	s.Equal(4000, total.TotalTokens)
+	s.InDelta(0.0125+0.00075, total.Cost, 1e-9)
+
+	s.Equal(1, s.tracker.Total(Filter{Since: s.now.Add(time.Hour), Until: s.now.Add(2 * time.Hour)}).Calls)
+	s.Equal(2, s.tracker.Total(Filter{Model: "gpt-4o"}).Calls)
+
+	groups := s.tracker.GroupBy(Filter{}, "tenant", "model")
+	s.Require().Len(groups, 3)
+	s.Equal(map[string]string{"tenant": "acme", "model": "gpt-4o"}, groups[0].Group)
+	s.Equal(map[string]string{"tenant": "acme", "model": "gpt-4o-mini"}, groups[1].Group)
+	s.Equal(map[string]string{"tenant": "globex", "model": "gpt-4o"}, groups[2].Group)
+	s.Equal(4000, groups[2].TotalTokens)
+
+	var buf bytes.Buffer
+	s.Require().NoError(s.tracker.WriteJSONL(&buf, Filter{Labels: map[string]string{"tenant": "globex"}}))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	s.Require().Len(lines, 1)
+	var record Record
+	s.Require().NoError(json.Unmarshal([]byte(lines[0]), &record))
+	s.Equal(2000, record.PromptTokens)
+	s.Equal("globex", record.Labels["tenant"])
+
+	s.tracker.Reset()
+	s.Empty(s.tracker.Records(Filter{}))
+}
+
+// charTokenizer counts one token per byte.
+type charTokenizer struct{}
+
+func (charTokenizer) Encode(text string) []string { return strings.Split(text, "") }
+
+func (s *UsageTestSuite) TestDefaultTokenizer() {
+	tracker := NewTracker(nil)
+	release := make(chan struct{})
+	var loads []string
+	tracker.newTokenizer = func(model string) textsplitter.Tokenizer {
+		<-release
+		loads = append(loads, model)
+		return charTokenizer{}
+	}
+	llm := New(s.fake, tracker)
+	embed := func() int {
+		_, err := llm.Embeddings(context.Background(), &models.EmbeddingsRequest{Model: "gpt-4o", Content: "hello world"})
+		s.Require().NoError(err)
+		records := tracker.Records(Filter{})
+		return records[len(records)-1].PromptTokens
+	}
+
+	s.Equal(2, embed(), "the fallback estimates while the tokenizer loads")
+	s.Equal(2, embed(), "a slow load does not block later calls")
+	close(release)
+	s.Eventually(func() bool { return embed() == 11 }, time.Second, time.Millisecond)
+	s.Equal([]string{"gpt-4o"}, loads, "each model's tokenizer is loaded once")
+}
+
+func (s *UsageTestSuite) TestMaxRecords() {
+	var seen []Record
+	tracker := NewTracker(&Config{MaxRecords: 2, OnRecord: func(r Record) { seen = append(seen, r) }})
+	for i := 1; i <= 3; i++ {
+		tracker.Add(Record{Model: "m", PromptTokens: i})
+	}
+	records := tracker.Records(Filter{})
+	s.Require().Len(records, 2)
+	s.Equal(2, records[0].PromptTokens)
+	s.Len(seen, 3)
+	s.False(records[0].Time.IsZero())
+}
@@ -0,0 +1,213 @@
+// Package fake holds the request capture, scripted replies and failure
+// injection shared by the fake provider servers.
+package fake
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	mocks "github.com/aqua777/ai-flow/mocks/llm"
+)
+
+// Request is a captured HTTP request.
+type Request struct {
+	Method string
+	Path   string
+	Header http.Header
+	Body   []byte
+}
+
+// Decode unmarshals the request body into v.
+func (r *Request) Decode(v any) error {
+	return json.Unmarshal(r.Body, v)
+}
+
+// Failure is an HTTP error returned instead of a reply.
+type Failure struct {
+	Status  int
+	Message string
+	// Header is added to the error response, e.g. Retry-After.
+	Header http.Header
+}
+
+// Script records requests and hands out scripted replies and failures.
+type Script struct {
+	// Default is the reply used once the queue is empty.
+	Default mocks.Response
+	// EmbeddingDimensions sizes the embeddings when the request does not.
+	// Zero means 8.
+	EmbeddingDimensions int
+
+	mu       sync.Mutex
+	queue    []mocks.Response
+	failures map[string][]Failure
+	requests []*Request
+}
+
+// Queue appends replies for the next chat or generate requests.
+func (s *Script) Queue(responses ...mocks.Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queue = append(s.queue, responses...)
+}
+
+// Fail makes the next request to path fail with status and message.
+// Failures for the same path are used in the order they were added.
+func (s *Script) Fail(path string, status int, message string) {
+	s.FailWith(path, Failure{Status: status, Message: message})
+}
+
+// FailWith is Fail with full control over the failure, including headers.
+func (s *Script) FailWith(path string, failure Failure) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failures == nil {
+		s.failures = make(map[string][]Failure)
+	}
+	s.failures[path] = append(s.failures[path], failure)
+}
+
+// Requests returns the captured requests, oldest first.
+func (s *Script) Requests() []*Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*Request(nil), s.requests...)
+}
+
+// LastRequest returns the latest request to path, or nil.
+func (s *Script) LastRequest(path string) *Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := len(s.requests) - 1; i >= 0; i-- {
+		if s.requests[i].Path == path {
+			return s.requests[i]
+		}
+	}
+	return nil
+}
+
+// Reset forgets requests, queued replies and failures.
+func (s *Script) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queue = nil
+	s.failures = nil
+	s.requests = nil
+}
+
+type requestKey struct{}
+
+// Handler records every request and answers it with the failure scripted
+// for its path, written by fail, or else with next. Handlers reach the
+// recorded request with Captured.
+func (s *Script) Handler(next http.Handler, fail func(w http.ResponseWriter, f Failure)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req, failure := s.capture(r)
+		if failure != nil {
+			for key, values := range failure.Header {
+				w.Header()[key] = values
+			}
+			fail(w, *failure)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestKey{}, req)))
+	})
+}
+
+// Captured returns the recorded request behind r.
+func Captured(r *http.Request) *Request {
+	return r.Context().Value(requestKey{}).(*Request)
+}
+
+func (s *Script) capture(r *http.Request) (*Request, *Failure) {
+	body, _ := io.ReadAll(r.Body)
+	req := &Request{Method: r.Method, Path: r.URL.Path, Header: r.Header.Clone(), Body: body}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, req)
+	if queued := s.failures[req.Path]; len(queued) > 0 {
+		s.failures[req.Path] = queued[1:]
+		return req, &queued[0]
+	}
+	return req, nil
+}
+
+// Next returns the next scripted reply.
+func (s *Script) Next() mocks.Response {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.queue) == 0 {
+		return s.Default
+	}
+	r := s.queue[0]
+	s.queue = s.queue[1:]
+	return r
+}
+
+// Embed returns the deterministic embedding of input.
+func (s *Script) Embed(input string, dimensions int) []float32 {
+	if dimensions <= 0 {
+		dimensions = s.EmbeddingDimensions
+	}
+	if dimensions <= 0 {
+		dimensions = 8
+	}
+	return mocks.HashEmbedding(input, dimensions)
+}
+
+// Chunks returns the pieces a reply streams in.
+func Chunks(r mocks.Response) []string {
+	if r.Chunks != nil {
+		return r.Chunks
+	}
+	if r.Content != "" {
+		return []string{r.Content}
+	}
+	return nil
+}
+
+// Content returns the full text of a reply.
+func Content(r mocks.Response) string {
+	if r.Content == "" {
+		return strings.Join(r.Chunks, "")
+	}
+	return r.Content
+}
+
+// Wait sleeps for d unless the client goes away first.
+func Wait(r *http.Request, d time.Duration) bool {
+	if d <= 0 {
+		return r.Context().Err() == nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-r.Context().Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// WriteJSON writes v as a JSON response with status.
+func WriteJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// Inputs reads an embeddings input that is either a string or a list.
+func Inputs(raw json.RawMessage) []string {
+	var one string
+	if err := json.Unmarshal(raw, &one); err == nil {
+		return []string{one}
+	}
+	var many []string
+	json.Unmarshal(raw, &many)
+	return many
+}
@@ -2,30 +2,318 @@ package llm
 
 import (
 	"context"
+	"errors"
+	"hash/fnv"
+	"math"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
 
 	llm_iface "github.com/aqua777/ai-flow/llm/iface"
 	llm_models "github.com/aqua777/ai-flow/llm/models"
 )
 
-type MockLLM struct{}
+// ErrUnexpectedCall is returned by a Strict mock with no response left for a
+// Chat or Generate call.
+var ErrUnexpectedCall = errors.New("mock llm: unexpected call")
+
+// Response scripts one Chat or Generate reply.
+type Response struct {
+	Content   string
+	Reasoning string
+	ToolCalls []*llm_models.ToolCall
+	// Chunks are sent to the stream callback in order. They default to
+	// Content as a single chunk.
+	Chunks       []string
+	Metadata     *llm_models.ChatResponseMetadata
+	FinishReason llm_models.FinishReason
+	// Err fails the call instead.
+	Err error
+	// Latency delays the reply, or each chunk when streaming.
+	Latency time.Duration
+}
+
+// Call is one recorded call: the method name and its request.
+type Call struct {
+	Method  string
+	Request any
+}
+
+// MockLLM is a scriptable iface.LLM for tests. Its zero value answers Chat
+// and Generate with empty responses and every embedding with [1, 0, 0].
+//
+// Chat and Generate take replies from the queue filled by Queue, in order,
+// then from ChatFunc or GenerateFunc. Embeddings are hashed from the
+// content's words when EmbeddingDimensions is set, so texts sharing words
+// get similar vectors. Every call is recorded.
+type MockLLM struct {
+	ChatFunc     func(ctx context.Context, r *llm_models.ChatRequest) (Response, error)
+	GenerateFunc func(ctx context.Context, r *llm_models.GenerateRequest) (Response, error)
+	// Strict makes Chat and Generate fail with ErrUnexpectedCall when no
+	// reply is scripted.
+	Strict bool
+	// Err fails every call.
+	Err error
+	// Latency delays every call.
+	Latency time.Duration
+	// EmbeddingDimensions sizes hashed embeddings; a request's Dimensions
+	// takes precedence. Zero keeps the fixed [1, 0, 0] vector.
+	EmbeddingDimensions int
+	// EmbeddingFunc, if set, computes every embedding instead.
+	EmbeddingFunc func(text string) []float32
+	Models        []*llm_models.Model
+
+	mu    sync.Mutex
+	queue []Response
+	calls []Call
+}
 
 // Ensure MockLLM implements LLM interface
 var _ llm_iface.LLM = (*MockLLM)(nil)
 
+// Queue appends replies for the next Chat or Generate calls.
+func (m *MockLLM) Queue(responses ...Response) *MockLLM {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.queue = append(m.queue, responses...)
+	return m
+}
+
+// Pending returns the number of queued replies not yet used.
+func (m *MockLLM) Pending() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.queue)
+}
+
+// Calls returns the calls made so far, oldest first.
+func (m *MockLLM) Calls() []Call {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Call(nil), m.calls...)
+}
+
+// ChatRequests returns the requests of the Chat calls made so far.
+func (m *MockLLM) ChatRequests() []*llm_models.ChatRequest {
+	var result []*llm_models.ChatRequest
+	for _, call := range m.Calls() {
+		if r, ok := call.Request.(*llm_models.ChatRequest); ok {
+			result = append(result, r)
+		}
+	}
+	return result
+}
+
+// GenerateRequests returns the requests of the Generate calls made so far.
+func (m *MockLLM) GenerateRequests() []*llm_models.GenerateRequest {
+	var result []*llm_models.GenerateRequest
+	for _, call := range m.Calls() {
+		if r, ok := call.Request.(*llm_models.GenerateRequest); ok {
+			result = append(result, r)
+		}
+	}
+	return result
+}
+
+// Reset forgets the recorded calls and the queued replies.
+func (m *MockLLM) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.queue = nil
+	m.calls = nil
+}
+
+// begin records a call and applies the mock-wide latency and error.
+func (m *MockLLM) begin(ctx context.Context, method string, request any) error {
+	m.mu.Lock()
+	m.calls = append(m.calls, Call{Method: method, Request: request})
+	m.mu.Unlock()
+	if err := sleep(ctx, m.Latency); err != nil {
+		return err
+	}
+	return m.Err
+}
+
+func (m *MockLLM) next() (Response, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.queue) == 0 {
+		return Response{}, false
+	}
+	r := m.queue[0]
+	m.queue = m.queue[1:]
+	return r, true
+}
+
+// reply produces the scripted response and streams it.
+func (m *MockLLM) reply(ctx context.Context, fallback func() (Response, error), stream []func(chunk []byte) error) (Response, error) {
+	r, ok := m.next()
+	if !ok {
+		switch {
+		case fallback != nil:
+			var err error
+			if r, err = fallback(); err != nil {
+				return r, err
+			}
+		case m.Strict:
+			return r, ErrUnexpectedCall
+		}
+	}
+	if r.Err != nil {
+		if err := sleep(ctx, r.Latency); err != nil {
+			return r, err
+		}
+		return r, r.Err
+	}
+	if len(stream) == 0 || stream[0] == nil {
+		return r, sleep(ctx, r.Latency)
+	}
+	chunks := r.Chunks
+	if chunks == nil && r.Content != "" {
+		chunks = []string{r.Content}
+	}
+	for _, chunk := range chunks {
+		if err := sleep(ctx, r.Latency); err != nil {
+			return r, err
+		}
+		if err := stream[0]([]byte(chunk)); err != nil {
+			return r, err
+		}
+	}
+	if r.Content == "" && r.Chunks != nil {
+		r.Content = strings.Join(r.Chunks, "")
+	}
+	return r, nil
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
 func (m *MockLLM) ListModels(ctx context.Context) ([]*llm_models.Model, error) {
-	return nil, nil
+	if err := m.begin(ctx, "ListModels", nil); err != nil {
+		return nil, err
+	}
+	return m.Models, nil
 }
 
-func (m *MockLLM) Generate(ctx context.Context, r *llm_models.GenerateRequest) (*llm_models.GenerateResponse, error) {
-	return nil, nil
+func (m *MockLLM) Generate(ctx context.Context, r *llm_models.GenerateRequest, stream ...func(chunk []byte) error) (*llm_models.GenerateResponse, error) {
+	if err := m.begin(ctx, "Generate", r); err != nil {
+		return nil, err
+	}
+	var fallback func() (Response, error)
+	if m.GenerateFunc != nil {
+		fallback = func() (Response, error) { return m.GenerateFunc(ctx, r) }
+	}
+	resp, err := m.reply(ctx, fallback, stream)
+	if err != nil {
+		return nil, err
+	}
+	result := &llm_models.GenerateResponse{Text: resp.Content, Model: r.Model, FinishReason: resp.FinishReason}
+	if resp.Metadata != nil {
+		result.PromptTokens = resp.Metadata.PromptTokens
+		result.CompletionTokens = resp.Metadata.CompletionTokens
+		result.TotalTokens = resp.Metadata.TotalTokens
+	}
+	return result, nil
 }
 
 func (m *MockLLM) Chat(ctx context.Context, r *llm_models.ChatRequest, stream ...func(chunk []byte) error) (*llm_models.ChatResponse, error) {
-	return nil, nil
+	if err := m.begin(ctx, "Chat", r); err != nil {
+		return nil, err
+	}
+	var fallback func() (Response, error)
+	if m.ChatFunc != nil {
+		fallback = func() (Response, error) { return m.ChatFunc(ctx, r) }
+	}
+	resp, err := m.reply(ctx, fallback, stream)
+	if err != nil {
+		return nil, err
+	}
+	return &llm_models.ChatResponse{
+		Content:      resp.Content,
+		Reasoning:    resp.Reasoning,
+		ToolCalls:    resp.ToolCalls,
+		Metadata:     resp.Metadata,
+		FinishReason: resp.FinishReason,
+	}, nil
 }
 
 func (m *MockLLM) Embeddings(ctx context.Context, cr *llm_models.EmbeddingsRequest) (*llm_models.EmbeddingsResponse, error) {
+	if err := m.begin(ctx, "Embeddings", cr); err != nil {
+		return nil, err
+	}
 	return &llm_models.EmbeddingsResponse{
-		Embeddings: []float32{1.0, 0.0, 0.0},
+		Embeddings: m.embed(cr.Content, cr.Dimensions),
+	}, nil
+}
+
+func (m *MockLLM) BatchEmbeddings(ctx context.Context, r *llm_models.BatchEmbeddingsRequest) (*llm_models.BatchEmbeddingsResponse, error) {
+	if err := m.begin(ctx, "BatchEmbeddings", r); err != nil {
+		return nil, err
+	}
+	embeddings := make([][]float32, len(r.Inputs))
+	for i, input := range r.Inputs {
+		embeddings[i] = m.embed(input, r.Dimensions)
+	}
+	return &llm_models.BatchEmbeddingsResponse{
+		Embeddings: embeddings,
 	}, nil
 }
+
+func (m *MockLLM) embed(text string, dimensions int) []float32 {
+	if m.EmbeddingFunc != nil {
+		return m.EmbeddingFunc(text)
+	}
+	if dimensions <= 0 {
+		dimensions = m.EmbeddingDimensions
+	}
+	if dimensions <= 0 {
+		return []float32{1.0, 0.0, 0.0}
+	}
+	return HashEmbedding(text, dimensions)
+}
+
+// HashEmbedding returns a deterministic unit vector for text. Each
+// lower-cased word adds a signed weight to a position chosen by its hash,
+// so texts sharing words have a high cosine similarity and unrelated texts
+// a low one. Text without words maps to the zero vector.
+func HashEmbedding(text string, dimensions int) []float32 {
+	vector := make([]float32, dimensions)
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	for _, word := range words {
+		h := fnv.New64a()
+		h.Write([]byte(word))
+		sum := h.Sum64()
+		sign := float32(1)
+		if sum>>63 == 1 {
+			sign = -1
+		}
+		vector[sum%uint64(dimensions)] += sign
+	}
+	var norm float64
+	for _, v := range vector {
+		norm += float64(v) * float64(v)
+	}
+	if norm == 0 {
+		return vector
+	}
+	scale := float32(1 / math.Sqrt(norm))
+	for i := range vector {
+		vector[i] *= scale
+	}
+	return vector
+}
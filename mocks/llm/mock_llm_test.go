@@ -0,0 +1,117 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	llm_models "github.com/aqua777/ai-flow/llm/models"
+)
+
+type MockLLMTestSuite struct {
+	suite.Suite
+}
+
+func TestMockLLMTestSuite(t *testing.T) {
+	suite.Run(t, new(MockLLMTestSuite))
+}
+
+func (s *MockLLMTestSuite) TestZeroValue() {
+	m := &MockLLM{}
+	resp, err := m.Chat(context.Background(), &llm_models.ChatRequest{})
+	s.Require().NoError(err)
+	s.Empty(resp.Content)
+	emb, err := m.Embeddings(context.Background(), &llm_models.EmbeddingsRequest{Content: "Hello"})
+	s.Require().NoError(err)
+	s.Equal([]float32{1, 0, 0}, emb.Embeddings)
+}
+
+func (s *MockLLMTestSuite) TestQueueAndStream() {
+	ctx := context.Background()
+	m := (&MockLLM{Strict: true}).Queue(
+		Response{Chunks: []string{"Hel", "lo"}, Metadata: &llm_models.ChatResponseMetadata{PromptTokens: 3}},
+		Response{Err: errors.New("overloaded")},
+		Response{Content: "generated"},
+	)
+
+	var chunks []string
+	resp, err := m.Chat(ctx, &llm_models.ChatRequest{Model: "a"}, func(chunk []byte) error {
+		chunks = append(chunks, string(chunk))
+		return nil
+	})
+	s.Require().NoError(err)
+	s.Equal([]string{"Hel", "lo"}, chunks)
+	s.Equal("Hello", resp.Content)
+	s.Equal(3, resp.Metadata.PromptTokens)
+
+	_, err = m.Chat(ctx, &llm_models.ChatRequest{Model: "b"})
+	s.EqualError(err, "overloaded")
+
+	gen, err := m.Generate(ctx, &llm_models.GenerateRequest{Model: "c"})
+	s.Require().NoError(err)
+	s.Equal("generated", gen.Text)
+	s.Zero(m.Pending())
+
+	_, err = m.Chat(ctx, &llm_models.ChatRequest{})
+	s.ErrorIs(err, ErrUnexpectedCall)
+
+	requests := m.ChatRequests()
+	s.Require().Len(requests, 3)
+	s.Equal("b", requests[1].Model)
+	s.Equal("c", m.GenerateRequests()[0].Model)
+	s.Len(m.Calls(), 4)
+	m.Reset()
+	s.Empty(m.Calls())
+}
+
+func (s *MockLLMTestSuite) TestFuncErrorsAndLatency() {
+	m := &MockLLM{ChatFunc: func(ctx context.Context, r *llm_models.ChatRequest) (Response, error) {
+		return Response{Content: "echo: " + r.Messages[0].Content}, nil
+	}}
+	resp, err := m.Chat(context.Background(), &llm_models.ChatRequest{Messages: []*llm_models.Message{{Content: "hi"}}})
+	s.Require().NoError(err)
+	s.Equal("echo: hi", resp.Content)
+
+	m.Err = errors.New("down")
+	_, err = m.Embeddings(context.Background(), &llm_models.EmbeddingsRequest{})
+	s.EqualError(err, "down")
+
+	m = &MockLLM{Latency: time.Hour}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = m.Chat(ctx, &llm_models.ChatRequest{})
+	s.ErrorIs(err, context.DeadlineExceeded)
+}
+
+func (s *MockLLMTestSuite) TestHashEmbedding() {
+	m := &MockLLM{EmbeddingDimensions: 64}
+	resp, err := m.BatchEmbeddings(context.Background(), &llm_models.BatchEmbeddingsRequest{Inputs: []string{
+		"The capital of France is Paris.",
+		"the capital of france is PARIS",
+		"What is the capital of France?",
+		"Bananas grow in tropical climates.",
+	}})
+	s.Require().NoError(err)
+	e := resp.Embeddings
+	s.Len(e[0], 64)
+	s.Equal(e[0], e[1], "case and punctuation are ignored")
+	s.InDelta(1.0, dot(e[0], e[0]), 1e-5)
+	s.Greater(dot(e[0], e[2]), dot(e[0], e[3]))
+	s.Equal(HashEmbedding("x", 8), HashEmbedding("x", 8))
+
+	one, err := m.Embeddings(context.Background(), &llm_models.EmbeddingsRequest{Content: "x", Dimensions: 8})
+	s.Require().NoError(err)
+	s.Len(one.Embeddings, 8)
+	s.Equal(make([]float32, 4), HashEmbedding("?!", 4))
+}
+
+func dot(a, b []float32) float64 {
+	var sum float64
+	for i := range a {
+		sum += float64(a[i]) * float64(b[i])
+	}
+	return sum
+}
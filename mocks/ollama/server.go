@@ -0,0 +1,227 @@
+// Package ollama is a fake Ollama server for hermetic tests of clients that
+// speak the Ollama HTTP API.
+package ollama
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/aqua777/ai-flow/mocks/internal/fake"
+	mocks "github.com/aqua777/ai-flow/mocks/llm"
+)
+
+type (
+	// Request is a captured HTTP request.
+	Request = fake.Request
+	// Failure is an HTTP error scripted with FailWith.
+	Failure = fake.Failure
+)
+
+// Server serves /api/tags, /api/show, /api/chat, /api/generate and
+// /api/embed. Chat and generate requests take replies from the queue filled
+// by Queue, falling back to Default, and stream them as NDJSON when the
+// request asks to. A reply's Chunks are streamed in order, Latency is waited
+// before each, and Err fails the request: with HTTP 500 before any chunk,
+// or as an error line after its chunks. Embeddings are hashed from the
+// input, as mocks.HashEmbedding does.
+type Server struct {
+	*httptest.Server
+	fake.Script
+
+	// Models are listed by /api/tags.
+	Models []string
+	// Capabilities are reported by /api/show for every model.
+	Capabilities []string
+}
+
+// NewServer starts a fake server. Close it when done.
+func NewServer() *Server {
+	s := &Server{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/tags", s.handleTags)
+	mux.HandleFunc("POST /api/show", s.handleShow)
+	mux.HandleFunc("POST /api/chat", s.handleChat)
+	mux.HandleFunc("POST /api/generate", s.handleGenerate)
+	mux.HandleFunc("POST /api/embed", s.handleEmbed)
+	s.Server = httptest.NewServer(s.Handler(mux, func(w http.ResponseWriter, f fake.Failure) {
+		writeError(w, f.Status, f.Message)
+	}))
+	return s
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	fake.WriteJSON(w, status, map[string]string{"error": message})
+}
+
+func (s *Server) handleTags(w http.ResponseWriter, r *http.Request) {
+	type tag struct {
+		Name       string    `json:"name"`
+		Model      string    `json:"model"`
+		ModifiedAt time.Time `json:"modified_at"`
+	}
+	tags := []tag{}
+	for _, name := range s.Models {
+		tags = append(tags, tag{Name: name, Model: name})
+	}
+	fake.WriteJSON(w, http.StatusOK, map[string]any{"models": tags})
+}
+
+func (s *Server) handleShow(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Model string `json:"model"`
+	}
+	if err := fake.Captured(r).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	for _, name := range s.Models {
+		if name == req.Model {
+			fake.WriteJSON(w, http.StatusOK, map[string]any{"model": name, "capabilities": s.Capabilities})
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "model '"+req.Model+"' not found")
+}
+
+type message struct {
+	Role      string     `json:"role"`
+	Content   string     `json:"content"`
+	Thinking  string     `json:"thinking,omitempty"`
+	ToolCalls []toolCall `json:"tool_calls,omitempty"`
+}
+
+type toolCall struct {
+	Function struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	} `json:"function"`
+}
+
+type chunk struct {
+	Model           string    `json:"model"`
+	CreatedAt       time.Time `json:"created_at"`
+	Message         *message  `json:"message,omitempty"`
+	Response        *string   `json:"response,omitempty"`
+	Thinking        string    `json:"thinking,omitempty"`
+	Done            bool      `json:"done"`
+	DoneReason      string    `json:"done_reason,omitempty"`
+	PromptEvalCount int       `json:"prompt_eval_count,omitempty"`
+	EvalCount       int       `json:"eval_count,omitempty"`
+}
+
+type completionRequest struct {
+	Model  string `json:"model"`
+	Stream *bool  `json:"stream"`
+}
+
+func (s *Server) handleChat(w http.ResponseWriter, r *http.Request) {
+	s.complete(w, r, true)
+}
+
+func (s *Server) handleGenerate(w http.ResponseWriter, r *http.Request) {
+	s.complete(w, r, false)
+}
+
+// complete answers /api/chat, or /api/generate when chat is false.
+func (s *Server) complete(w http.ResponseWriter, r *http.Request, chat bool) {
+	var req completionRequest
+	if err := fake.Captured(r).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	reply := s.Next()
+	// Ollama streams unless told not to.
+	stream := req.Stream == nil || *req.Stream
+	if reply.Err != nil && (!stream || len(fake.Chunks(reply)) == 0) {
+		if fake.Wait(r, reply.Latency) {
+			writeError(w, http.StatusInternalServerError, reply.Err.Error())
+		}
+		return
+	}
+
+	piece := func(content string) chunk {
+		c := chunk{Model: req.Model, CreatedAt: time.Now()}
+		if chat {
+			c.Message = &message{Role: "assistant", Content: content}
+		} else {
+			c.Response = &content
+		}
+		return c
+	}
+	final := piece("")
+	if !stream {
+		final = piece(fake.Content(reply))
+	}
+	final.Done = true
+	final.DoneReason = string(reply.FinishReason)
+	if final.DoneReason == "" || final.DoneReason == "tool_calls" {
+		final.DoneReason = "stop"
+	}
+	if reply.Metadata != nil {
+		final.PromptEvalCount = reply.Metadata.PromptTokens
+		final.EvalCount = reply.Metadata.CompletionTokens
+	}
+	if chat {
+		final.Message.Thinking = reply.Reasoning
+		final.Message.ToolCalls = toToolCalls(reply)
+	} else {
+		final.Thinking = reply.Reasoning
+	}
+
+	if !stream {
+		if fake.Wait(r, reply.Latency) {
+			fake.WriteJSON(w, http.StatusOK, final)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	encoder := json.NewEncoder(w)
+	flusher, _ := w.(http.Flusher)
+	for _, content := range fake.Chunks(reply) {
+		if !fake.Wait(r, reply.Latency) {
+			return
+		}
+		encoder.Encode(piece(content))
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	if reply.Err != nil {
+		encoder.Encode(map[string]string{"error": reply.Err.Error()})
+		return
+	}
+	encoder.Encode(final)
+}
+
+func toToolCalls(reply mocks.Response) []toolCall {
+	var calls []toolCall
+	for _, call := range reply.ToolCalls {
+		var c toolCall
+		c.Function.Name = call.Name
+		c.Function.Arguments = json.RawMessage(call.Arguments)
+		if len(c.Function.Arguments) == 0 {
+			c.Function.Arguments = json.RawMessage("{}")
+		}
+		calls = append(calls, c)
+	}
+	return calls
+}
+
+func (s *Server) handleEmbed(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Model      string          `json:"model"`
+		Input      json.RawMessage `json:"input"`
+		Dimensions int             `json:"dimensions"`
+	}
+	if err := fake.Captured(r).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	embeddings := [][]float32{}
+	for _, input := range fake.Inputs(req.Input) {
+		embeddings = append(embeddings, s.Embed(input, req.Dimensions))
+	}
+	fake.WriteJSON(w, http.StatusOK, map[string]any{"model": req.Model, "embeddings": embeddings})
+}
@@ -0,0 +1,133 @@
+package ollama
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/aqua777/ai-flow/llm/models"
+	client "github.com/aqua777/ai-flow/llm/ollama"
+	mocks "github.com/aqua777/ai-flow/mocks/llm"
+)
+
+type ServerTestSuite struct {
+	suite.Suite
+	server *Server
+	client *client.Client
+}
+
+func TestServerTestSuite(t *testing.T) {
+	suite.Run(t, new(ServerTestSuite))
+}
+
+func (s *ServerTestSuite) SetupTest() {
+	s.server = NewServer()
+	c, err := client.NewClient(&models.LLMConfig{Url: s.server.URL})
+	s.Require().NoError(err)
+	s.client = c
+}
+
+func (s *ServerTestSuite) TearDownTest() {
+	s.server.Close()
+}
+
+func (s *ServerTestSuite) TestListModels() {
+	s.server.Models = []string{"llama3.1", "nomic-embed-text"}
+	s.server.Capabilities = []string{"completion", "tools"}
+	list, err := s.client.ListModels(context.Background())
+	s.Require().NoError(err)
+	s.Require().Len(list, 2)
+	s.Equal("llama3.1", list[0].ID)
+	s.True(list[0].HasCapability(models.CapabilityTools))
+}
+
+func (s *ServerTestSuite) TestChat() {
+	ctx := context.Background()
+	s.server.Queue(
+		mocks.Response{Content: "Hello!", Metadata: &models.ChatResponseMetadata{PromptTokens: 5, CompletionTokens: 2}},
+		mocks.Response{Chunks: []string{"Hel", "lo", "!"}},
+		mocks.Response{ToolCalls: []*models.ToolCall{{Name: "get_weather", Arguments: `{"city":"Paris"}`}}},
+	)
+	req := &models.ChatRequest{Model: "llama3.1", Messages: []*models.Message{{Role: models.UserRole, Content: "Hi"}}}
+
+	resp, err := s.client.Chat(ctx, req)
+	s.Require().NoError(err)
+	s.Equal("Hello!", resp.Content)
+	s.Equal(7, resp.Metadata.TotalTokens)
+
+	var chunks []string
+	resp, err = s.client.Chat(ctx, req, func(chunk []byte) error {
+		chunks = append(chunks, string(chunk))
+		return nil
+	})
+	s.Require().NoError(err)
+	s.Equal([]string{"Hel", "lo", "!"}, chunks)
+	s.Equal("Hello!", resp.Content)
+
+	resp, err = s.client.Chat(ctx, req)
+	s.Require().NoError(err)
+	s.Require().Len(resp.ToolCalls, 1)
+	s.JSONEq(`{"city":"Paris"}`, resp.ToolCalls[0].Arguments)
+
+	var body struct {
+		Model    string `json:"model"`
+		Messages []struct {
+			Content string `json:"content"`
+		} `json:"messages"`
+	}
+	s.Require().NoError(s.server.LastRequest("/api/chat").Decode(&body))
+	s.Equal("llama3.1", body.Model)
+	s.Equal("Hi", body.Messages[0].Content)
+	s.Len(s.server.Requests(), 3)
+}
+
+func (s *ServerTestSuite) TestGenerateAndEmbed() {
+	ctx := context.Background()
+	s.server.Default = mocks.Response{Content: "42"}
+	resp, err := s.client.Generate(ctx, &models.GenerateRequest{Model: "llama3.1", Prompt: "answer?"})
+	s.Require().NoError(err)
+	s.Equal("42", resp.Text)
+
+	var chunks []string
+	_, err = s.client.Generate(ctx, &models.GenerateRequest{Model: "llama3.1", Prompt: "answer?"}, func(chunk []byte) error {
+		chunks = append(chunks, string(chunk))
+		return nil
+	})
+	s.Require().NoError(err)
+	s.Equal([]string{"42"}, chunks)
+
+	s.server.EmbeddingDimensions = 16
+	one, err := s.client.Embeddings(ctx, &models.EmbeddingsRequest{Model: "nomic-embed-text", Content: "Paris"})
+	s.Require().NoError(err)
+	s.Equal(mocks.HashEmbedding("Paris", 16), one.Embeddings)
+	batch, err := s.client.BatchEmbeddings(ctx, &models.BatchEmbeddingsRequest{Model: "nomic-embed-text", Inputs: []string{"a", "b", "c"}})
+	s.Require().NoError(err)
+	s.Len(batch.Embeddings, 3)
+}
+
+func (s *ServerTestSuite) TestErrors() {
+	ctx := context.Background()
+	req := &models.ChatRequest{Model: "llama3.1", Messages: []*models.Message{{Role: models.UserRole, Content: "Hi"}}}
+
+	s.server.Fail("/api/chat", 429, "slow down")
+	_, err := s.client.Chat(ctx, req)
+	s.ErrorIs(err, models.ErrRateLimited)
+
+	s.server.Queue(mocks.Response{Err: errors.New("model crashed")})
+	_, err = s.client.Chat(ctx, req)
+	s.ErrorIs(err, models.ErrServerError)
+
+	s.server.Queue(mocks.Response{Chunks: []string{"partial"}, Err: errors.New("out of memory")})
+	var chunks []string
+	_, err = s.client.Chat(ctx, req, func(chunk []byte) error {
+		chunks = append(chunks, string(chunk))
+		return nil
+	})
+	s.ErrorContains(err, "out of memory")
+	s.Equal([]string{"partial"}, chunks)
+
+	_, err = s.client.Chat(ctx, req)
+	s.NoError(err, "failures are used once")
+}
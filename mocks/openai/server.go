@@ -0,0 +1,239 @@
+// Package openai is a fake OpenAI server for hermetic tests of clients that
+// speak the OpenAI HTTP API. Point a client at URL + "/v1".
+package openai
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+
+	"github.com/aqua777/ai-flow/mocks/internal/fake"
+	mocks "github.com/aqua777/ai-flow/mocks/llm"
+)
+
+type (
+	// Request is a captured HTTP request.
+	Request = fake.Request
+	// Failure is an HTTP error scripted with FailWith.
+	Failure = fake.Failure
+)
+
+// Server serves /v1/models, /v1/models/{id}, /v1/chat/completions and
+// /v1/embeddings. Chat completions take replies from the queue filled by
+// Queue, falling back to Default, and stream them as server-sent events
+// when the request asks to. A reply's Chunks are streamed in order, Latency
+// is waited before each, and Err fails the request: with HTTP 500 before any
+// chunk, or as an error event after its chunks. Embeddings are hashed from
+// the input, as mocks.HashEmbedding does.
+type Server struct {
+	*httptest.Server
+	fake.Script
+
+	// Models are listed by /v1/models.
+	Models []string
+	// APIKey, if set, is required as the bearer token of every request.
+	APIKey string
+}
+
+// NewServer starts a fake server. Close it when done.
+func NewServer() *Server {
+	s := &Server{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/models", s.handleModels)
+	mux.HandleFunc("GET /v1/models/{id}", s.handleModel)
+	mux.HandleFunc("POST /v1/chat/completions", s.handleChat)
+	mux.HandleFunc("POST /v1/embeddings", s.handleEmbeddings)
+	handler := s.Handler(mux, func(w http.ResponseWriter, f fake.Failure) {
+		writeError(w, f.Status, f.Message)
+	})
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.APIKey != "" && r.Header.Get("Authorization") != "Bearer "+s.APIKey {
+			writeError(w, http.StatusUnauthorized, "Incorrect API key provided")
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	return s
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	apiErr := &openai.APIError{Message: message, Type: "invalid_request_error"}
+	// The codes OpenAI sends with each status.
+	switch {
+	case status == http.StatusUnauthorized:
+		apiErr.Code = "invalid_api_key"
+	case status == http.StatusNotFound:
+		apiErr.Code = "model_not_found"
+	case status == http.StatusTooManyRequests:
+		apiErr.Type = "requests"
+		apiErr.Code = "rate_limit_exceeded"
+	case status >= 500:
+		apiErr.Type = "server_error"
+	}
+	fake.WriteJSON(w, status, openai.ErrorResponse{Error: apiErr})
+}
+
+func model(id string) openai.Model {
+	return openai.Model{ID: id, Object: "model", OwnedBy: "fake", CreatedAt: time.Now().Unix()}
+}
+
+func (s *Server) handleModels(w http.ResponseWriter, r *http.Request) {
+	list := struct {
+		Object string         `json:"object"`
+		Data   []openai.Model `json:"data"`
+	}{Object: "list", Data: []openai.Model{}}
+	for _, id := range s.Models {
+		list.Data = append(list.Data, model(id))
+	}
+	fake.WriteJSON(w, http.StatusOK, list)
+}
+
+func (s *Server) handleModel(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	for _, name := range s.Models {
+		if name == id {
+			fake.WriteJSON(w, http.StatusOK, model(id))
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, fmt.Sprintf("The model '%s' does not exist", id))
+}
+
+func (s *Server) handleChat(w http.ResponseWriter, r *http.Request) {
+	var req openai.ChatCompletionRequest
+	if err := fake.Captured(r).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	reply := s.Next()
+	if reply.Err != nil && (!req.Stream || len(fake.Chunks(reply)) == 0) {
+		if fake.Wait(r, reply.Latency) {
+			writeError(w, http.StatusInternalServerError, reply.Err.Error())
+		}
+		return
+	}
+
+	id := fmt.Sprintf("chatcmpl-%d", time.Now().UnixNano())
+	finishReason := openai.FinishReason(reply.FinishReason)
+	if finishReason == "" {
+		finishReason = openai.FinishReasonStop
+		if len(reply.ToolCalls) > 0 {
+			finishReason = openai.FinishReasonToolCalls
+		}
+	}
+	var usage openai.Usage
+	if reply.Metadata != nil {
+		usage = openai.Usage{
+			PromptTokens:     reply.Metadata.PromptTokens,
+			CompletionTokens: reply.Metadata.CompletionTokens,
+			TotalTokens:      reply.Metadata.TotalTokens,
+		}
+	}
+
+	if !req.Stream {
+		if !fake.Wait(r, reply.Latency) {
+			return
+		}
+		fake.WriteJSON(w, http.StatusOK, openai.ChatCompletionResponse{
+			ID:      id,
+			Object:  "chat.completion",
+			Created: time.Now().Unix(),
+			Model:   req.Model,
+			Choices: []openai.ChatCompletionChoice{{
+				Message: openai.ChatCompletionMessage{
+					Role:             openai.ChatMessageRoleAssistant,
+					Content:          fake.Content(reply),
+					ReasoningContent: reply.Reasoning,
+					ToolCalls:        toToolCalls(reply, false),
+				},
+				FinishReason: finishReason,
+			}},
+			Usage: usage,
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	flusher, _ := w.(http.Flusher)
+	send := func(v any) {
+		data, _ := json.Marshal(v)
+		fmt.Fprintf(w, "data: %s\n\n", data)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	event := func(delta openai.ChatCompletionStreamChoiceDelta, finish openai.FinishReason) openai.ChatCompletionStreamResponse {
+		return openai.ChatCompletionStreamResponse{
+			ID:      id,
+			Object:  "chat.completion.chunk",
+			Created: time.Now().Unix(),
+			Model:   req.Model,
+			Choices: []openai.ChatCompletionStreamChoice{{Delta: delta, FinishReason: finish}},
+		}
+	}
+	if reply.Reasoning != "" {
+		send(event(openai.ChatCompletionStreamChoiceDelta{Role: openai.ChatMessageRoleAssistant, ReasoningContent: reply.Reasoning}, ""))
+	}
+	for _, content := range fake.Chunks(reply) {
+		if !fake.Wait(r, reply.Latency) {
+			return
+		}
+		send(event(openai.ChatCompletionStreamChoiceDelta{Role: openai.ChatMessageRoleAssistant, Content: content}, ""))
+	}
+	if reply.Err != nil {
+		send(openai.ErrorResponse{Error: &openai.APIError{Message: reply.Err.Error(), Type: "server_error"}})
+		return
+	}
+	send(event(openai.ChatCompletionStreamChoiceDelta{ToolCalls: toToolCalls(reply, true)}, finishReason))
+	if req.StreamOptions != nil && req.StreamOptions.IncludeUsage {
+		final := event(openai.ChatCompletionStreamChoiceDelta{}, "")
+		final.Choices = []openai.ChatCompletionStreamChoice{}
+		final.Usage = &usage
+		send(final)
+	}
+	fmt.Fprint(w, "data: [DONE]\n\n")
+}
+
+// toToolCalls converts the reply's tool calls; streamed deltas carry indexes.
+func toToolCalls(reply mocks.Response, indexed bool) []openai.ToolCall {
+	var calls []openai.ToolCall
+	for i, call := range reply.ToolCalls {
+		c := openai.ToolCall{
+			ID:       call.ID,
+			Type:     openai.ToolTypeFunction,
+			Function: openai.FunctionCall{Name: call.Name, Arguments: call.Arguments},
+		}
+		if c.ID == "" {
+			c.ID = fmt.Sprintf("call_%d", i)
+		}
+		if indexed {
+			index := i
+			c.Index = &index
+		}
+		calls = append(calls, c)
+	}
+	return calls
+}
+
+func (s *Server) handleEmbeddings(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Model      string          `json:"model"`
+		Input      json.RawMessage `json:"input"`
+		Dimensions int             `json:"dimensions"`
+	}
+	if err := fake.Captured(r).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	resp := openai.EmbeddingResponse{Object: "list", Data: []openai.Embedding{}, Model: openai.EmbeddingModel(req.Model)}
+	for i, input := range fake.Inputs(req.Input) {
+		resp.Data = append(resp.Data, openai.Embedding{Object: "embedding", Index: i, Embedding: s.Embed(input, req.Dimensions)})
+		resp.Usage.PromptTokens += len(input) / 4
+	}
+	resp.Usage.TotalTokens = resp.Usage.PromptTokens
+	fake.WriteJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,137 @@
+package openai
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/aqua777/ai-flow/llm/models"
+	client "github.com/aqua777/ai-flow/llm/openai"
+	mocks "github.com/aqua777/ai-flow/mocks/llm"
+)
+
+type ServerTestSuite struct {
+	suite.Suite
+	server *Server
+	client *client.Client
+}
+
+func TestServerTestSuite(t *testing.T) {
+	suite.Run(t, new(ServerTestSuite))
+}
+
+func (s *ServerTestSuite) SetupTest() {
+	s.server = NewServer()
+	s.server.APIKey = "test"
+	c, err := client.NewClient(&models.LLMConfig{Url: s.server.URL + "/v1", ApiKey: "test"})
+	s.Require().NoError(err)
+	s.client = c
+}
+
+func (s *ServerTestSuite) TearDownTest() {
+	s.server.Close()
+}
+
+func (s *ServerTestSuite) TestModels() {
+	ctx := context.Background()
+	s.server.Models = []string{"gpt-4o", "text-embedding-3-small"}
+	list, err := s.client.ListModels(ctx)
+	s.Require().NoError(err)
+	s.Require().Len(list, 2)
+	s.Equal("gpt-4o", list[0].ID)
+
+	model, err := s.client.GetModel(ctx, "gpt-4o")
+	s.Require().NoError(err)
+	s.Equal(128000, model.ContextSize)
+	_, err = s.client.GetModel(ctx, "nope")
+	s.ErrorIs(err, models.ErrModelNotFound)
+}
+
+func (s *ServerTestSuite) TestChat() {
+	ctx := context.Background()
+	usage := &models.ChatResponseMetadata{PromptTokens: 9, CompletionTokens: 3, TotalTokens: 12}
+	s.server.Queue(
+		mocks.Response{Content: "Hello!", Metadata: usage},
+		mocks.Response{Chunks: []string{"Hel", "lo", "!"}, Metadata: usage},
+		mocks.Response{ToolCalls: []*models.ToolCall{{ID: "call_1", Name: "get_weather", Arguments: `{"city":"Paris"}`}}},
+	)
+	req := &models.ChatRequest{Model: "gpt-4o", Messages: []*models.Message{{Role: models.UserRole, Content: "Hi"}}}
+
+	resp, err := s.client.Chat(ctx, req)
+	s.Require().NoError(err)
+	s.Equal("Hello!", resp.Content)
+	s.Equal(12, resp.Metadata.TotalTokens)
+	s.Equal(models.FinishReasonStop, resp.FinishReason)
+
+	var chunks []string
+	resp, err = s.client.Chat(ctx, req, func(chunk []byte) error {
+		chunks = append(chunks, string(chunk))
+		return nil
+	})
+	s.Require().NoError(err)
+	s.Equal([]string{"Hel", "lo", "!"}, chunks)
+	s.Equal("Hello!", resp.Content)
+	s.Equal(12, resp.Metadata.TotalTokens, "usage arrives in the last event")
+
+	_, err = s.client.Chat(ctx, req, func(chunk []byte) error { return nil })
+	s.Require().NoError(err)
+
+	var body struct {
+		Model  string `json:"model"`
+		Stream bool   `json:"stream"`
+	}
+	s.Require().NoError(s.server.LastRequest("/v1/chat/completions").Decode(&body))
+	s.True(body.Stream)
+	s.Equal("Bearer test", s.server.LastRequest("/v1/chat/completions").Header.Get("Authorization"))
+}
+
+func (s *ServerTestSuite) TestToolCallsStreamed() {
+	s.server.Queue(mocks.Response{ToolCalls: []*models.ToolCall{{ID: "call_1", Name: "get_weather", Arguments: `{"city":"Paris"}`}}})
+	resp, err := s.client.Chat(context.Background(), &models.ChatRequest{Model: "gpt-4o", Messages: []*models.Message{{Role: models.UserRole, Content: "Weather?"}}}, func(chunk []byte) error { return nil })
+	s.Require().NoError(err)
+	s.Require().Len(resp.ToolCalls, 1)
+	s.Equal("get_weather", resp.ToolCalls[0].Name)
+	s.Equal(models.FinishReasonToolCalls, resp.FinishReason)
+}
+
+func (s *ServerTestSuite) TestEmbeddings() {
+	ctx := context.Background()
+	one, err := s.client.Embeddings(ctx, &models.EmbeddingsRequest{Model: "text-embedding-3-small", Content: "Paris"})
+	s.Require().NoError(err)
+	s.Equal(mocks.HashEmbedding("Paris", 8), one.Embeddings)
+
+	batch, err := s.client.BatchEmbeddings(ctx, &models.BatchEmbeddingsRequest{Model: "text-embedding-3-small", Dimensions: 4, Inputs: []string{"a", "b"}})
+	s.Require().NoError(err)
+	s.Equal([][]float32{mocks.HashEmbedding("a", 4), mocks.HashEmbedding("b", 4)}, batch.Embeddings)
+}
+
+func (s *ServerTestSuite) TestErrors() {
+	ctx := context.Background()
+	req := &models.ChatRequest{Model: "gpt-4o", Messages: []*models.Message{{Role: models.UserRole, Content: "Hi"}}}
+
+	s.server.Fail("/v1/chat/completions", 429, "Rate limit reached")
+	_, err := s.client.Chat(ctx, req)
+	s.ErrorIs(err, models.ErrRateLimited)
+
+	s.server.FailWith("/v1/embeddings", Failure{Status: 503, Message: "Overloaded", Header: http.Header{"Retry-After": {"3"}}})
+	_, err = s.client.Embeddings(ctx, &models.EmbeddingsRequest{Content: "x"})
+	var providerErr *models.ProviderError
+	s.Require().ErrorAs(err, &providerErr)
+	s.Equal(3*time.Second, providerErr.RetryAfter)
+
+	s.server.Queue(mocks.Response{Err: errors.New("boom")})
+	_, err = s.client.Chat(ctx, req)
+	s.ErrorIs(err, models.ErrServerError)
+
+	s.server.Queue(mocks.Response{Chunks: []string{"partial"}, Err: errors.New("stream broke")})
+	_, err = s.client.Chat(ctx, req, func(chunk []byte) error { return nil })
+	s.ErrorContains(err, "stream broke")
+
+	s.server.APIKey = "other"
+	_, err = s.client.Chat(ctx, req)
+	s.ErrorIs(err, models.ErrAuthentication)
+}
@@ -0,0 +1,154 @@
+package prompt
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/aqua777/ai-flow/llm/models"
+)
+
+// MessageTemplate is one message of a ChatTemplate: either a templated
+// message with a role, or a placeholder for a list of messages.
+type MessageTemplate struct {
+	Role models.Role
+	Text string
+	// Placeholder names a variable holding []*models.Message, such as the
+	// conversation so far, inserted in place of this message.
+	Placeholder string
+}
+
+func System(text string) MessageTemplate {
+	return MessageTemplate{Role: models.SystemRole, Text: text}
+}
+
+func User(text string) MessageTemplate {
+	return MessageTemplate{Role: models.UserRole, Text: text}
+}
+
+func Assistant(text string) MessageTemplate {
+	return MessageTemplate{Role: models.AssistantRole, Text: text}
+}
+
+func Placeholder(name string) MessageTemplate {
+	return MessageTemplate{Placeholder: name}
+}
+
+type chatPart struct {
+	role        models.Role
+	tmpl        *Template
+	placeholder string
+}
+
+// ChatTemplate renders a list of messages. Its variables are those of all
+// its messages plus the placeholder names.
+type ChatTemplate struct {
+	name      string
+	parts     []chatPart
+	variables []string
+	partials  map[string]any
+}
+
+// NewChat parses each message of a chat template called name.
+func NewChat(name string, messages ...MessageTemplate) (*ChatTemplate, error) {
+	c := &ChatTemplate{name: name}
+	seen := map[string]bool{}
+	for i, m := range messages {
+		if m.Placeholder != "" {
+			c.parts = append(c.parts, chatPart{placeholder: m.Placeholder})
+			seen[m.Placeholder] = true
+			continue
+		}
+		tmpl, err := New(fmt.Sprintf("%s[%d]", name, i), m.Text)
+		if err != nil {
+			return nil, err
+		}
+		c.parts = append(c.parts, chatPart{role: m.Role, tmpl: tmpl})
+		for _, v := range tmpl.variables {
+			seen[v] = true
+		}
+	}
+	c.variables = slices.Sorted(maps.Keys(seen))
+	return c, nil
+}
+
+// MustChat panics if err is not nil. It is meant for package-level templates.
+func MustChat(c *ChatTemplate, err error) *ChatTemplate {
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+func (c *ChatTemplate) Name() string {
+	return c.name
+}
+
+// Variables returns the names still to be supplied to Format, sorted.
+func (c *ChatTemplate) Variables() []string {
+	var result []string
+	for _, name := range c.variables {
+		if _, ok := c.partials[name]; !ok {
+			result = append(result, name)
+		}
+	}
+	return result
+}
+
+// Partial returns a copy of the template with some variables already set.
+func (c *ChatTemplate) Partial(values map[string]any) (*ChatTemplate, error) {
+	if err := checkUnknown(c.name, c.variables, values); err != nil {
+		return nil, err
+	}
+	partials := maps.Clone(c.partials)
+	if partials == nil {
+		partials = make(map[string]any, len(values))
+	}
+	maps.Copy(partials, values)
+	return &ChatTemplate{name: c.name, parts: c.parts, variables: c.variables, partials: partials}, nil
+}
+
+// Format renders the messages. values must hold every variable not set by
+// Partial and nothing else; placeholder values must be []*models.Message.
+func (c *ChatTemplate) Format(values map[string]any) ([]*models.Message, error) {
+	if err := checkUnknown(c.name, c.Variables(), values); err != nil {
+		return nil, err
+	}
+	all := maps.Clone(c.partials)
+	if all == nil {
+		all = make(map[string]any, len(values))
+	}
+	maps.Copy(all, values)
+	var missing []string
+	for _, name := range c.variables {
+		if _, ok := all[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("%s: %w: %s", c.name, ErrMissingVariables, strings.Join(missing, ", "))
+	}
+
+	var messages []*models.Message
+	for _, part := range c.parts {
+		if part.placeholder != "" {
+			inserted, ok := all[part.placeholder].([]*models.Message)
+			if !ok && all[part.placeholder] != nil {
+				return nil, fmt.Errorf("%s: placeholder %s is %T, not []*models.Message", c.name, part.placeholder, all[part.placeholder])
+			}
+			messages = append(messages, inserted...)
+			continue
+		}
+		subset := make(map[string]any, len(part.tmpl.variables))
+		for _, name := range part.tmpl.variables {
+			subset[name] = all[name]
+		}
+		content, err := part.tmpl.Format(subset)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, &models.Message{Role: part.role, Content: content})
+	}
+	return messages, nil
+}
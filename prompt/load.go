@@ -0,0 +1,99 @@
+package prompt
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aqua777/ai-flow/llm/models"
+)
+
+// LoadFS reads a template from a file in fsys, such as an embed.FS. The
+// template is named after the file.
+func LoadFS(fsys fs.FS, name string) (*Template, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	return New(name, string(data))
+}
+
+// LoadFile reads a template from a file on disk.
+func LoadFile(path string) (*Template, error) {
+	return LoadFS(os.DirFS(filepath.Dir(path)), filepath.Base(path))
+}
+
+// LoadChatFS reads a chat template from a file in fsys. See ParseChat for
+// the file format.
+func LoadChatFS(fsys fs.FS, name string) (*ChatTemplate, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	return ParseChat(name, string(data))
+}
+
+// LoadChatFile reads a chat template from a file on disk.
+func LoadChatFile(path string) (*ChatTemplate, error) {
+	return LoadChatFS(os.DirFS(filepath.Dir(path)), filepath.Base(path))
+}
+
+// ParseChat parses a chat template written as text. A line holding only
+// @system, @user or @assistant starts a message with that role; the text up
+// to the next such line, trimmed, is its template. A line
+// "@placeholder name" inserts the messages of the variable name.
+//
+//	@system
+//	Answer using only {{.context}}.
+//	@placeholder history
+//	@user
+//	{{.query}}
+func ParseChat(name, text string) (*ChatTemplate, error) {
+	var (
+		messages []MessageTemplate
+		current  *MessageTemplate
+		body     strings.Builder
+	)
+	flush := func() {
+		if current != nil {
+			current.Text = strings.TrimSpace(body.String())
+			messages = append(messages, *current)
+		}
+		current = nil
+		body.Reset()
+	}
+	roles := map[string]models.Role{
+		"@system":    models.SystemRole,
+		"@user":      models.UserRole,
+		"@assistant": models.AssistantRole,
+	}
+	for i, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if role, ok := roles[trimmed]; ok {
+			flush()
+			current = &MessageTemplate{Role: role}
+			continue
+		}
+		if placeholder, ok := strings.CutPrefix(trimmed, "@placeholder"); ok && (placeholder == "" || placeholder[0] == ' ' || placeholder[0] == '\t') {
+			flush()
+			placeholder = strings.TrimSpace(placeholder)
+			if placeholder == "" {
+				return nil, fmt.Errorf("%s line %d: @placeholder needs a variable name", name, i+1)
+			}
+			messages = append(messages, Placeholder(placeholder))
+			continue
+		}
+		if current == nil {
+			if trimmed != "" {
+				return nil, fmt.Errorf("%s line %d: text before the first message marker", name, i+1)
+			}
+			continue
+		}
+		body.WriteString(line)
+		body.WriteString("\n")
+	}
+	flush()
+	return NewChat(name, messages...)
+}
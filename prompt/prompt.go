@@ -0,0 +1,202 @@
+// Package prompt renders prompts from text/template templates with named,
+// validated variables.
+package prompt
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+var (
+	ErrMissingVariables = errors.New("missing prompt variables")
+	ErrUnknownVariables = errors.New("unknown prompt variables")
+)
+
+// Funcs are available in every template.
+var Funcs = template.FuncMap{
+	"join":  strings.Join,
+	"trim":  strings.TrimSpace,
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+}
+
+// Template is a text prompt whose variables are the top-level fields it
+// references, such as {{.query}} or {{range .docs}}. Format checks that the
+// values supply exactly those variables.
+type Template struct {
+	tmpl      *template.Template
+	variables []string
+	partials  map[string]any
+}
+
+// New parses text as a template called name.
+func New(name, text string) (*Template, error) {
+	tmpl, err := template.New(name).Funcs(Funcs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	return &Template{tmpl: tmpl, variables: variables(tmpl)}, nil
+}
+
+// Must panics if err is not nil. It is meant for package-level templates.
+func Must(t *Template, err error) *Template {
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func (t *Template) Name() string {
+	return t.tmpl.Name()
+}
+
+// Variables returns the names still to be supplied to Format, sorted.
+func (t *Template) Variables() []string {
+	var result []string
+	for _, name := range t.variables {
+		if _, ok := t.partials[name]; !ok {
+			result = append(result, name)
+		}
+	}
+	return result
+}
+
+// Partial returns a copy of the template with some variables already set.
+// Format then only needs the rest. Values for names the template does not
+// use fail with ErrUnknownVariables.
+func (t *Template) Partial(values map[string]any) (*Template, error) {
+	if err := checkUnknown(t.Name(), t.variables, values); err != nil {
+		return nil, err
+	}
+	partials := maps.Clone(t.partials)
+	if partials == nil {
+		partials = make(map[string]any, len(values))
+	}
+	maps.Copy(partials, values)
+	return &Template{tmpl: t.tmpl, variables: t.variables, partials: partials}, nil
+}
+
+// Format renders the template. values must hold every variable not set by
+// Partial and nothing else.
+func (t *Template) Format(values map[string]any) (string, error) {
+	if err := checkUnknown(t.Name(), t.Variables(), values); err != nil {
+		return "", err
+	}
+	all := maps.Clone(t.partials)
+	if all == nil {
+		all = make(map[string]any, len(values))
+	}
+	maps.Copy(all, values)
+	var missing []string
+	for _, name := range t.variables {
+		if _, ok := all[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return "", fmt.Errorf("%s: %w: %s", t.Name(), ErrMissingVariables, strings.Join(missing, ", "))
+	}
+	var sb strings.Builder
+	if err := t.tmpl.Execute(&sb, all); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+func checkUnknown(name string, allowed []string, values map[string]any) error {
+	var unknown []string
+	for key := range values {
+		if !slices.Contains(allowed, key) {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) > 0 {
+		slices.Sort(unknown)
+		return fmt.Errorf("%s: %w: %s", name, ErrUnknownVariables, strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// variables collects the top-level field names the template references,
+// following {{template "name" .}} into templates that receive the same data.
+func variables(tmpl *template.Template) []string {
+	w := &walker{tmpl: tmpl, seen: map[string]bool{}, visited: map[string]bool{tmpl.Name(): true}}
+	w.walk(tmpl.Tree.Root, true)
+	return slices.Sorted(maps.Keys(w.seen))
+}
+
+type walker struct {
+	tmpl    *template.Template
+	seen    map[string]bool
+	visited map[string]bool
+}
+
+// walk records fields used on the template's data. Inside range and with
+// blocks dot is rebound, so only $.name references count there.
+func (w *walker) walk(node parse.Node, dotIsRoot bool) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			w.walk(child, dotIsRoot)
+		}
+	case *parse.ActionNode:
+		w.walk(n.Pipe, dotIsRoot)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, cmd := range n.Cmds {
+			w.walk(cmd, dotIsRoot)
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			w.walk(arg, dotIsRoot)
+		}
+	case *parse.FieldNode:
+		if dotIsRoot {
+			w.seen[n.Ident[0]] = true
+		}
+	case *parse.ChainNode:
+		w.walk(n.Node, dotIsRoot)
+	case *parse.VariableNode:
+		if n.Ident[0] == "$" && len(n.Ident) > 1 {
+			w.seen[n.Ident[1]] = true
+		}
+	case *parse.IfNode:
+		w.walk(n.Pipe, dotIsRoot)
+		w.walk(n.List, dotIsRoot)
+		w.walk(n.ElseList, dotIsRoot)
+	case *parse.RangeNode:
+		w.walk(n.Pipe, dotIsRoot)
+		w.walk(n.List, false)
+		w.walk(n.ElseList, dotIsRoot)
+	case *parse.WithNode:
+		w.walk(n.Pipe, dotIsRoot)
+		w.walk(n.List, false)
+		w.walk(n.ElseList, dotIsRoot)
+	case *parse.TemplateNode:
+		w.walk(n.Pipe, dotIsRoot)
+		if dotIsRoot && passesDot(n.Pipe) && !w.visited[n.Name] {
+			w.visited[n.Name] = true
+			if t := w.tmpl.Lookup(n.Name); t != nil && t.Tree != nil {
+				w.walk(t.Tree.Root, true)
+			}
+		}
+	}
+}
+
+func passesDot(pipe *parse.PipeNode) bool {
+	if pipe == nil || len(pipe.Cmds) != 1 || len(pipe.Cmds[0].Args) != 1 {
+		return false
+	}
+	_, ok := pipe.Cmds[0].Args[0].(*parse.DotNode)
+	return ok
+}
@@ -0,0 +1,125 @@
+package prompt
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/aqua777/ai-flow/llm/models"
+)
+
+type PromptTestSuite struct {
+	suite.Suite
+}
+
+func TestPromptTestSuite(t *testing.T) {
+	suite.Run(t, new(PromptTestSuite))
+}
+
+func (s *PromptTestSuite) TestFormat() {
+	tmpl, err := New("qa", `{{define "ctx"}}[{{.context}}]{{end}}{{template "ctx" .}} {{if .strict}}Only use the context. {{end}}{{range .examples}}{{.}} for {{$.query}}; {{end}}Q: {{upper .query}}`)
+	s.Require().NoError(err)
+	s.Equal([]string{"context", "examples", "query", "strict"}, tmpl.Variables())
+
+	out, err := tmpl.Format(map[string]any{"context": "docs", "strict": true, "examples": []string{"a", "b"}, "query": "why"})
+	s.Require().NoError(err)
+	s.Equal("[docs] Only use the context. a for why; b for why; Q: WHY", out)
+
+	_, err = tmpl.Format(map[string]any{"context": "docs", "query": "why"})
+	s.ErrorIs(err, ErrMissingVariables)
+	s.EqualError(err, "qa: missing prompt variables: examples, strict")
+
+	_, err = tmpl.Format(map[string]any{"context": "docs", "strict": false, "examples": nil, "query": "why", "extra": 1, "another": 2})
+	s.ErrorIs(err, ErrUnknownVariables)
+	s.EqualError(err, "qa: unknown prompt variables: another, extra")
+
+	_, err = New("bad", "{{.query")
+	s.Error(err)
+}
+
+func (s *PromptTestSuite) TestPartial() {
+	tmpl := Must(New("greet", "{{.greeting}}, {{.name}}!"))
+	hello, err := tmpl.Partial(map[string]any{"greeting": "Hello"})
+	s.Require().NoError(err)
+	s.Equal([]string{"name"}, hello.Variables())
+	s.Equal([]string{"greeting", "name"}, tmpl.Variables(), "the original is unchanged")
+
+	out, err := hello.Format(map[string]any{"name": "Ada"})
+	s.Require().NoError(err)
+	s.Equal("Hello, Ada!", out)
+
+	_, err = hello.Format(map[string]any{"name": "Ada", "greeting": "Hi"})
+	s.ErrorIs(err, ErrUnknownVariables, "partial values are not supplied again")
+	_, err = tmpl.Partial(map[string]any{"nope": 1})
+	s.ErrorIs(err, ErrUnknownVariables)
+}
+
+func (s *PromptTestSuite) TestChat() {
+	chat, err := NewChat("assistant",
+		System("You are {{.persona}}."),
+		Placeholder("history"),
+		User("{{.query}}"),
+	)
+	s.Require().NoError(err)
+	s.Equal([]string{"history", "persona", "query"}, chat.Variables())
+
+	chat, err = chat.Partial(map[string]any{"persona": "terse"})
+	s.Require().NoError(err)
+	history := []*models.Message{
+		{Role: models.UserRole, Content: "hi"},
+		{Role: models.AssistantRole, Content: "hello"},
+	}
+	messages, err := chat.Format(map[string]any{"history": history, "query": "bye"})
+	s.Require().NoError(err)
+	s.Equal([]*models.Message{
+		{Role: models.SystemRole, Content: "You are terse."},
+		history[0],
+		history[1],
+		{Role: models.UserRole, Content: "bye"},
+	}, messages)
+
+	_, err = chat.Format(map[string]any{"query": "bye"})
+	s.ErrorIs(err, ErrMissingVariables)
+	_, err = chat.Format(map[string]any{"history": "nope", "query": "bye"})
+	s.ErrorContains(err, "placeholder history is string")
+}
+
+func (s *PromptTestSuite) TestLoad() {
+	fsys := fstest.MapFS{
+		"qa.tmpl": {Data: []byte("Q: {{.query}}")},
+		"chat.tmpl": {Data: []byte(`
+@system
+Answer from {{.context}}.
+
+@placeholder history
+@user
+{{.query}}
+`)},
+		"bad.tmpl": {Data: []byte("hello\n@user\nhi")},
+	}
+	tmpl, err := LoadFS(fsys, "qa.tmpl")
+	s.Require().NoError(err)
+	s.Equal("qa.tmpl", tmpl.Name())
+	s.Equal([]string{"query"}, tmpl.Variables())
+
+	chat, err := LoadChatFS(fsys, "chat.tmpl")
+	s.Require().NoError(err)
+	messages, err := chat.Format(map[string]any{"context": "docs", "history": []*models.Message(nil), "query": "why"})
+	s.Require().NoError(err)
+	s.Equal([]*models.Message{
+		{Role: models.SystemRole, Content: "Answer from docs."},
+		{Role: models.UserRole, Content: "why"},
+	}, messages)
+
+	_, err = LoadChatFS(fsys, "bad.tmpl")
+	s.EqualError(err, "bad.tmpl line 1: text before the first message marker")
+
+	path := filepath.Join(s.T().TempDir(), "file.tmpl")
+	s.Require().NoError(os.WriteFile(path, []byte("{{.x}}"), 0o644))
+	tmpl, err = LoadFile(path)
+	s.Require().NoError(err)
+	s.Equal([]string{"x"}, tmpl.Variables())
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/stretchr/testify/suite"
 
+	"github.com/aqua777/ai-flow/llm/models"
 	"github.com/aqua777/ai-flow/vectordb/v1"
 )
 
@@ -63,6 +64,38 @@ func (s *CallbacksTestSuite) TestIngestionCallbacks() {
 	s.True(completed, "OnIngestCompleted should be called")
 	s.Nil(errResult, "OnIngestError should not be called")
 	s.Greater(progressCount, 0, "OnIngestProgress should be called at least once")
+	s.Equal(1, mockLLM.BatchCalls, "chunks of a document should be embedded in one batch call")
 }
 
 
+
+// shortBatchLLM returns one embedding fewer than requested.
+type shortBatchLLM struct {
+	*MockLLM
+}
+
+func (m *shortBatchLLM) BatchEmbeddings(ctx context.Context, r *models.BatchEmbeddingsRequest) (*models.BatchEmbeddingsResponse, error) {
+	resp, err := m.MockLLM.BatchEmbeddings(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	resp.Embeddings = resp.Embeddings[:len(resp.Embeddings)-1]
+	return resp, nil
+}
+
+func (s *CallbacksTestSuite) TestIngestionEmbeddingCountMismatch() {
+	ragSystem, err := NewRAGSystem(&RAGConfig{ChunkSize: 10, ChunkOverlap: 0})
+	s.Require().NoError(err)
+	mockLLM := &shortBatchLLM{&MockLLM{Embedding: []float32{0.1, 0.2, 0.3}}}
+	ragSystem.WithEmbedding(mockLLM).WithLLM(mockLLM).WithVectorStore(store.NewSimpleVectorStore())
+
+	var errResult error
+	ragSystem.WithOnIngestError(func(err error) {
+		errResult = err
+	})
+
+	err = ragSystem.IngestText(context.Background(), "This is a test document that should be split into chunks.", "test-id")
+	s.Require().Error(err)
+	s.Contains(err.Error(), "embeddings, got")
+	s.Equal(err, errResult)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/aqua777/ai-flow/tracing"
 	"github.com/aqua777/ai-flow/vectordb/v1/schema"
 )
 
@@ -32,13 +33,16 @@ func (e *RetrieverQueryEngine) Synthesize(ctx context.Context, query schema.Quer
 }
 
 // Query performs the end-to-end RAG flow: retrieve -> synthesize.
-func (e *RetrieverQueryEngine) Query(ctx context.Context, query schema.QueryBundle) (schema.EngineResponse, error) {
+func (e *RetrieverQueryEngine) Query(ctx context.Context, query schema.QueryBundle) (response schema.EngineResponse, err error) {
+	ctx, span := tracing.Start(ctx, "rag.query")
+	defer func() { span.Finish(err) }()
+
 	nodes, err := e.Retrieve(ctx, query)
 	if err != nil {
 		return schema.EngineResponse{}, fmt.Errorf("retrieve failed: %w", err)
 	}
 
-	response, err := e.Synthesize(ctx, query, nodes)
+	response, err = e.Synthesize(ctx, query, nodes)
 	if err != nil {
 		return schema.EngineResponse{}, fmt.Errorf("synthesize failed: %w", err)
 	}
@@ -47,13 +51,19 @@ func (e *RetrieverQueryEngine) Query(ctx context.Context, query schema.QueryBund
 }
 
 // QueryStream performs the end-to-end RAG flow with streaming: retrieve -> synthesize stream.
-func (e *RetrieverQueryEngine) QueryStream(ctx context.Context, query schema.QueryBundle) (schema.StreamingEngineResponse, error) {
+func (e *RetrieverQueryEngine) QueryStream(ctx context.Context, query schema.QueryBundle) (response schema.StreamingEngineResponse, err error) {
+	ctx, span := tracing.Start(ctx, "rag.query")
+	span.SetAttribute("stream", true)
+	// The span covers retrieval and the start of synthesis; the streamed
+	// answer is traced by the synthesizer's own span.
+	defer func() { span.Finish(err) }()
+
 	nodes, err := e.Retrieve(ctx, query)
 	if err != nil {
 		return schema.StreamingEngineResponse{}, fmt.Errorf("retrieve failed: %w", err)
 	}
 
-	response, err := e.synthesizer.SynthesizeStream(ctx, query, nodes)
+	response, err = e.synthesizer.SynthesizeStream(ctx, query, nodes)
 	if err != nil {
 		return schema.StreamingEngineResponse{}, fmt.Errorf("synthesize stream failed: %w", err)
 	}
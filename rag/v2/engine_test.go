@@ -8,6 +8,8 @@ import (
 
 	"github.com/aqua777/ai-flow/llm/iface"
 	"github.com/aqua777/ai-flow/llm/models"
+	"github.com/aqua777/ai-flow/prompt"
+	"github.com/aqua777/ai-flow/tracing"
 	"github.com/aqua777/ai-flow/vectordb/v1/schema"
 	"github.com/aqua777/ai-flow/vectordb/v1"
 	"github.com/aqua777/ai-flow/vectordb/v1/chromem"
@@ -41,6 +43,8 @@ func (m *MockSynthesizer) SynthesizeStream(ctx context.Context, query schema.Que
 type MockLLM struct {
 	ChatResponse string
 	Embedding    []float32
+	BatchCalls   int
+	ChatRequests []*models.ChatRequest
 }
 
 var _ iface.LLM = (*MockLLM)(nil)
@@ -49,11 +53,12 @@ func (m *MockLLM) ListModels(ctx context.Context) ([]*models.Model, error) {
 	return nil, nil
 }
 
-func (m *MockLLM) Generate(ctx context.Context, r *models.GenerateRequest) (*models.GenerateResponse, error) {
+func (m *MockLLM) Generate(ctx context.Context, r *models.GenerateRequest, stream ...func(chunk []byte) error) (*models.GenerateResponse, error) {
 	return &models.GenerateResponse{Text: m.ChatResponse}, nil
 }
 
 func (m *MockLLM) Chat(ctx context.Context, r *models.ChatRequest, stream ...func(chunk []byte) error) (*models.ChatResponse, error) {
+	m.ChatRequests = append(m.ChatRequests, r)
 	return &models.ChatResponse{Content: m.ChatResponse}, nil
 }
 
@@ -61,6 +66,15 @@ func (m *MockLLM) Embeddings(ctx context.Context, cr *models.EmbeddingsRequest)
 	return &models.EmbeddingsResponse{Embeddings: m.Embedding}, nil
 }
 
+func (m *MockLLM) BatchEmbeddings(ctx context.Context, r *models.BatchEmbeddingsRequest) (*models.BatchEmbeddingsResponse, error) {
+	m.BatchCalls++
+	embeddings := make([][]float32, len(r.Inputs))
+	for i := range r.Inputs {
+		embeddings[i] = m.Embedding
+	}
+	return &models.BatchEmbeddingsResponse{Embeddings: embeddings}, nil
+}
+
 type EngineTestSuite struct {
 	suite.Suite
 }
@@ -207,3 +221,70 @@ func (s *EngineTestSuite) TestFullRAGFlow_Chromem() {
 	// 1st node has exact same vector as query (mock), so it should be first.
 	s.Equal("The capital of France is Paris.", response.SourceNodes[0].Node.Text)
 }
+
+func (s *EngineTestSuite) TestSimpleSynthesizer_Templates() {
+	ctx := context.Background()
+	mockLLM := &MockLLM{ChatResponse: "Paris"}
+	query := schema.QueryBundle{QueryString: "Capital of France?"}
+	nodes := []schema.NodeWithScore{{Node: schema.Node{ID: "1", Text: "Paris is in France."}}}
+
+	synthesizer := NewSimpleSynthesizer(mockLLM, "test-llm-model")
+	_, err := synthesizer.Synthesize(ctx, query, nodes)
+	s.Require().NoError(err)
+	s.Contains(mockLLM.ChatRequests[0].Messages[0].Content, "Paris is in France.\n\n---------------------\n")
+	s.Contains(mockLLM.ChatRequests[0].Messages[0].Content, "Query: Capital of France?\nAnswer:")
+
+	// A template may leave out context or query.
+	synthesizer.WithTemplate(prompt.Must(prompt.New("bare", "Answer: {{.query}}")))
+	_, err = synthesizer.Synthesize(ctx, query, nodes)
+	s.Require().NoError(err)
+	s.Equal("Answer: Capital of France?", mockLLM.ChatRequests[1].Messages[0].Content)
+
+	chat := prompt.MustChat(prompt.NewChat("qa",
+		prompt.System("Answer in {{.language}} using:\n{{.context}}"),
+		prompt.User("{{.query}}"),
+	))
+	french, err := chat.Partial(map[string]any{"language": "French"})
+	s.Require().NoError(err)
+	_, err = synthesizer.WithChatTemplate(french).Synthesize(ctx, query, nodes)
+	s.Require().NoError(err)
+	s.Equal([]*models.Message{
+		{Role: models.SystemRole, Content: "Answer in French using:\nParis is in France.\n"},
+		{Role: models.UserRole, Content: "Capital of France?"},
+	}, mockLLM.ChatRequests[2].Messages)
+
+	// Variables other than context and query must be set beforehand.
+	_, err = synthesizer.WithChatTemplate(chat).SynthesizeStream(ctx, query, nodes)
+	s.ErrorIs(err, prompt.ErrMissingVariables)
+}
+
+func (s *EngineTestSuite) TestTracing() {
+	ctx := context.Background()
+	mockLLM := &MockLLM{Embedding: []float32{0.1, 0.2, 0.3}, ChatResponse: "Paris"}
+	recorder := tracing.NewRecorder()
+	ragSystem, err := NewRAGSystem(&RAGConfig{ChunkSize: 10, ChunkOverlap: 0})
+	s.Require().NoError(err)
+	ragSystem.WithEmbedding(mockLLM).WithLLM(mockLLM).WithVectorStore(store.NewSimpleVectorStore()).WithTracer(recorder)
+
+	s.Require().NoError(ragSystem.IngestText(ctx, "The capital of France is Paris.", "doc"))
+	ingest := recorder.Find("rag.ingest")
+	s.Require().Len(ingest, 1)
+	s.NoError(ingest[0].Err)
+	s.Equal(1, ingest[0].Attributes()["documents"])
+	documents := recorder.Children(ingest[0])
+	s.Require().Len(documents, 1)
+	s.Equal("doc", documents[0].Attributes()["document_id"])
+
+	_, err = ragSystem.Query(ctx, "Capital of France?")
+	s.Require().NoError(err)
+	query := recorder.Find("rag.query")
+	s.Require().Len(query, 1)
+	children := recorder.Children(query[0])
+	s.Require().Len(children, 2)
+	s.Equal("rag.retrieve", children[0].Name)
+	s.Equal(1, children[0].Attributes()["results"])
+	s.Equal("rag.synthesize", children[1].Name)
+	s.Equal("gpt-3.5-turbo", children[1].Attributes()["model"])
+	s.Equal(query[0].TraceID, children[1].TraceID)
+	s.NotEqual(ingest[0].TraceID, query[0].TraceID)
+}
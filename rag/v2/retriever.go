@@ -6,6 +6,7 @@ import (
 
 	"github.com/aqua777/ai-flow/llm/iface"
 	"github.com/aqua777/ai-flow/llm/models"
+	"github.com/aqua777/ai-flow/tracing"
 	"github.com/aqua777/ai-flow/vectordb/v1/schema"
 	"github.com/aqua777/ai-flow/vectordb/v1"
 )
@@ -28,7 +29,14 @@ func NewVectorRetriever(vectorStore store.VectorStore, embedder iface.LLM, embed
 	}
 }
 
-func (r *VectorRetriever) Retrieve(ctx context.Context, query schema.QueryBundle) ([]schema.NodeWithScore, error) {
+func (r *VectorRetriever) Retrieve(ctx context.Context, query schema.QueryBundle) (nodes []schema.NodeWithScore, err error) {
+	ctx, span := tracing.Start(ctx, "rag.retrieve")
+	span.SetAttribute("top_k", r.topK)
+	defer func() {
+		span.SetAttribute("results", len(nodes))
+		span.Finish(err)
+	}()
+
 	resp, err := r.embedder.Embeddings(ctx, &models.EmbeddingsRequest{
 		Content: query.QueryString,
 		Model:   r.embeddingModelName,
@@ -49,7 +57,7 @@ func (r *VectorRetriever) Retrieve(ctx context.Context, query schema.QueryBundle
 		Filters:   query.Filters,
 	}
 
-	nodes, err := r.vectorStore.Query(ctx, storeQuery)
+	nodes, err = r.vectorStore.Query(ctx, storeQuery)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query vector store: %w", err)
 	}
@@ -7,13 +7,21 @@ import (
 
 	"github.com/aqua777/ai-flow/llm/iface"
 	"github.com/aqua777/ai-flow/llm/models"
+	"github.com/aqua777/ai-flow/prompt"
+	"github.com/aqua777/ai-flow/tracing"
 	"github.com/aqua777/ai-flow/vectordb/v1/schema"
 )
 
+// DefaultQATemplate is the prompt SimpleSynthesizer uses unless given
+// another. Templates for the synthesizer take the variables context and query.
+var DefaultQATemplate = prompt.Must(prompt.New("qa", "Context information is below.\n---------------------\n{{.context}}\n---------------------\nGiven the context information and not prior knowledge, answer the query.\nQuery: {{.query}}\nAnswer:"))
+
 // SimpleSynthesizer generates a response by stuffing retrieved context into a prompt.
 type SimpleSynthesizer struct {
 	llm          iface.LLM
 	llmModelName string
+	template     *prompt.Template
+	chatTemplate *prompt.ChatTemplate
 }
 
 // NewSimpleSynthesizer creates a new SimpleSynthesizer.
@@ -21,27 +29,47 @@ func NewSimpleSynthesizer(llm iface.LLM, llmModelName string) *SimpleSynthesizer
 	return &SimpleSynthesizer{
 		llm:          llm,
 		llmModelName: llmModelName,
+		template:     DefaultQATemplate,
 	}
 }
 
+// WithTemplate sets the template rendered as the single user message.
+func (s *SimpleSynthesizer) WithTemplate(template *prompt.Template) *SimpleSynthesizer {
+	s.template = template
+	s.chatTemplate = nil
+	return s
+}
+
+// WithChatTemplate sets a template rendered as the whole list of messages,
+// for example to add a system prompt.
+func (s *SimpleSynthesizer) WithChatTemplate(template *prompt.ChatTemplate) *SimpleSynthesizer {
+	s.chatTemplate = template
+	return s
+}
+
 func (s *SimpleSynthesizer) Synthesize(ctx context.Context, query schema.QueryBundle, nodes []schema.NodeWithScore) (schema.EngineResponse, error) {
-	contextStr := s.formatContext(nodes)
-	prompt := s.createPrompt(contextStr, query.QueryString)
+	ctx, span := s.startSpan(ctx, nodes)
+	messages, err := s.createMessages(s.formatContext(nodes), query.QueryString)
+	if err != nil {
+		span.Finish(err)
+		return schema.EngineResponse{}, err
+	}
 
 	req := &models.ChatRequest{
-		Model: s.llmModelName,
-		Messages: []*models.Message{
-			{
-				Role:    models.UserRole,
-				Content: prompt,
-			},
-		},
+		Model:    s.llmModelName,
+		Messages: messages,
 	}
 
 	resp, err := s.llm.Chat(ctx, req)
 	if err != nil {
-		return schema.EngineResponse{}, fmt.Errorf("llm completion failed: %w", err)
+		err = fmt.Errorf("llm completion failed: %w", err)
+		span.Finish(err)
+		return schema.EngineResponse{}, err
+	}
+	if resp.Metadata != nil {
+		span.SetTokens(resp.Metadata.PromptTokens, resp.Metadata.CompletionTokens)
 	}
+	span.Finish(nil)
 
 	return schema.EngineResponse{
 		Response:    resp.Content,
@@ -50,37 +78,35 @@ func (s *SimpleSynthesizer) Synthesize(ctx context.Context, query schema.QueryBu
 }
 
 func (s *SimpleSynthesizer) SynthesizeStream(ctx context.Context, query schema.QueryBundle, nodes []schema.NodeWithScore) (schema.StreamingEngineResponse, error) {
-	contextStr := s.formatContext(nodes)
-	prompt := s.createPrompt(contextStr, query.QueryString)
+	ctx, span := s.startSpan(ctx, nodes)
+	span.SetAttribute("stream", true)
+	messages, err := s.createMessages(s.formatContext(nodes), query.QueryString)
+	if err != nil {
+		span.Finish(err)
+		return schema.StreamingEngineResponse{}, err
+	}
 
 	// Create channel for streaming response
 	tokenChan := make(chan string)
 
 	req := &models.ChatRequest{
-		Model: s.llmModelName,
-		Messages: []*models.Message{
-			{
-				Role:    models.UserRole,
-				Content: prompt,
-			},
-		},
-		Stream: true,
+		Model:    s.llmModelName,
+		Messages: messages,
+		Stream:   true,
 	}
 
 	go func() {
 		defer close(tokenChan)
-		_, err := s.llm.Chat(ctx, req, func(chunk []byte) error {
+		resp, err := s.llm.Chat(ctx, req, func(chunk []byte) error {
 			tokenChan <- string(chunk)
 			return nil
 		})
-		if err != nil {
-			// Log error or handle it? Since we are inside a goroutine and the channel is the only output,
-			// typically we might send an error or just close.
-			// For this simple interface, we might just log or accept that the stream ends.
-			// But wait, QueryStream returns a channel.
-			// The original implementation returned `<-chan string, error`.
-			// Here we are returning the channel immediately.
+		// The channel is the only output, so a failed stream just ends early;
+		// the span is where the error shows up.
+		if err == nil && resp.Metadata != nil {
+			span.SetTokens(resp.Metadata.PromptTokens, resp.Metadata.CompletionTokens)
 		}
+		span.Finish(err)
 	}()
 
 	return schema.StreamingEngineResponse{
@@ -89,6 +115,13 @@ func (s *SimpleSynthesizer) SynthesizeStream(ctx context.Context, query schema.Q
 	}, nil
 }
 
+func (s *SimpleSynthesizer) startSpan(ctx context.Context, nodes []schema.NodeWithScore) (context.Context, *tracing.Span) {
+	ctx, span := tracing.Start(ctx, "rag.synthesize")
+	span.SetAttribute("model", s.llmModelName)
+	span.SetAttribute("nodes", len(nodes))
+	return ctx, span
+}
+
 func (s *SimpleSynthesizer) formatContext(nodes []schema.NodeWithScore) string {
 	var sb strings.Builder
 	for _, n := range nodes {
@@ -98,6 +131,28 @@ func (s *SimpleSynthesizer) formatContext(nodes []schema.NodeWithScore) string {
 	return sb.String()
 }
 
-func (s *SimpleSynthesizer) createPrompt(context, query string) string {
-	return fmt.Sprintf("Context information is below.\n---------------------\n%s\n---------------------\nGiven the context information and not prior knowledge, answer the query.\nQuery: %s\nAnswer:", context, query)
+func (s *SimpleSynthesizer) createMessages(context, query string) ([]*models.Message, error) {
+	if s.chatTemplate != nil {
+		return s.chatTemplate.Format(templateValues(s.chatTemplate.Variables(), context, query))
+	}
+	content, err := s.template.Format(templateValues(s.template.Variables(), context, query))
+	if err != nil {
+		return nil, err
+	}
+	return []*models.Message{{Role: models.UserRole, Content: content}}, nil
+}
+
+// templateValues supplies context and query to templates that use them, so a
+// template may leave either out; any other variable must be set with Partial.
+func templateValues(variables []string, context, query string) map[string]any {
+	values := make(map[string]any, 2)
+	for _, name := range variables {
+		switch name {
+		case "context":
+			values[name] = context
+		case "query":
+			values[name] = query
+		}
+	}
+	return values
 }
@@ -12,6 +12,7 @@ import (
 	// llm_openai "github.com/aqua777/ai-flow/llm/openai"
 	"github.com/aqua777/ai-flow/rag/v2/reader"
 	"github.com/aqua777/ai-flow/textsplitter"
+	"github.com/aqua777/ai-flow/tracing"
 	store "github.com/aqua777/ai-flow/vectordb/v1"
 	"github.com/aqua777/ai-flow/vectordb/v1/schema"
 
@@ -60,6 +61,9 @@ type RAGSystem struct {
 	QueryEngine *RetrieverQueryEngine
 	Splitter    *textsplitter.SentenceSplitter
 	Callbacks   IngestionCallbacks
+	// Tracer receives spans for queries and ingestion run with a context
+	// that has no tracer of its own.
+	Tracer tracing.Tracer
 }
 
 // NewRAGSystem creates a new RAGSystem with the provided configuration.
@@ -125,6 +129,11 @@ func (s *RAGSystem) WithVectorStore(vectorStore store.VectorStore) *RAGSystem {
 	return s
 }
 
+func (s *RAGSystem) WithTracer(tracer tracing.Tracer) *RAGSystem {
+	s.Tracer = tracer
+	return s
+}
+
 func (s *RAGSystem) WithOnIngestStarted(callback func(totalDocs int)) *RAGSystem {
 	s.Callbacks.OnIngestStarted = callback
 	return s
@@ -251,7 +260,11 @@ func (s *RAGSystem) IngestFile(ctx context.Context, filePath string) error {
 }
 
 // ingestDocuments handles the common logic of splitting, embedding, and adding documents to the store.
-func (s *RAGSystem) ingestDocuments(ctx context.Context, docs []schema.Document) error {
+func (s *RAGSystem) ingestDocuments(ctx context.Context, docs []schema.Document) (err error) {
+	ctx, span := tracing.Start(s.traceContext(ctx), "rag.ingest")
+	defer func() { span.Finish(err) }()
+	span.SetAttribute("documents", len(docs))
+
 	totalDocs := len(docs)
 	if s.Callbacks.OnIngestStarted != nil {
 		s.Callbacks.OnIngestStarted(totalDocs)
@@ -262,7 +275,11 @@ func (s *RAGSystem) ingestDocuments(ctx context.Context, docs []schema.Document)
 	for docIdx, doc := range docs {
 		chunks := s.Splitter.SplitText(doc.Text)
 		totalChunks := len(chunks)
+		if totalChunks == 0 {
+			continue
+		}
 
+		nodes := make([]schema.Node, totalChunks)
 		for i, chunk := range chunks {
 			if s.Callbacks.OnIngestProgress != nil {
 				s.Callbacks.OnIngestProgress(IngestProgress{
@@ -289,32 +306,42 @@ func (s *RAGSystem) ingestDocuments(ctx context.Context, docs []schema.Document)
 					node.Metadata[k] = v
 				}
 			}
+			nodes[i] = node
+		}
 
-			// Generate embedding explicitly
-			resp, err := s.Embedder.Embeddings(ctx, &models.EmbeddingsRequest{
-				Content: chunk,
-				Model:   s.Config.EmbeddingModel,
-			})
-			if err != nil {
-				err = fmt.Errorf("failed to get embedding for chunk %d of doc %s: %w", i, doc.ID, err)
-				if s.Callbacks.OnIngestError != nil {
-					s.Callbacks.OnIngestError(err)
-				}
-				return err
+		// Embed all chunks of the document in one batch call
+		docCtx, docSpan := tracing.Start(ctx, "rag.ingest.document")
+		docSpan.SetAttribute("document_id", doc.ID)
+		docSpan.SetAttribute("chunks", totalChunks)
+		resp, err := s.Embedder.BatchEmbeddings(docCtx, &models.BatchEmbeddingsRequest{
+			Inputs: chunks,
+			Model:  s.Config.EmbeddingModel,
+		})
+		if err == nil && len(resp.Embeddings) != totalChunks {
+			err = fmt.Errorf("expected %d embeddings, got %d", totalChunks, len(resp.Embeddings))
+		}
+		docSpan.Finish(err)
+		if err != nil {
+			err = fmt.Errorf("failed to get embeddings for %d chunks of doc %s: %w", totalChunks, doc.ID, err)
+			if s.Callbacks.OnIngestError != nil {
+				s.Callbacks.OnIngestError(err)
 			}
+			return err
+		}
 
+		for i := range nodes {
 			// Convert float32 to float64
-			embedding := make([]float64, len(resp.Embeddings))
-			for j, v := range resp.Embeddings {
+			embedding := make([]float64, len(resp.Embeddings[i]))
+			for j, v := range resp.Embeddings[i] {
 				embedding[j] = float64(v)
 			}
-
-			node.Embedding = embedding
-			allNodes = append(allNodes, node)
+			nodes[i].Embedding = embedding
 		}
+		allNodes = append(allNodes, nodes...)
 	}
 
 	// 3. Ingest
+	span.SetAttribute("nodes", len(allNodes))
 	if len(allNodes) > 0 {
 		_, err := s.VectorStore.Add(ctx, allNodes)
 		if err != nil {
@@ -339,9 +366,17 @@ func (s *RAGSystem) Query(ctx context.Context, queryStr string) (string, error)
 		return "", err
 	}
 
-	response, err := s.QueryEngine.Query(ctx, schema.QueryBundle{QueryString: queryStr})
+	response, err := s.QueryEngine.Query(s.traceContext(ctx), schema.QueryBundle{QueryString: queryStr})
 	if err != nil {
 		return "", err
 	}
 	return response.Response, nil
 }
+
+// traceContext attaches the system's tracer to ctx unless it already has one.
+func (s *RAGSystem) traceContext(ctx context.Context) context.Context {
+	if s.Tracer == nil || tracing.FromContext(ctx) != nil {
+		return ctx
+	}
+	return tracing.WithTracer(ctx, s.Tracer)
+}
@@ -0,0 +1,134 @@
+package tracing
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"slices"
+	"sync"
+)
+
+// SlogTracer logs every ended span, at Level or at Error when the span
+// failed. Span starts are logged at Debug.
+type SlogTracer struct {
+	Logger *slog.Logger
+	Level  slog.Level
+}
+
+// Ensure SlogTracer implements Tracer
+var _ Tracer = (*SlogTracer)(nil)
+
+// NewSlogTracer logs to logger at Info; a nil logger uses slog.Default().
+func NewSlogTracer(logger *slog.Logger) *SlogTracer {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &SlogTracer{Logger: logger, Level: slog.LevelInfo}
+}
+
+func (t *SlogTracer) OnStart(span *Span) {
+	t.Logger.Debug("span started", "name", span.Name, "trace_id", span.TraceID, "span_id", span.ID, "parent_id", span.ParentID)
+}
+
+func (t *SlogTracer) OnEnd(span *Span) {
+	args := []any{
+		"name", span.Name,
+		"trace_id", span.TraceID,
+		"span_id", span.ID,
+		"parent_id", span.ParentID,
+		"duration", span.Duration(),
+	}
+	if span.PromptTokens > 0 || span.CompletionTokens > 0 {
+		args = append(args, "prompt_tokens", span.PromptTokens, "completion_tokens", span.CompletionTokens)
+	}
+	attributes := span.Attributes()
+	if len(attributes) > 0 {
+		attrs := make([]any, 0, len(attributes))
+		for _, key := range slices.Sorted(maps.Keys(attributes)) {
+			attrs = append(attrs, slog.Any(key, attributes[key]))
+		}
+		args = append(args, slog.Group("attributes", attrs...))
+	}
+	level := t.Level
+	if span.Err != nil {
+		level = slog.LevelError
+		args = append(args, "error", span.Err)
+	}
+	t.Logger.Log(context.Background(), level, "span ended", args...)
+}
+
+// Recorder keeps spans in memory, for tests.
+type Recorder struct {
+	mu      sync.Mutex
+	started []*Span
+	ended   []*Span
+}
+
+// Ensure Recorder implements Tracer
+var _ Tracer = (*Recorder)(nil)
+
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+func (r *Recorder) OnStart(span *Span) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.started = append(r.started, span)
+}
+
+func (r *Recorder) OnEnd(span *Span) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ended = append(r.ended, span)
+}
+
+// Spans returns the ended spans in the order they ended.
+func (r *Recorder) Spans() []*Span {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.ended)
+}
+
+// Running returns the spans started but not yet ended.
+func (r *Recorder) Running() []*Span {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var result []*Span
+	for _, span := range r.started {
+		if !slices.Contains(r.ended, span) {
+			result = append(result, span)
+		}
+	}
+	return result
+}
+
+// Find returns the ended spans named name.
+func (r *Recorder) Find(name string) []*Span {
+	var result []*Span
+	for _, span := range r.Spans() {
+		if span.Name == name {
+			result = append(result, span)
+		}
+	}
+	return result
+}
+
+// Children returns the ended spans whose parent is span.
+func (r *Recorder) Children(span *Span) []*Span {
+	var result []*Span
+	for _, s := range r.Spans() {
+		if s.ParentID == span.ID {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+// Reset discards all spans.
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.started = nil
+	r.ended = nil
+}
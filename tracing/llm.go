@@ -0,0 +1,126 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/aqua777/ai-flow/llm/iface"
+	"github.com/aqua777/ai-flow/llm/models"
+)
+
+// LLMConfig configures the tracing decorator.
+type LLMConfig struct {
+	// Tracer receives the spans of calls whose context has no tracer. When
+	// nil only calls with a tracer in their context are traced.
+	Tracer Tracer
+	// RecordContent adds prompts and responses as span attributes. They may
+	// hold sensitive data and are left out by default.
+	RecordContent bool
+}
+
+// LLM traces the calls of the wrapped LLM as spans named "llm.Chat",
+// "llm.Generate", "llm.Embeddings", "llm.BatchEmbeddings" and
+// "llm.ListModels", with the model, streaming flag and reported tokens.
+type LLM struct {
+	llm    iface.LLM
+	config LLMConfig
+}
+
+// Ensure LLM implements iface.LLM
+var (
+	_ iface.LLM         = (*LLM)(nil)
+	_ iface.ModelGetter = (*LLM)(nil)
+)
+
+func NewLLM(llm iface.LLM, config *LLMConfig) *LLM {
+	t := &LLM{llm: llm}
+	if config != nil {
+		t.config = *config
+	}
+	return t
+}
+
+func (t *LLM) start(ctx context.Context, operation, model string) (context.Context, *Span) {
+	if t.config.Tracer != nil && FromContext(ctx) == nil {
+		ctx = WithTracer(ctx, t.config.Tracer)
+	}
+	ctx, span := Start(ctx, "llm."+operation)
+	if model != "" {
+		span.SetAttribute("model", model)
+	}
+	return ctx, span
+}
+
+func (t *LLM) ListModels(ctx context.Context) ([]*models.Model, error) {
+	ctx, span := t.start(ctx, "ListModels", "")
+	result, err := t.llm.ListModels(ctx)
+	span.SetAttribute("models", len(result))
+	span.Finish(err)
+	return result, err
+}
+
+func (t *LLM) GetModel(ctx context.Context, name string) (*models.Model, error) {
+	ctx, span := t.start(ctx, "GetModel", name)
+	result, err := iface.GetModel(ctx, t.llm, name)
+	span.Finish(err)
+	return result, err
+}
+
+func (t *LLM) Generate(ctx context.Context, r *models.GenerateRequest, stream ...func(chunk []byte) error) (*models.GenerateResponse, error) {
+	ctx, span := t.start(ctx, "Generate", r.Model)
+	span.SetAttribute("stream", len(stream) > 0 && stream[0] != nil)
+	if t.config.RecordContent {
+		span.SetAttribute("prompt", r.Prompt)
+	}
+	resp, err := t.llm.Generate(ctx, r, stream...)
+	if err == nil {
+		span.SetTokens(resp.PromptTokens, resp.CompletionTokens)
+		span.SetAttribute("finish_reason", string(resp.FinishReason))
+		if t.config.RecordContent {
+			span.SetAttribute("response", resp.Text)
+		}
+	}
+	span.Finish(err)
+	return resp, err
+}
+
+func (t *LLM) Chat(ctx context.Context, r *models.ChatRequest, stream ...func(chunk []byte) error) (*models.ChatResponse, error) {
+	ctx, span := t.start(ctx, "Chat", r.Model)
+	span.SetAttribute("stream", len(stream) > 0 && stream[0] != nil)
+	span.SetAttribute("messages", len(r.Messages))
+	if len(r.Tools) > 0 {
+		span.SetAttribute("tools", len(r.Tools))
+	}
+	if t.config.RecordContent {
+		span.SetAttribute("prompt", r.Messages)
+	}
+	resp, err := t.llm.Chat(ctx, r, stream...)
+	if err == nil {
+		if resp.Metadata != nil {
+			span.SetTokens(resp.Metadata.PromptTokens, resp.Metadata.CompletionTokens)
+		}
+		span.SetAttribute("finish_reason", string(resp.FinishReason))
+		if len(resp.ToolCalls) > 0 {
+			span.SetAttribute("tool_calls", len(resp.ToolCalls))
+		}
+		if t.config.RecordContent {
+			span.SetAttribute("response", resp.Content)
+		}
+	}
+	span.Finish(err)
+	return resp, err
+}
+
+func (t *LLM) Embeddings(ctx context.Context, r *models.EmbeddingsRequest) (*models.EmbeddingsResponse, error) {
+	ctx, span := t.start(ctx, "Embeddings", r.Model)
+	resp, err := t.llm.Embeddings(ctx, r)
+	span.Finish(err)
+	return resp, err
+}
+
+func (t *LLM) BatchEmbeddings(ctx context.Context, r *models.BatchEmbeddingsRequest) (*models.BatchEmbeddingsResponse, error) {
+	ctx, span := t.start(ctx, "BatchEmbeddings", r.Model)
+	span.SetAttribute("inputs", len(r.Inputs))
+	resp, err := t.llm.BatchEmbeddings(ctx, r)
+	span.Finish(err)
+	return resp, err
+}
@@ -0,0 +1,171 @@
+// Package tracing records spans for LLM calls, retrieval and ingestion. A
+// span has a name, start and end times, attributes, token counts, an error
+// and a parent, and is reported to a Tracer when it starts and ends. The
+// tracer travels in the context, so instrumented code costs almost nothing
+// when none is set.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Tracer receives span events. Implementations must be safe for concurrent
+// use and must not keep the span past OnEnd unless they no longer modify it.
+type Tracer interface {
+	OnStart(span *Span)
+	OnEnd(span *Span)
+}
+
+// Span is one timed operation. Its exported fields are set by Start and End;
+// read them from a Tracer, not while the span is running.
+type Span struct {
+	// TraceID is shared by a root span and all its descendants.
+	TraceID  string
+	ID       string
+	ParentID string
+	Name     string
+	Start    time.Time
+	End      time.Time
+	// PromptTokens and CompletionTokens are set on spans that call an LLM.
+	PromptTokens     int
+	CompletionTokens int
+	Err              error
+
+	mu         sync.Mutex
+	attributes map[string]any
+	tracer     Tracer
+	ended      atomic.Bool
+}
+
+type contextKey int
+
+const (
+	tracerKey contextKey = iota
+	spanKey
+)
+
+// WithTracer returns a context whose spans are reported to tracer.
+func WithTracer(ctx context.Context, tracer Tracer) context.Context {
+	return context.WithValue(ctx, tracerKey, tracer)
+}
+
+// FromContext returns the tracer set on ctx, or nil.
+func FromContext(ctx context.Context) Tracer {
+	tracer, _ := ctx.Value(tracerKey).(Tracer)
+	return tracer
+}
+
+// SpanFromContext returns the span started last on ctx, or nil.
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey).(*Span)
+	return span
+}
+
+// Start begins a span named name, a child of the span in ctx if any, and
+// returns a context carrying it. Without a tracer in ctx it returns ctx and a
+// nil span, whose methods do nothing.
+func Start(ctx context.Context, name string) (context.Context, *Span) {
+	tracer := FromContext(ctx)
+	if tracer == nil {
+		return ctx, nil
+	}
+	span := &Span{ID: newID(), Name: name, Start: time.Now(), tracer: tracer}
+	if parent := SpanFromContext(ctx); parent != nil {
+		span.TraceID = parent.TraceID
+		span.ParentID = parent.ID
+	} else {
+		span.TraceID = span.ID
+	}
+	tracer.OnStart(span)
+	return context.WithValue(ctx, spanKey, span), span
+}
+
+func newID() string {
+	return fmt.Sprintf("%016x", rand.Uint64())
+}
+
+// SetAttribute records a key/value pair on the span.
+func (s *Span) SetAttribute(key string, value any) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.attributes == nil {
+		s.attributes = make(map[string]any)
+	}
+	s.attributes[key] = value
+}
+
+// Attributes returns a copy of the span's attributes.
+func (s *Span) Attributes() map[string]any {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return maps.Clone(s.attributes)
+}
+
+// SetTokens records the tokens of an LLM call.
+func (s *Span) SetTokens(prompt, completion int) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.PromptTokens = prompt
+	s.CompletionTokens = completion
+}
+
+// Finish ends the span with err, which may be nil, and reports it. Only the
+// first call has an effect.
+func (s *Span) Finish(err error) {
+	if s == nil || s.ended.Swap(true) {
+		return
+	}
+	s.mu.Lock()
+	s.End = time.Now()
+	s.Err = err
+	s.mu.Unlock()
+	s.tracer.OnEnd(s)
+}
+
+// Duration is the time from start to end, or to now while the span runs.
+func (s *Span) Duration() time.Duration {
+	if s == nil {
+		return 0
+	}
+	s.mu.Lock()
+	end := s.End
+	s.mu.Unlock()
+	if end.IsZero() {
+		return time.Since(s.Start)
+	}
+	return end.Sub(s.Start)
+}
+
+type multi []Tracer
+
+// Multi returns a tracer that reports to each of tracers in turn.
+func Multi(tracers ...Tracer) Tracer {
+	return multi(tracers)
+}
+
+func (m multi) OnStart(span *Span) {
+	for _, t := range m {
+		t.OnStart(span)
+	}
+}
+
+func (m multi) OnEnd(span *Span) {
+	for _, t := range m {
+		t.OnEnd(span)
+	}
+}
@@ -0,0 +1,146 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/aqua777/ai-flow/llm/models"
+	mocks "github.com/aqua777/ai-flow/mocks/llm"
+)
+
+type chatLLM struct {
+	mocks.MockLLM
+	err error
+}
+
+func (c *chatLLM) Chat(ctx context.Context, r *models.ChatRequest, stream ...func(chunk []byte) error) (*models.ChatResponse, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	return &models.ChatResponse{
+		Content:      "secret answer",
+		Metadata:     &models.ChatResponseMetadata{PromptTokens: 12, CompletionTokens: 3, TotalTokens: 15},
+		FinishReason: models.FinishReasonStop,
+	}, nil
+}
+
+type TracingTestSuite struct {
+	suite.Suite
+}
+
+func TestTracingTestSuite(t *testing.T) {
+	suite.Run(t, new(TracingTestSuite))
+}
+
+func (s *TracingTestSuite) TestSpans() {
+	recorder := NewRecorder()
+	ctx := WithTracer(context.Background(), recorder)
+
+	ctx, root := Start(ctx, "root")
+	childCtx, child := Start(ctx, "child")
+	s.Equal(child, SpanFromContext(childCtx))
+	_, grandchild := Start(childCtx, "grandchild")
+	s.Len(recorder.Running(), 3)
+
+	grandchild.SetAttribute("k", "v")
+	grandchild.Finish(nil)
+	child.Finish(errors.New("boom"))
+	child.Finish(nil)
+	root.Finish(nil)
+
+	s.Empty(recorder.Running())
+	spans := recorder.Spans()
+	s.Equal([]*Span{grandchild, child, root}, spans, "spans are reported once, in end order")
+	s.Equal(root.ID, root.TraceID)
+	s.Empty(root.ParentID)
+	s.Equal(root.ID, child.ParentID)
+	s.Equal(child.ID, grandchild.ParentID)
+	s.Equal(root.TraceID, grandchild.TraceID)
+	s.EqualError(child.Err, "boom")
+	s.Equal(map[string]any{"k": "v"}, grandchild.Attributes())
+	s.False(root.End.Before(child.End))
+	s.GreaterOrEqual(root.Duration(), child.Duration())
+	s.Equal([]*Span{child}, recorder.Children(root))
+	s.Equal([]*Span{grandchild}, recorder.Find("grandchild"))
+
+	// Without a tracer nothing is recorded and the nil span is safe to use.
+	plain := context.Background()
+	ctx, span := Start(plain, "untraced")
+	s.Nil(span)
+	s.Equal(plain, ctx)
+	span.SetAttribute("k", "v")
+	span.SetTokens(1, 2)
+	span.Finish(nil)
+	s.Zero(span.Duration())
+}
+
+func (s *TracingTestSuite) TestDurationWhileFinishing() {
+	_, span := Start(WithTracer(context.Background(), NewRecorder()), "op")
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		span.Finish(nil)
+	}()
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+		}
+		s.GreaterOrEqual(span.Duration(), time.Duration(0))
+	}
+	s.Equal(span.End.Sub(span.Start), span.Duration())
+}
+
+func (s *TracingTestSuite) TestLLM() {
+	recorder := NewRecorder()
+	inner := &chatLLM{}
+	llm := NewLLM(inner, &LLMConfig{Tracer: recorder})
+	req := &models.ChatRequest{Model: "m", Messages: []*models.Message{{Role: models.UserRole, Content: "secret prompt"}}}
+
+	_, err := llm.Chat(context.Background(), req)
+	s.Require().NoError(err)
+	inner.err = errors.New("down")
+	_, err = llm.Chat(context.Background(), req)
+	s.Error(err)
+
+	spans := recorder.Find("llm.Chat")
+	s.Require().Len(spans, 2)
+	s.Equal(12, spans[0].PromptTokens)
+	s.Equal(3, spans[0].CompletionTokens)
+	s.Equal(map[string]any{"model": "m", "stream": false, "messages": 1, "finish_reason": "stop"}, spans[0].Attributes())
+	s.EqualError(spans[1].Err, "down")
+
+	// Content is only recorded on request, and a tracer in the context wins.
+	other := NewRecorder()
+	inner.err = nil
+	_, err = NewLLM(inner, &LLMConfig{Tracer: recorder, RecordContent: true}).Chat(WithTracer(context.Background(), other), req)
+	s.Require().NoError(err)
+	s.Len(recorder.Spans(), 2)
+	s.Require().Len(other.Spans(), 1)
+	s.Equal("secret answer", other.Spans()[0].Attributes()["response"])
+
+	_, err = NewLLM(inner, nil).Chat(context.Background(), req)
+	s.Require().NoError(err, "untraced without any tracer")
+}
+
+func (s *TracingTestSuite) TestSlogTracer() {
+	var buf bytes.Buffer
+	tracer := NewSlogTracer(slog.New(slog.NewTextHandler(&buf, nil)))
+	llm := NewLLM(&chatLLM{}, &LLMConfig{Tracer: Multi(tracer, NewRecorder())})
+	_, err := llm.Chat(context.Background(), &models.ChatRequest{Model: "m", Messages: []*models.Message{{Role: models.UserRole, Content: "secret prompt"}}})
+	s.Require().NoError(err)
+
+	out := buf.String()
+	s.Contains(out, "level=INFO msg=\"span ended\" name=llm.Chat")
+	s.Contains(out, "prompt_tokens=12 completion_tokens=3")
+	s.Contains(out, "attributes.model=m")
+	s.NotContains(out, "span started", "starts are logged at debug")
+	s.NotContains(out, "secret")
+}